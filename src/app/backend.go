@@ -0,0 +1,50 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Backend TLS modes, the same as in libpq
+const (
+	TLSDisable    = "disable"
+	TLSRequire    = "require"     // encrypted, the server certificate is not checked
+	TLSVerifyCA   = "verify-ca"   // the certificate is signed by a trusted CA
+	TLSVerifyFull = "verify-full" // and issued for the server name
+)
+
+type BackendConfig struct {
+	AllowedOptions []string `yaml:"allowed_options"` // driver options clients may set, profiles may set any
+}
+
+// TLS settings of the backend connection. Clients pass certificates and keys
+// as PEM text, files are read only for profiles
+type TLSOptions struct {
+	Mode       string `yaml:"mode" json:"mode"`
+	CA         string `yaml:"ca" json:"ca"`     // CA bundle, system roots if empty
+	Cert       string `yaml:"cert" json:"cert"` // client certificate
+	Key        string `yaml:"key" json:"key"`   // client certificate key
+	CAFile     string `yaml:"ca_file" json:"-"`
+	CertFile   string `yaml:"cert_file" json:"-"`
+	KeyFile    string `yaml:"key_file" json:"-"`
+	ServerName string `yaml:"server_name" json:"server_name"` // name in the certificate, host if empty
+}
+
+func (t *TLSOptions) Validate() error {
+
+	var errs []error
+	if !slices.Contains([]string{"", TLSDisable, TLSRequire, TLSVerifyCA, TLSVerifyFull}, t.Mode) {
+		errs = append(errs, fmt.Errorf("unknown TLS mode '%s', expected disable, require, verify-ca or verify-full", t.Mode))
+	}
+	if t.CA != "" && t.CAFile != "" {
+		errs = append(errs, errors.New("TLS CA is set both as text and file"))
+	}
+	hasCert := t.Cert != "" || t.CertFile != ""
+	hasKey := t.Key != "" || t.KeyFile != ""
+	if hasCert != hasKey {
+		errs = append(errs, errors.New("TLS client certificate and key must be set together"))
+	}
+	return errors.Join(errs...)
+
+}
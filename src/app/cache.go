@@ -0,0 +1,58 @@
+package app
+
+import (
+	"fmt"
+	"time"
+)
+
+// SELECT results cache settings. Zero limits mean unlimited
+type CacheConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	TTL        time.Duration `yaml:"ttl"`
+	MaxBytes   int64         `yaml:"max_bytes"`   // total size of results per profile
+	MaxEntries int           `yaml:"max_entries"` // number of results per profile
+}
+
+// Partial cache settings for profiles
+type CacheOverride struct {
+	Enabled    *bool     `yaml:"enabled"`
+	TTL        *Duration `yaml:"ttl"`
+	MaxBytes   *int64    `yaml:"max_bytes"`
+	MaxEntries *int      `yaml:"max_entries"`
+}
+
+// Cache settings for the profile: global ones with the profile overrides applied
+func (c *Config) CacheFor(profile string) CacheConfig {
+	cache := c.Cache
+	p, ok := c.Profiles[profile]
+	if !ok || p.Cache == nil {
+		return cache
+	}
+
+	if p.Cache.Enabled != nil {
+		cache.Enabled = *p.Cache.Enabled
+	}
+	if p.Cache.TTL != nil {
+		cache.TTL = time.Duration(*p.Cache.TTL)
+	}
+	if p.Cache.MaxBytes != nil {
+		cache.MaxBytes = *p.Cache.MaxBytes
+	}
+	if p.Cache.MaxEntries != nil {
+		cache.MaxEntries = *p.Cache.MaxEntries
+	}
+	return cache
+}
+
+func (c CacheConfig) validate(prefix string) []error {
+	var errs []error
+
+	if c.Enabled && c.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("%s.ttl must be positive", prefix))
+	}
+	if c.MaxBytes < 0 || c.MaxEntries < 0 {
+		errs = append(errs, fmt.Errorf("%s: limits must not be negative", prefix))
+	}
+
+	return errs
+}
@@ -0,0 +1,432 @@
+package app
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Application settings. Values are resolved in the following order,
+// each step overriding the previous one:
+// built-in defaults -> config file -> environment variables -> command line flags
+type Config struct {
+	Server      ServerConfig       `yaml:"server"`
+	Log         LogConfig          `yaml:"log"`
+	Limits      LimitsConfig       `yaml:"limits"`
+	Maintenance MaintenanceConfig  `yaml:"maintenance"`
+	Pool        PoolConfig         `yaml:"pool"`
+	Cache       CacheConfig        `yaml:"cache"`
+	RateLimit   RateLimitConfig    `yaml:"rate_limit"`
+	SQLite      SQLiteConfig       `yaml:"sqlite"`
+	ClickHouse  ClickHouseConfig   `yaml:"clickhouse"`
+	ODBC        ODBCConfig         `yaml:"odbc"`
+	DBF         DBFConfig          `yaml:"dbf"`
+	Files       FilesConfig        `yaml:"files"`
+	Backend     BackendConfig      `yaml:"backend"`
+	Admin       AdminConfig        `yaml:"admin"`
+	Replay      ReplayConfig       `yaml:"replay"`
+	Profiles    map[string]Profile `yaml:"profiles"`
+}
+
+type ServerConfig struct {
+	BindAddr       string        `yaml:"bind_addr"`       // restart required
+	BindPort       int           `yaml:"bind_port"`       // restart required
+	TLSCert        string        `yaml:"tls_cert"`        // reloaded, but TLS can't be switched on/off without restart
+	TLSKey         string        `yaml:"tls_key"`         // same as above
+	ReloadInterval time.Duration `yaml:"reload_interval"` // config file watch period, 0 = SIGHUP only
+}
+
+type AdminConfig struct {
+	Enabled  bool              `yaml:"enabled"`   // restart required
+	BindAddr string            `yaml:"bind_addr"` // separate listener, restart required
+	BindPort int               `yaml:"bind_port"` // 0 = serve on the main listener
+	Users    map[string]string `yaml:"users"`     // user name -> bcrypt password hash
+	Console  bool              `yaml:"console"`   // embedded web console at /admin/console/
+}
+
+type SQLiteConfig struct {
+	AllowedDirs []string `yaml:"allowed_dirs"` // database files are opened only from these directories
+	AllowCreate bool     `yaml:"allow_create"` // missing database files are created
+}
+
+type ClickHouseConfig struct {
+	LimitResultRows bool `yaml:"limit_result_rows"` // server side max_result_rows set to limits.max_rows for SELECT queries
+}
+
+type ODBCConfig struct {
+	AllowedDSNs    []string `yaml:"allowed_dsns"`    // data source names clients may connect to
+	AllowedDrivers []string `yaml:"allowed_drivers"` // driver names clients may connect with
+}
+
+type DBFConfig struct {
+	AllowedDirs []string `yaml:"allowed_dirs"` // table directories are opened only from these directories
+	Charset     string   `yaml:"charset"`      // default code page, e.g. CP866; empty = from the file header
+}
+
+type FilesConfig struct {
+	AllowedDirs []string `yaml:"allowed_dirs"` // CSV and XLSX directories are opened only from these directories
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"` // debug, info, warn, error
+}
+
+type LimitsConfig struct {
+	MaxRows     uint32 `yaml:"max_rows"`
+	MaxBlobSize int64  `yaml:"max_blob_size"`
+}
+
+type MaintenanceConfig struct {
+	Interval    time.Duration `yaml:"interval"`     // maintenance task period
+	PingTimeout time.Duration `yaml:"ping_timeout"` // health check of a single pool
+}
+
+// Named connection settings. Clients refer to the profile by name
+// instead of passing the server address and credentials
+type Profile struct {
+	DbType   string `yaml:"db_type"`
+	Host     string `yaml:"host"`
+	Port     uint16 `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DbName   string `yaml:"db_name"`
+	SSL      bool   `yaml:"ssl"`
+	ReadOnly bool   `yaml:"read_only"` // SQLite only
+
+	ServiceName string `yaml:"service_name"` // Oracle only
+	SID         string `yaml:"sid"`          // Oracle only
+	Charset     string `yaml:"charset"`      // Firebird and DBF only
+	Role        string `yaml:"role"`         // Firebird only
+	DSN         string `yaml:"dsn"`          // ODBC only
+	Driver      string `yaml:"driver"`       // ODBC only
+
+	TLS             *TLSOptions       `yaml:"tls"`
+	ConnectTimeout  Duration          `yaml:"connect_timeout"`
+	ReadTimeout     Duration          `yaml:"read_timeout"`
+	ApplicationName string            `yaml:"application_name"`
+	Options         map[string]string `yaml:"options"` // driver specific, added to the connection string
+
+	Pool  *PoolOverride  `yaml:"pool"`  // overrides global pool settings
+	Cache *CacheOverride `yaml:"cache"` // overrides global cache settings
+}
+
+var (
+	config      atomic.Pointer[Config]
+	configPath  string
+	configFlags func(*Config)
+
+	reloadMu    sync.Mutex
+	reloadHooks []func(oldCfg, newCfg *Config)
+)
+
+// Built-in defaults, the same as in the Makefile
+func DefaultConfig() *Config {
+	return &Config{
+		Server: ServerConfig{
+			BindAddr: "localhost",
+			BindPort: 8080,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Limits: LimitsConfig{
+			MaxRows:     10000,
+			MaxBlobSize: 32 << 20, // 32 MB
+		},
+		Maintenance: MaintenanceConfig{
+			Interval:    2 * time.Minute,
+			PingTimeout: 5 * time.Second,
+		},
+		Pool: PoolConfig{
+			IdleTimeout:     20 * time.Minute,
+			StmtIdleTimeout: 20 * time.Minute,
+		},
+		Cache: CacheConfig{
+			TTL:        time.Minute,
+			MaxBytes:   64 << 20, // 64 MB
+			MaxEntries: 10000,
+		},
+		RateLimit: RateLimitConfig{
+			RequestsPerSecond: 50,
+			Burst:             100,
+			ConnectPerSecond:  1,
+			ConnectBurst:      5,
+			QueueTimeout:      10 * time.Second,
+			MaxQueueLength:    100,
+		},
+		Replay: ReplayConfig{
+			Match: MatchNormalized,
+		},
+		Profiles: map[string]Profile{},
+	}
+}
+
+// Returns current settings. The returned value must not be modified
+func GetConfig() *Config {
+	if cfg := config.Load(); cfg != nil {
+		return cfg
+	}
+	return DefaultConfig()
+}
+
+// Loads settings on startup. The path may be empty, then the CONFIG_FILE
+// environment variable is used, and if it is not set too, no file is read.
+// The flags function applies command line overrides and is kept to be
+// reapplied on every reload
+func LoadConfig(path string, flags func(*Config)) (*Config, error) {
+	if path == "" {
+		path = GetEnvString("CONFIG_FILE", "")
+	}
+	configPath = path
+	configFlags = flags
+
+	cfg, err := readConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	config.Store(cfg)
+	SetLogLevel(cfg.Log.Level)
+
+	return cfg, nil
+}
+
+// Rereads settings. On failure the current settings are kept.
+// Settings which require restart are not changed
+func ReloadConfig() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	newCfg, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	oldCfg := GetConfig()
+	if newCfg.Server.BindAddr != oldCfg.Server.BindAddr || newCfg.Server.BindPort != oldCfg.Server.BindPort {
+		Logger.Warn("Config reload: bind_addr and bind_port changes require restart, ignored")
+		newCfg.Server.BindAddr = oldCfg.Server.BindAddr
+		newCfg.Server.BindPort = oldCfg.Server.BindPort
+	}
+	if newCfg.Admin.Enabled != oldCfg.Admin.Enabled ||
+		newCfg.Admin.BindAddr != oldCfg.Admin.BindAddr || newCfg.Admin.BindPort != oldCfg.Admin.BindPort {
+		Logger.Warn("Config reload: admin listener changes require restart, ignored")
+		newCfg.Admin.Enabled = oldCfg.Admin.Enabled
+		newCfg.Admin.BindAddr = oldCfg.Admin.BindAddr
+		newCfg.Admin.BindPort = oldCfg.Admin.BindPort
+	}
+	if newCfg.Replay != oldCfg.Replay {
+		Logger.Warn("Config reload: replay changes require restart, ignored")
+		newCfg.Replay = oldCfg.Replay
+	}
+
+	config.Store(newCfg)
+	SetLogLevel(newCfg.Log.Level)
+
+	for _, hook := range reloadHooks {
+		hook(oldCfg, newCfg)
+	}
+
+	Logger.Info("Config reloaded")
+	return nil
+}
+
+// Registers a function to be called after settings were reloaded
+func OnConfigReload(hook func(oldCfg, newCfg *Config)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	reloadHooks = append(reloadHooks, hook)
+}
+
+// Watches config file modifications, if the watch period is set.
+// Reload by SIGHUP is handled by the caller
+func WatchConfig(exit <-chan struct{}) {
+	if configPath == "" {
+		return
+	}
+
+	lastMod := configModTime()
+
+	for {
+		interval := GetConfig().Server.ReloadInterval
+		if interval <= 0 {
+			// Watch disabled, check again later as it may be enabled by SIGHUP reload
+			interval = time.Minute
+		}
+
+		select {
+		case <-exit:
+			return
+		case <-time.After(interval):
+		}
+
+		if GetConfig().Server.ReloadInterval <= 0 {
+			continue
+		}
+
+		if modTime := configModTime(); !modTime.Equal(lastMod) {
+			lastMod = modTime
+			if err := ReloadConfig(); err != nil {
+				Logger.Errorf("Config reload failed, previous settings kept: %v", err)
+			}
+		}
+	}
+}
+
+func configModTime() time.Time {
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Defaults -> file -> environment -> flags, then validation
+func readConfig() (*Config, error) {
+	cfg := DefaultConfig()
+
+	if configPath != "" {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err = dec.Decode(cfg); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("parsing config file %s: %w", configPath, err)
+		}
+	}
+
+	applyEnv(cfg)
+
+	if configFlags != nil {
+		configFlags(cfg)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func applyEnv(cfg *Config) {
+	cfg.Server.BindAddr = GetEnvString("BIND_ADDR", cfg.Server.BindAddr)
+	cfg.Server.BindPort = GetEnvInt("BIND_PORT", cfg.Server.BindPort)
+	cfg.Server.TLSCert = GetEnvString("TLS_CERT", cfg.Server.TLSCert)
+	cfg.Server.TLSKey = GetEnvString("TLS_KEY", cfg.Server.TLSKey)
+	if maxRows := GetEnvInt("MAX_ROWS", int(cfg.Limits.MaxRows)); maxRows >= 1 && maxRows <= math.MaxUint32 {
+		cfg.Limits.MaxRows = uint32(maxRows)
+	} else {
+		// Rejected by validation, a negative value would wrap around to a huge limit
+		cfg.Limits.MaxRows = 0
+	}
+	cfg.Limits.MaxBlobSize = int64(GetEnvInt("MAX_BLOB_SIZE", int(cfg.Limits.MaxBlobSize)))
+	cfg.Log.Level = GetEnvString("LOG_LEVEL", cfg.Log.Level)
+
+	// Kept for compatibility, DEBUG_LOG=true is the same as LOG_LEVEL=debug
+	if GetEnvBool("DEBUG_LOG", false) {
+		cfg.Log.Level = "debug"
+	}
+}
+
+// Checks settings consistency, all errors are reported at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.BindPort < 1 || c.Server.BindPort > 65535 {
+		errs = append(errs, fmt.Errorf("server.bind_port: %d is out of range", c.Server.BindPort))
+	}
+	if (c.Server.TLSCert == "") != (c.Server.TLSKey == "") {
+		errs = append(errs, errors.New("server.tls_cert and server.tls_key must be set together"))
+	} else if c.Server.TLSCert != "" {
+		if _, err := tls.LoadX509KeyPair(c.Server.TLSCert, c.Server.TLSKey); err != nil {
+			errs = append(errs, fmt.Errorf("server.tls_cert, server.tls_key: %w", err))
+		}
+	}
+	if c.Server.ReloadInterval < 0 {
+		errs = append(errs, errors.New("server.reload_interval must not be negative"))
+	}
+	if _, ok := parseLogLevel(c.Log.Level); !ok {
+		errs = append(errs, fmt.Errorf("log.level: unknown level '%s'", c.Log.Level))
+	}
+	if c.Limits.MaxRows == 0 {
+		errs = append(errs, errors.New("limits.max_rows must be positive"))
+	}
+	if c.Limits.MaxBlobSize <= 0 {
+		errs = append(errs, errors.New("limits.max_blob_size must be positive"))
+	}
+	if c.Maintenance.Interval <= 0 {
+		errs = append(errs, errors.New("maintenance.interval must be positive"))
+	}
+	if c.Maintenance.PingTimeout <= 0 {
+		errs = append(errs, errors.New("maintenance.ping_timeout must be positive"))
+	}
+	errs = append(errs, c.Pool.validate("pool")...)
+	errs = append(errs, c.Cache.validate("cache")...)
+	errs = append(errs, c.RateLimit.validate()...)
+	errs = append(errs, c.Replay.validate()...)
+	for _, dir := range c.SQLite.AllowedDirs {
+		if !filepath.IsAbs(dir) {
+			errs = append(errs, fmt.Errorf("sqlite.allowed_dirs: '%s' must be an absolute path", dir))
+		}
+	}
+	for _, dir := range c.DBF.AllowedDirs {
+		if !filepath.IsAbs(dir) {
+			errs = append(errs, fmt.Errorf("dbf.allowed_dirs: '%s' must be an absolute path", dir))
+		}
+	}
+	for _, dir := range c.Files.AllowedDirs {
+		if !filepath.IsAbs(dir) {
+			errs = append(errs, fmt.Errorf("files.allowed_dirs: '%s' must be an absolute path", dir))
+		}
+	}
+	if c.Admin.Enabled {
+		if len(c.Admin.Users) == 0 {
+			errs = append(errs, errors.New("admin.users: at least one user is required"))
+		}
+		if c.Admin.BindPort < 0 || c.Admin.BindPort > 65535 {
+			errs = append(errs, fmt.Errorf("admin.bind_port: %d is out of range", c.Admin.BindPort))
+		}
+		if c.Admin.BindPort != 0 && c.Admin.BindPort == c.Server.BindPort {
+			errs = append(errs, errors.New("admin.bind_port must differ from server.bind_port, or be 0 to use the main listener"))
+		}
+		for user, hash := range c.Admin.Users {
+			if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+				errs = append(errs, fmt.Errorf("admin.users.%s: bcrypt hash expected: %w", user, err))
+			}
+		}
+	}
+	for name, profile := range c.Profiles {
+		if name == "" {
+			errs = append(errs, errors.New("profiles: empty profile name"))
+		}
+		if profile.DbType == "" {
+			errs = append(errs, fmt.Errorf("profiles.%s.db_type is required", name))
+		}
+		if profile.TLS != nil {
+			if err := profile.TLS.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("profiles.%s.tls: %w", name, err))
+			}
+		}
+		if profile.Password != "" && profile.Host == "" {
+			errs = append(errs, fmt.Errorf("profiles.%s.host is required when password is set", name))
+		}
+		errs = append(errs, c.PoolFor(name).validate("profiles."+name+".pool")...)
+		errs = append(errs, c.CacheFor(name).validate("profiles."+name+".cache")...)
+	}
+
+	return errors.Join(errs...)
+}
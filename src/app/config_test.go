@@ -0,0 +1,44 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaxRowsEnv(t *testing.T) {
+
+	tests := []struct {
+		value string
+		want  uint32
+		err   bool
+	}{
+		{"500", 500, false},
+		{"1", 1, false},
+		{"4294967295", 4294967295, false},
+		{"abc", 10000, false},
+		{"0", 0, true},
+		{"-1", 0, true},
+		{"4294967296", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			t.Setenv("MAX_ROWS", tt.value)
+			cfg, err := LoadConfig("", nil)
+			if tt.err {
+				if err == nil || !strings.Contains(err.Error(), "limits.max_rows") {
+					t.Fatalf("error %v, want limits.max_rows", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Limits.MaxRows != tt.want {
+				t.Errorf("max_rows %d, want %d", cfg.Limits.MaxRows, tt.want)
+			}
+		})
+	}
+
+}
@@ -24,10 +24,10 @@ func GetEnvInt(key string, defaultValue int) int {
 
 func GetEnvBool(key string, defaultValue bool) bool {
 	if value, exists := os.LookupEnv(key); exists {
-		returnValue, err := strconv.ParseBool(value)
-		if err != nil {
-			return returnValue
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
 		}
+		Logger.Warnf("Invalid boolean value for %s, using default value: %t", key, defaultValue)
 	}
 	return defaultValue
 }
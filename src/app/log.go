@@ -3,6 +3,8 @@ package app
 import (
 	"io"
 	"os"
+	"strings"
+	"sync/atomic"
 
 	"github.com/kardianos/service"
 	"github.com/sirupsen/logrus"
@@ -20,7 +22,46 @@ type LoggerInterface interface {
 }
 
 var Logger LoggerInterface
-var DebugLog bool
+
+type LogLevel int32
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var logLevel atomic.Int32
+
+func init() {
+	logLevel.Store(int32(LevelInfo))
+}
+
+// Sets the minimal level of messages to log, unknown values are ignored
+func SetLogLevel(level string) {
+	if l, ok := parseLogLevel(level); ok {
+		logLevel.Store(int32(l))
+	}
+}
+
+func logEnabled(level LogLevel) bool {
+	return LogLevel(logLevel.Load()) <= level
+}
+
+func parseLogLevel(level string) (LogLevel, bool) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return LevelDebug, true
+	case "info":
+		return LevelInfo, true
+	case "warn", "warning":
+		return LevelWarn, true
+	case "error":
+		return LevelError, true
+	}
+	return LevelInfo, false
+}
 
 // service logger:
 type ServiceLogger struct {
@@ -28,11 +69,15 @@ type ServiceLogger struct {
 }
 
 func (s *ServiceLogger) Info(args ...interface{}) {
-	s.Logger.Info(args...)
+	if logEnabled(LevelInfo) {
+		s.Logger.Info(args...)
+	}
 }
 
 func (s *ServiceLogger) Infof(format string, args ...interface{}) {
-	s.Logger.Infof(format, args...)
+	if logEnabled(LevelInfo) {
+		s.Logger.Infof(format, args...)
+	}
 }
 
 func (s *ServiceLogger) Error(args ...interface{}) {
@@ -44,21 +89,25 @@ func (s *ServiceLogger) Errorf(format string, args ...interface{}) {
 }
 
 func (s *ServiceLogger) Warn(args ...interface{}) {
-	s.Logger.Warning(args...)
+	if logEnabled(LevelWarn) {
+		s.Logger.Warning(args...)
+	}
 }
 
 func (s *ServiceLogger) Warnf(format string, args ...interface{}) {
-	s.Logger.Warningf(format, args...)
+	if logEnabled(LevelWarn) {
+		s.Logger.Warningf(format, args...)
+	}
 }
 
 func (s *ServiceLogger) Debug(args ...interface{}) {
-	if DebugLog {
+	if logEnabled(LevelDebug) {
 		s.Logger.Info(args...)
 	}
 }
 
 func (s *ServiceLogger) Debugf(format string, args ...interface{}) {
-	if DebugLog {
+	if logEnabled(LevelDebug) {
 		s.Logger.Infof(format, args...)
 	}
 }
@@ -70,11 +119,15 @@ type ConsoleLogger struct {
 }
 
 func (c *ConsoleLogger) Info(args ...interface{}) {
-	c.Logger.Info(args...)
+	if logEnabled(LevelInfo) {
+		c.Logger.Info(args...)
+	}
 }
 
 func (c *ConsoleLogger) Infof(format string, args ...interface{}) {
-	c.Logger.Infof(format, args...)
+	if logEnabled(LevelInfo) {
+		c.Logger.Infof(format, args...)
+	}
 }
 
 func (c *ConsoleLogger) Error(args ...interface{}) {
@@ -86,21 +139,25 @@ func (c *ConsoleLogger) Errorf(format string, args ...interface{}) {
 }
 
 func (c *ConsoleLogger) Warn(args ...interface{}) {
-	c.Logger.Warn(args...)
+	if logEnabled(LevelWarn) {
+		c.Logger.Warn(args...)
+	}
 }
 
 func (c *ConsoleLogger) Warnf(format string, args ...interface{}) {
-	c.Logger.Warnf(format, args...)
+	if logEnabled(LevelWarn) {
+		c.Logger.Warnf(format, args...)
+	}
 }
 
 func (c *ConsoleLogger) Debug(args ...interface{}) {
-	if DebugLog {
+	if logEnabled(LevelDebug) {
 		c.Logger.Info(args...)
 	}
 }
 
 func (c *ConsoleLogger) Debugf(format string, args ...interface{}) {
-	if DebugLog {
+	if logEnabled(LevelDebug) {
 		c.Logger.Infof(format, args...)
 	}
 }
@@ -0,0 +1,14 @@
+package app
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger := NewConsoleLogger()
+	logger.Logger.SetOutput(io.Discard)
+	InitLogger(logger)
+	os.Exit(m.Run())
+}
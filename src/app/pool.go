@@ -0,0 +1,160 @@
+package app
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// SQL connection pool settings. Zero limits and durations mean "unlimited"
+type PoolConfig struct {
+	MaxOpenConns    int           `yaml:"max_open_conns"`     // sql.DB.SetMaxOpenConns
+	MaxIdleConns    int           `yaml:"max_idle_conns"`     // sql.DB.SetMaxIdleConns, 0 = database/sql default
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`  // sql.DB.SetConnMaxLifetime
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"` // sql.DB.SetConnMaxIdleTime
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`       // unused pool is closed by maintenance task after
+	StmtIdleTimeout time.Duration `yaml:"stmt_idle_timeout"`  // unused prepared statement is closed after
+}
+
+const defaultMaxIdleConns = 2 // the same as in database/sql
+
+// Partial pool settings, used in profiles and connection requests.
+// Only the fields given replace the inherited values
+type PoolOverride struct {
+	MaxOpenConns    *int      `yaml:"max_open_conns" json:"max_open_conns,omitempty"`
+	MaxIdleConns    *int      `yaml:"max_idle_conns" json:"max_idle_conns,omitempty"`
+	ConnMaxLifetime *Duration `yaml:"conn_max_lifetime" json:"conn_max_lifetime,omitempty"`
+	ConnMaxIdleTime *Duration `yaml:"conn_max_idle_time" json:"conn_max_idle_time,omitempty"`
+	IdleTimeout     *Duration `yaml:"idle_timeout" json:"idle_timeout,omitempty"`
+	StmtIdleTimeout *Duration `yaml:"stmt_idle_timeout" json:"stmt_idle_timeout,omitempty"`
+}
+
+// Duration accepting "90s", "1h30m" strings, or a number of seconds in JSON
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	v, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Pool settings for the profile: global ones with the profile overrides applied
+func (c *Config) PoolFor(profile string) PoolConfig {
+	pool := c.Pool
+	if p, ok := c.Profiles[profile]; ok && p.Pool != nil {
+		pool = pool.Merge(p.Pool)
+	}
+	return pool
+}
+
+// Replaces settings with the values given
+func (p PoolConfig) Merge(o *PoolOverride) PoolConfig {
+	if o == nil {
+		return p
+	}
+	if o.MaxOpenConns != nil {
+		p.MaxOpenConns = *o.MaxOpenConns
+	}
+	if o.MaxIdleConns != nil {
+		p.MaxIdleConns = *o.MaxIdleConns
+	}
+	if o.ConnMaxLifetime != nil {
+		p.ConnMaxLifetime = time.Duration(*o.ConnMaxLifetime)
+	}
+	if o.ConnMaxIdleTime != nil {
+		p.ConnMaxIdleTime = time.Duration(*o.ConnMaxIdleTime)
+	}
+	if o.IdleTimeout != nil {
+		p.IdleTimeout = time.Duration(*o.IdleTimeout)
+	}
+	if o.StmtIdleTimeout != nil {
+		p.StmtIdleTimeout = time.Duration(*o.StmtIdleTimeout)
+	}
+	return p
+}
+
+// Applies client requested values, which can't exceed the server settings.
+// A server setting of zero means unlimited, so any request is accepted then
+func (p PoolConfig) Clamp(o *PoolOverride) PoolConfig {
+	if o == nil {
+		return p
+	}
+	if o.MaxOpenConns != nil {
+		p.MaxOpenConns = clampLimit(*o.MaxOpenConns, p.MaxOpenConns)
+	}
+	if o.MaxIdleConns != nil {
+		p.MaxIdleConns = clampLimit(*o.MaxIdleConns, cmp.Or(p.MaxIdleConns, defaultMaxIdleConns))
+	}
+	if o.ConnMaxLifetime != nil {
+		p.ConnMaxLifetime = clampLimit(time.Duration(*o.ConnMaxLifetime), p.ConnMaxLifetime)
+	}
+	if o.ConnMaxIdleTime != nil {
+		p.ConnMaxIdleTime = clampLimit(time.Duration(*o.ConnMaxIdleTime), p.ConnMaxIdleTime)
+	}
+	if o.IdleTimeout != nil {
+		p.IdleTimeout = clampLimit(time.Duration(*o.IdleTimeout), p.IdleTimeout)
+	}
+	if o.StmtIdleTimeout != nil {
+		p.StmtIdleTimeout = clampLimit(time.Duration(*o.StmtIdleTimeout), p.StmtIdleTimeout)
+	}
+	return p
+}
+
+func clampLimit[T int | time.Duration](requested, max T) T {
+	if requested < 0 {
+		return max
+	}
+	if max == 0 {
+		return requested
+	}
+	if requested == 0 || requested > max {
+		return max
+	}
+	return requested
+}
+
+func (p PoolConfig) validate(prefix string) []error {
+	var errs []error
+
+	if p.MaxOpenConns < 0 {
+		errs = append(errs, fmt.Errorf("%s.max_open_conns must not be negative", prefix))
+	}
+	if p.MaxIdleConns < 0 {
+		errs = append(errs, fmt.Errorf("%s.max_idle_conns must not be negative", prefix))
+	}
+	if p.ConnMaxLifetime < 0 || p.ConnMaxIdleTime < 0 || p.IdleTimeout < 0 || p.StmtIdleTimeout < 0 {
+		errs = append(errs, errors.New(prefix+": durations must not be negative"))
+	}
+
+	return errs
+}
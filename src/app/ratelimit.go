@@ -0,0 +1,40 @@
+package app
+
+import (
+	"errors"
+	"time"
+)
+
+// Request rate and concurrency limits of the /api/v1 endpoints.
+// Zero concurrency limits mean unlimited
+type RateLimitConfig struct {
+	Enabled                    bool          `yaml:"enabled"`
+	ClientHeader               string        `yaml:"client_header"`                 // client identity header set by a trusted proxy, empty = remote IP
+	RequestsPerSecond          float64       `yaml:"requests_per_second"`           // per client, all endpoints
+	Burst                      int           `yaml:"burst"`                         // requests above the rate allowed at once
+	ConnectPerSecond           float64       `yaml:"connect_per_second"`            // per client, POST /api/v1/connection
+	ConnectBurst               int           `yaml:"connect_burst"`                 // same as above
+	MaxConcurrentPerConnection int           `yaml:"max_concurrent_per_connection"` // SQL calls by the same Connection-Id
+	MaxConcurrentPerBackend    int           `yaml:"max_concurrent_per_backend"`    // SQL calls to the same database server
+	QueueTimeout               time.Duration `yaml:"queue_timeout"`                 // excess calls wait for a free slot up to
+	MaxQueueLength             int           `yaml:"max_queue_length"`              // waiting calls per connection or backend, 0 = unlimited
+}
+
+func (c RateLimitConfig) validate() []error {
+	var errs []error
+
+	if c.RequestsPerSecond < 0 || c.ConnectPerSecond < 0 {
+		errs = append(errs, errors.New("rate_limit: rates must not be negative"))
+	}
+	if c.Enabled && (c.RequestsPerSecond > 0 && c.Burst < 1 || c.ConnectPerSecond > 0 && c.ConnectBurst < 1) {
+		errs = append(errs, errors.New("rate_limit: burst must be positive when the rate is set"))
+	}
+	if c.MaxConcurrentPerConnection < 0 || c.MaxConcurrentPerBackend < 0 || c.MaxQueueLength < 0 {
+		errs = append(errs, errors.New("rate_limit: limits must not be negative"))
+	}
+	if c.QueueTimeout < 0 {
+		errs = append(errs, errors.New("rate_limit.queue_timeout must not be negative"))
+	}
+
+	return errs
+}
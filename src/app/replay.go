@@ -0,0 +1,41 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Record and replay modes and matching levels
+const (
+	ReplayRecord = "record"
+	ReplayPlay   = "replay"
+
+	MatchExact      = "exact"      // SQL text and parameters as sent
+	MatchNormalized = "normalized" // SQL with comments, spacing and keyword case ignored
+	MatchSQL        = "sql"        // normalized SQL, parameters ignored
+)
+
+// Recording of query, prepared and blob requests with their responses,
+// and serving them back without databases. Changes require restart
+type ReplayConfig struct {
+	Mode  string `yaml:"mode"`  // empty = off, record or replay
+	File  string `yaml:"file"`  // JSON lines, appended when recording
+	Match string `yaml:"match"` // replay matching: exact, normalized or sql
+}
+
+func (c ReplayConfig) validate() []error {
+	var errs []error
+
+	if !slices.Contains([]string{"", ReplayRecord, ReplayPlay}, c.Mode) {
+		errs = append(errs, fmt.Errorf("replay.mode: unknown mode '%s', expected record or replay", c.Mode))
+	}
+	if c.Mode != "" && c.File == "" {
+		errs = append(errs, errors.New("replay.file is required"))
+	}
+	if !slices.Contains([]string{MatchExact, MatchNormalized, MatchSQL}, c.Match) {
+		errs = append(errs, fmt.Errorf("replay.match: unknown level '%s', expected exact, normalized or sql", c.Match))
+	}
+
+	return errs
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"crypto/tls"
+	"sync/atomic"
+)
+
+// Keeps TLS certificate which may be replaced on config reload
+// without restarting the listener
+type CertReloader struct {
+	cert atomic.Pointer[tls.Certificate]
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	c := &CertReloader{}
+	if err := c.Load(certFile, keyFile); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reads certificate and key files, the current certificate is kept on failure
+func (c *CertReloader) Load(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	c.cert.Store(&cert)
+	return nil
+}
+
+// To be used as tls.Config.GetCertificate
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.cert.Load(), nil
+}
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"sql-proxy/src/app"
+)
+
+// Cached SELECT result, the encoded response body
+type Entry struct {
+	Key     string
+	Profile string
+	Data    []byte
+	ETag    string
+	Expires time.Time
+}
+
+// LRU cache of SELECT results, partitioned by profile.
+// Each partition has its own TTL and size limits
+type ResultCache struct {
+	mu         sync.Mutex
+	partitions map[string]*partition
+}
+
+type partition struct {
+	items map[string]*list.Element
+	lru   *list.List // front is the most recently used
+	bytes int64
+}
+
+var Results = &ResultCache{partitions: make(map[string]*partition)}
+
+// Key of the query result: connection parameters hash, SQL text and parameters.
+// Starts with the connection hash, so results of a connection can be removed by prefix
+func Key(connHash [32]byte, sqlQuery string, params []any) string {
+	h := sha256.New()
+	h.Write([]byte(sqlQuery))
+	h.Write([]byte{0})
+	if len(params) > 0 {
+		if data, err := json.Marshal(params); err == nil {
+			h.Write(data)
+		} else {
+			fmt.Fprint(h, params...)
+		}
+	}
+	return hex.EncodeToString(connHash[:8]) + "/" + hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Returns unexpired result
+func (c *ResultCache) Get(profile, key string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.partitions[profile]
+	if !ok {
+		metricMisses.WithLabelValues(profile).Inc()
+		return nil, false
+	}
+
+	elem, ok := p.items[key]
+	if !ok {
+		metricMisses.WithLabelValues(profile).Inc()
+		return nil, false
+	}
+
+	entry := elem.Value.(*Entry)
+	if time.Now().After(entry.Expires) {
+		c.removeElement(p, elem)
+		metricMisses.WithLabelValues(profile).Inc()
+		return nil, false
+	}
+
+	p.lru.MoveToFront(elem)
+	metricHits.WithLabelValues(profile).Inc()
+	return entry, true
+}
+
+// Stores the result, evicting the least recently used ones over the limits.
+// Results larger than the partition size limit are not stored
+func (c *ResultCache) Put(profile, key string, data []byte, cfg app.CacheConfig) *Entry {
+	sum := sha256.Sum256(data)
+	entry := &Entry{
+		Key:     key,
+		Profile: profile,
+		Data:    data,
+		ETag:    `"` + hex.EncodeToString(sum[:16]) + `"`,
+		Expires: time.Now().Add(cfg.TTL),
+	}
+
+	if cfg.MaxBytes > 0 && int64(len(data)) > cfg.MaxBytes {
+		return entry
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.partitions[profile]
+	if !ok {
+		p = &partition{items: make(map[string]*list.Element), lru: list.New()}
+		c.partitions[profile] = p
+	}
+
+	if elem, ok := p.items[key]; ok {
+		c.removeElement(p, elem)
+	}
+
+	p.items[key] = p.lru.PushFront(entry)
+	p.bytes += int64(len(data))
+	metricEntries.Inc()
+	metricBytes.Add(float64(len(data)))
+
+	for (cfg.MaxEntries > 0 && p.lru.Len() > cfg.MaxEntries) || (cfg.MaxBytes > 0 && p.bytes > cfg.MaxBytes) {
+		c.removeElement(p, p.lru.Back())
+		metricEvictions.WithLabelValues(profile).Inc()
+	}
+
+	return entry
+}
+
+// Removes results of the profile (all profiles if empty) with the key prefix
+// (all keys if empty). Returns the number of results removed
+func (c *ResultCache) Invalidate(profile, prefix string, allProfiles bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := 0
+	for name, p := range c.partitions {
+		if !allProfiles && name != profile {
+			continue
+		}
+		for key, elem := range p.items {
+			if strings.HasPrefix(key, prefix) {
+				c.removeElement(p, elem)
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func (c *ResultCache) removeElement(p *partition, elem *list.Element) {
+	entry := p.lru.Remove(elem).(*Entry)
+	delete(p.items, entry.Key)
+	p.bytes -= int64(len(entry.Data))
+	metricEntries.Dec()
+	metricBytes.Sub(float64(len(entry.Data)))
+}
@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqlproxy_cache_hits_total",
+		Help: "SELECT results served from cache",
+	}, []string{"profile"})
+	metricMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqlproxy_cache_misses_total",
+		Help: "SELECT results not found in cache",
+	}, []string{"profile"})
+	metricEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqlproxy_cache_evictions_total",
+		Help: "SELECT results removed from cache by size limits",
+	}, []string{"profile"})
+	metricEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sqlproxy_cache_entries",
+		Help: "SELECT results in cache",
+	})
+	metricBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sqlproxy_cache_bytes",
+		Help: "Size of SELECT results in cache",
+	})
+)
@@ -0,0 +1,18 @@
+package console
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// Static files of the admin web console, no external assets
+//
+//go:embed static
+var static embed.FS
+
+// Serves the console under the prefix given, e.g. /admin/console/
+func Handler(prefix string) http.Handler {
+	files, _ := fs.Sub(static, "static")
+	return http.StripPrefix(prefix, http.FileServer(http.FS(files)))
+}
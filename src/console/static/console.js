@@ -0,0 +1,226 @@
+"use strict";
+
+const API_VERSION = "1.2";
+
+function $(id) { return document.getElementById(id); }
+
+function el(tag, text, cls) {
+  const e = document.createElement(tag);
+  if (text !== undefined && text !== null) e.textContent = String(text);
+  if (cls) e.className = cls;
+  return e;
+}
+
+function row(cells) {
+  const tr = el("tr");
+  for (const c of cells) tr.appendChild(c instanceof Node ? c : el("td", c));
+  return tr;
+}
+
+function cell(node) {
+  const td = el("td");
+  td.appendChild(node);
+  return td;
+}
+
+function fmtTime(s) {
+  return s ? new Date(s).toLocaleString() : "";
+}
+
+async function request(method, url, body, headers) {
+  const resp = await fetch(url, { method, body, headers, credentials: "same-origin" });
+  if (!resp.ok) throw new Error(resp.status + ": " + (await resp.text()).trim());
+  return resp;
+}
+
+// Tabs
+
+for (const btn of document.querySelectorAll("nav button")) {
+  btn.addEventListener("click", () => {
+    for (const b of document.querySelectorAll("nav button")) b.classList.toggle("active", b === btn);
+    for (const t of document.querySelectorAll(".tab")) t.classList.toggle("active", t.id === btn.dataset.tab);
+    if (btn.dataset.tab === "metrics") loadMetrics();
+  });
+}
+
+// Pools
+
+async function loadPools() {
+  try {
+    const pools = await (await request("GET", "../v1/pools")).json();
+    const body = $("pools-body");
+    body.replaceChildren();
+    for (const p of pools) {
+      const close = el("button", "Close", "danger");
+      close.onclick = () => closePool(p.id);
+      const stmts = el("td");
+      for (const s of p.statements) {
+        const div = el("div", s.id.slice(0, 8) + " (" + Math.round(s.age_sec) + " s" + (s.in_use ? ", in use" : "") + ") ");
+        const x = el("button", "x", "danger");
+        x.onclick = () => closeStatement(p.id, s.id);
+        div.appendChild(x);
+        stmts.appendChild(div);
+      }
+      body.appendChild(row([
+        p.id, p.db_type, p.host + ":" + p.port, p.db_name, p.user, p.profile || "",
+        fmtTime(p.created), fmtTime(p.last_used), p.active_requests,
+        p.stats.open_connections + " / " + p.stats.in_use + " / " + p.stats.idle,
+        stmts, cell(close),
+      ]));
+    }
+    const draining = await (await request("GET", "../v1/draining")).json();
+    $("draining").replaceChildren(...draining.map((d) => el("li", d)));
+  } catch (e) {
+    $("pools-body").replaceChildren(row([el("td", e.message, "error")]));
+  }
+}
+
+async function closePool(id) {
+  if (!confirm("Close pool " + id + "?")) return;
+  await request("DELETE", "../v1/pools/" + encodeURIComponent(id)).catch((e) => alert(e.message));
+  loadPools();
+}
+
+async function closeStatement(id, stmt) {
+  if (!confirm("Close prepared statement " + stmt + "?")) return;
+  await request("DELETE", "../v1/pools/" + encodeURIComponent(id) + "/statements/" + encodeURIComponent(stmt))
+    .catch((e) => alert(e.message));
+  loadPools();
+}
+
+$("pools-refresh").onclick = loadPools;
+setInterval(() => { if ($("pools-auto").checked && $("pools").classList.contains("active")) loadPools(); }, 5000);
+loadPools();
+
+// In-flight queries
+
+function showQueries(queries) {
+  const body = $("queries-body");
+  body.replaceChildren();
+  for (const q of queries) {
+    const cancel = el("button", q.cancelled ? "Cancelling" : "Cancel", "danger");
+    cancel.disabled = q.cancelled;
+    cancel.onclick = async () => {
+      if (!confirm("Cancel query " + q.id + "?")) return;
+      await request("DELETE", "../v1/queries/" + encodeURIComponent(q.id)).catch((e) => alert(e.message));
+    };
+    body.appendChild(row([
+      q.id, q.kind, q.connection_id, q.client_addr, fmtTime(q.started),
+      el("td", q.duration_sec.toFixed(1), "num"), el("td", q.sql, "sql"), cell(cancel),
+    ]));
+  }
+}
+
+function streamQueries() {
+  const source = new EventSource("../v1/queries/stream");
+  source.addEventListener("open", () => { $("queries-status").textContent = "live"; });
+  source.addEventListener("queries", (e) => showQueries(JSON.parse(e.data)));
+  source.addEventListener("error", () => { $("queries-status").textContent = "reconnecting..."; });
+}
+streamQueries();
+
+// Metrics
+
+const KEY_METRICS = [
+  "sqlproxy_pools",
+  "sqlproxy_prepared_statements",
+  "sqlproxy_queries_in_flight",
+  "sqlproxy_queries_cancelled_total",
+  "sqlproxy_pools_evicted_total",
+  "sqlproxy_prepared_statements_evicted_total",
+  "sqlproxy_maintenance_duration_seconds_sum",
+  "sqlproxy_maintenance_duration_seconds_count",
+  "go_goroutines",
+  "process_resident_memory_bytes",
+  "process_open_fds",
+];
+
+async function loadMetrics() {
+  const body = $("metrics-body");
+  try {
+    const text = await (await request("GET", "../v1/metrics")).text();
+    body.replaceChildren();
+    for (const line of text.split("\n")) {
+      if (line.startsWith("#") || !line.trim()) continue;
+      const i = line.lastIndexOf(" ");
+      const name = line.slice(0, i);
+      const base = name.replace(/\{.*\}$/, "");
+      if (base.startsWith("sqlproxy_") && !base.endsWith("_bucket") || KEY_METRICS.includes(base)) {
+        body.appendChild(row([name, el("td", line.slice(i + 1), "num")]));
+      }
+    }
+  } catch (e) {
+    body.replaceChildren(row([el("td", e.message, "error")]));
+  }
+}
+
+$("metrics-refresh").onclick = loadMetrics;
+
+// Query runner, uses the public API
+
+let connectionId = "";
+
+async function loadDbTypes() {
+  try {
+    const types = await (await request("GET", "../v1/db_types")).json();
+    $("runner-db-type").append(...types.map((t) => el("option", t)));
+  } catch (e) {
+    $("runner-conn").textContent = e.message;
+    $("runner-conn").className = "error";
+  }
+}
+loadDbTypes();
+
+$("runner-connect").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  const form = new FormData(e.target);
+  const info = {};
+  for (const [k, v] of form.entries()) {
+    if (v === "") continue;
+    info[k] = k === "port" ? Number(v) : v;
+  }
+  try {
+    const resp = await request("POST", "/api/v1/connection", JSON.stringify(info),
+      { "API-Version": API_VERSION, "Content-Type": "application/json" });
+    connectionId = (await resp.text()).trim();
+    $("runner-conn").textContent = "Connection-Id: " + connectionId;
+    $("runner-conn").className = "";
+  } catch (err) {
+    $("runner-conn").textContent = err.message;
+    $("runner-conn").className = "error";
+  }
+});
+
+async function runQuery(method) {
+  const status = $("runner-status");
+  const result = $("runner-result");
+  if (!connectionId) { status.textContent = "Connect first"; status.className = "error"; return; }
+  status.textContent = "running...";
+  status.className = "status";
+  const started = performance.now();
+  try {
+    const resp = await request(method, "/api/v1/query", $("runner-sql").value,
+      { "API-Version": API_VERSION, "Connection-Id": connectionId, "Content-Type": "text/plain" });
+    const elapsed = ((performance.now() - started) / 1000).toFixed(2);
+    result.replaceChildren();
+    if (method === "PUT") {
+      status.textContent = "Done in " + elapsed + " s";
+      return;
+    }
+    const env = await resp.json();
+    status.textContent = env.rows_count + " rows in " + elapsed + " s" + (env.exceeds_max_rows ? ", truncated by MAX_ROWS" : "");
+    const columns = env.rows.length ? Object.keys(env.rows[0]) : [];
+    const head = el("tr");
+    for (const c of columns) head.appendChild(el("th", c));
+    result.appendChild(head);
+    for (const r of env.rows) {
+      result.appendChild(row(columns.map((c) => r[c] === null ? el("td", "NULL", "status") : el("td", typeof r[c] === "object" ? JSON.stringify(r[c]) : r[c]))));
+    }
+  } catch (err) {
+    status.textContent = err.message;
+    status.className = "error";
+  }
+}
+
+$("runner-select").onclick = () => runQuery("POST");
+$("runner-exec").onclick = () => runQuery("PUT");
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"sql-proxy/src/app"
+)
+
+const clickhouseDefaultPort = 9000
+
+type clickhouseDialect struct{ baseDialect }
+
+func (clickhouseDialect) DriverName() string {
+	return "clickhouse"
+}
+
+// ClickHouse native protocol connection URL. Certificates are set by the connector
+func (clickhouseDialect) DSN(connInfo *DbConnInfo) (string, error) {
+
+	query := url.Values{}
+	switch tlsMode(connInfo) {
+	case app.TLSRequire:
+		query.Set("secure", "true")
+		query.Set("skip_verify", "true")
+	case app.TLSVerifyCA, app.TLSVerifyFull:
+		query.Set("secure", "true")
+	}
+	if connInfo.ConnectTimeout > 0 {
+		query.Set("dial_timeout", time.Duration(connInfo.ConnectTimeout).String())
+	}
+	if connInfo.ReadTimeout > 0 {
+		query.Set("read_timeout", time.Duration(connInfo.ReadTimeout).String())
+	}
+	if connInfo.ApplicationName != "" {
+		// List of name/version
+		if strings.ContainsAny(connInfo.ApplicationName, ",/") {
+			return "", notSupported(connInfo, "application_name with ',' or '/'")
+		}
+		query.Set("client_info_product", connInfo.ApplicationName+"/"+app.BuildVersion)
+	}
+
+	for key, value := range connInfo.Options {
+		query.Set(key, value)
+	}
+
+	port := cmp.Or(connInfo.Port, clickhouseDefaultPort)
+	dsn := url.URL{
+		Scheme:   "clickhouse",
+		User:     url.UserPassword(connInfo.User, connInfo.Password),
+		Host:     net.JoinHostPort(connInfo.Host, strconv.Itoa(int(port))),
+		Path:     "/" + connInfo.DbName,
+		RawQuery: query.Encode(),
+	}
+	return dsn.String(), nil
+
+}
+
+// Settings of SELECT queries, nil when clickhouse.limit_result_rows is off.
+// The server stops reading when the result exceeds max rows, one more row
+// is requested to report exceeds_max_rows. In break mode the server returns
+// whole blocks, so the result may still be larger and is truncated as usual
+func clickhouseSelectSettings(cfg *app.Config) map[string]any {
+
+	if !cfg.ClickHouse.LimitResultRows {
+		return nil
+	}
+	return map[string]any{
+		"max_result_rows":      uint64(cfg.Limits.MaxRows) + 1,
+		"result_overflow_mode": "break",
+	}
+
+}
+
+func (clickhouseDialect) QuoteIdent(name string) string {
+	return quoteBackticks(name)
+}
+
+func (clickhouseDialect) Syntax() Syntax {
+	return Syntax{BackslashEscapes: true, Backticks: true}
+}
+
+func (clickhouseDialect) Decoder(*sql.ColumnType) ValueDecoder {
+	return decodeClickhouse
+}
+
+// ClickHouse values to JSON friendly ones. UInt64 and wider integers are
+// written as strings as JSON numbers lose precision above 2^53. Nullable
+// values come as pointers, Array and Map are converted element by element.
+// DateTime64 keeps its column time zone offset
+func decodeClickhouse(v any) any {
+
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case uint64:
+		return strconv.FormatUint(x, 10)
+	case big.Int:
+		return x.String()
+	case *big.Int:
+		if x == nil {
+			return nil
+		}
+		return x.String()
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case string, bool, int8, int16, int32, int64, uint8, uint16, uint32, float32, float64:
+		return x
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil
+		}
+		return decodeClickhouse(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		list := make([]any, rv.Len())
+		for i := range list {
+			list[i] = decodeClickhouse(rv.Index(i).Interface())
+		}
+		return list
+	case reflect.Map:
+		// JSON object keys are strings
+		m := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := decodeClickhouse(iter.Key().Interface())
+			m[fmt.Sprint(key)] = decodeClickhouse(iter.Value().Interface())
+		}
+		return m
+	}
+
+	return v
+
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sql-proxy/src/app"
+)
+
+func TestClickhouseSelectSettings(t *testing.T) {
+
+	cfg := app.DefaultConfig()
+	if settings := clickhouseSelectSettings(cfg); settings != nil {
+		t.Fatalf("limit is off by default, got %v", settings)
+	}
+
+	cfg.ClickHouse.LimitResultRows = true
+	cfg.Limits.MaxRows = 1000
+	want := map[string]any{"max_result_rows": uint64(1001), "result_overflow_mode": "break"}
+	if settings := clickhouseSelectSettings(cfg); !reflect.DeepEqual(settings, want) {
+		t.Fatalf("settings = %v, want %v", settings, want)
+	}
+
+	// Set per query, so pools made before a reload don't keep the old limit
+	dsn, err := clickhouseDialect{}.DSN(&DbConnInfo{Host: "localhost", User: "u", DbName: "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(dsn, "max_result_rows") {
+		t.Fatalf("dsn has the result limit: %s", dsn)
+	}
+
+}
+
+func TestDecodeClickhouse(t *testing.T) {
+
+	s := "a"
+	tests := []struct {
+		name  string
+		value any
+		want  any
+	}{
+		{name: "uint64", value: uint64(1) << 60, want: "1152921504606846976"},
+		{name: "nullable", value: &s, want: "a"},
+		{name: "null", value: (*string)(nil), want: nil},
+		{name: "array of UInt8", value: []uint8{1, 2}, want: []any{uint8(1), uint8(2)}},
+		{name: "array of UInt64", value: []uint64{1}, want: []any{"1"}},
+		{name: "map", value: map[uint8]string{1: "x"}, want: map[string]any{"1": "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeClickhouse(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeClickhouse(%#v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+
+}
@@ -0,0 +1,309 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"sql-proxy/src/app"
+)
+
+var ErrConnectionString = errors.New("Invalid connection string")
+
+// Connection string keyword and value, in the order given
+type connStringPair struct {
+	name  string // as written, for messages
+	key   string // lower case
+	value string
+}
+
+// Client side settings of OLE DB providers and ODBC drivers, not affecting the proxy connection
+var ignoredKeywords = []string{
+	"persist security info", "auto translate", "packet size", "workstation id", "wsid",
+	"use procedure for prepare", "multipleactiveresultsets", "mars connection", "mars_connection",
+	"datatypecompatibility", "option", "charset",
+}
+
+// Fills the connection settings from the OLE DB (ADODB) or ODBC connection string,
+// like Provider=SQLOLEDB;Data Source=srv,1433;Initial Catalog=db;User ID=u;Password=p.
+// The server type is taken from Provider or Driver, db_type if neither is given.
+// Values of the string override the other fields. The string is cleared, as it may keep the password
+func (o *DbConnInfo) ParseConnectionString() error {
+
+	if o.ConnectionString == "" {
+		return nil
+	}
+	pairs, err := splitConnectionString(o.ConnectionString)
+	o.ConnectionString = ""
+	if err != nil {
+		return err
+	}
+
+	dbType, err := connStringDbType(pairs)
+	if err != nil {
+		return err
+	}
+	switch {
+	case dbType == "" && o.DbType == "":
+		return fmt.Errorf("%w: Provider or Driver is required", ErrConnectionString)
+	case dbType == "":
+		dbType = o.DbType
+	case o.DbType != "" && o.DbType != dbType:
+		return fmt.Errorf("%w: db_type %s does not match the provider", ErrConnectionString, o.DbType)
+	}
+	if dbType != "sqlserver" && dbType != "postgres" && dbType != "mysql" {
+		return fmt.Errorf("%w: db_type %s is not supported", ErrConnectionString, dbType)
+	}
+	o.DbType = dbType
+
+	var encrypt, trustCertificate *bool
+	tlsMode := ""
+
+	for _, pair := range pairs {
+		switch pair.key {
+		case "provider", "driver":
+		case "data source", "server", "servername", "address", "addr", "network address", "host":
+			if err = o.setServer(pair.value); err != nil {
+				return err
+			}
+		case "port":
+			port, err := strconv.ParseUint(pair.value, 10, 16)
+			if err != nil {
+				return fmt.Errorf("%w: invalid %s", ErrConnectionString, pair.name)
+			}
+			o.Port = uint16(port)
+		case "initial catalog", "database", "location", "db":
+			o.DbName = pair.value
+		case "user id", "uid", "user", "username", "user name":
+			o.User = pair.value
+		case "password", "pwd":
+			o.Password = pair.value
+		case "connect timeout", "connection timeout", "timeout", "logintimeout":
+			seconds, err := strconv.Atoi(pair.value)
+			if err != nil || seconds < 0 {
+				return fmt.Errorf("%w: invalid %s", ErrConnectionString, pair.name)
+			}
+			o.ConnectTimeout = app.Duration(time.Duration(seconds) * time.Second)
+		case "application name", "app", "applicationname":
+			o.ApplicationName = pair.value
+		case "encrypt", "use encryption for data":
+			if encrypt, err = connStringBool(pair); err != nil {
+				return err
+			}
+		case "trustservercertificate", "trust server certificate":
+			if trustCertificate, err = connStringBool(pair); err != nil {
+				return err
+			}
+		case "sslmode", "ssl mode":
+			if tlsMode, err = connStringSSLMode(pair); err != nil {
+				return err
+			}
+		case "integrated security", "trusted_connection":
+			return fmt.Errorf("%w: Windows authentication (%s) is not supported", ErrConnectionString, pair.name)
+		default:
+			if !slices.Contains(ignoredKeywords, pair.key) {
+				return fmt.Errorf("%w: unsupported keyword %s", ErrConnectionString, pair.name)
+			}
+		}
+	}
+
+	// SQL Server style encryption flags. Encrypt=no keeps the driver default
+	// (the login packet is still encrypted), servers forcing encryption refuse plain connections
+	if encrypt != nil {
+		switch {
+		case !*encrypt:
+		case trustCertificate != nil && *trustCertificate:
+			tlsMode = app.TLSRequire
+		default:
+			tlsMode = app.TLSVerifyFull
+		}
+	}
+	if tlsMode != "" {
+		tlsOptions := app.TLSOptions{}
+		if o.TLS != nil {
+			tlsOptions = *o.TLS
+		}
+		tlsOptions.Mode = tlsMode
+		o.TLS = &tlsOptions
+	}
+
+	return nil
+
+}
+
+// Server name, SQL Server style values may be prefixed by the protocol
+// and followed by the port: tcp:srv,1433
+func (o *DbConnInfo) setServer(value string) error {
+
+	host := strings.TrimPrefix(value, "tcp:")
+	if name, port, found := strings.Cut(host, ","); found {
+		p, err := strconv.ParseUint(strings.TrimSpace(port), 10, 16)
+		if err != nil {
+			return fmt.Errorf("%w: invalid port in server name %s", ErrConnectionString, value)
+		}
+		host = name
+		o.Port = uint16(p)
+	}
+	if strings.Contains(host, `\`) {
+		return fmt.Errorf("%w: named instance %s is not supported, give the port instead", ErrConnectionString, value)
+	}
+	host = strings.TrimSpace(host)
+	if host == "." || strings.EqualFold(host, "(local)") {
+		host = "localhost"
+	}
+	o.Host = host
+	return nil
+
+}
+
+// Server type by the OLE DB provider or ODBC driver, empty if none is given
+func connStringDbType(pairs []connStringPair) (string, error) {
+
+	dbType := ""
+	for _, pair := range pairs {
+		name := strings.ToLower(pair.value)
+		var found string
+		switch pair.key {
+		case "provider":
+			switch {
+			case strings.HasPrefix(name, "sqloledb"), strings.HasPrefix(name, "msoledbsql"), strings.HasPrefix(name, "sqlncli"):
+				found = "sqlserver"
+			case strings.HasPrefix(name, "postgresql"), strings.HasPrefix(name, "pgnp"):
+				found = "postgres"
+			case strings.HasPrefix(name, "msdasql"):
+				// ODBC driver through OLE DB, the type is given by Driver
+				continue
+			default:
+				return "", fmt.Errorf("%w: unsupported provider %s", ErrConnectionString, pair.value)
+			}
+		case "driver":
+			switch {
+			case strings.Contains(name, "sql server"), strings.Contains(name, "sql native client"):
+				found = "sqlserver"
+			case strings.HasPrefix(name, "postgresql"), strings.HasPrefix(name, "psqlodbc"):
+				found = "postgres"
+			case strings.HasPrefix(name, "mysql"):
+				found = "mysql"
+			default:
+				return "", fmt.Errorf("%w: unsupported driver %s, use db_type odbc", ErrConnectionString, pair.value)
+			}
+		default:
+			continue
+		}
+		if dbType != "" && dbType != found {
+			return "", fmt.Errorf("%w: provider and driver are for different servers", ErrConnectionString)
+		}
+		dbType = found
+	}
+	return dbType, nil
+
+}
+
+// Splits the string into keyword=value pairs. Values may be enclosed in quotes
+// (OLE DB) or braces (ODBC), the closing character is doubled inside
+func splitConnectionString(s string) ([]connStringPair, error) {
+
+	var pairs []connStringPair
+	runes := []rune(s)
+	i := 0
+
+	for i < len(runes) {
+		if runes[i] == ';' || runes[i] == ' ' || runes[i] == '\t' || runes[i] == '\r' || runes[i] == '\n' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(runes) && runes[i] != '=' && runes[i] != ';' {
+			i++
+		}
+		name := strings.TrimSpace(string(runes[start:i]))
+		if i >= len(runes) || runes[i] != '=' {
+			return nil, fmt.Errorf("%w: no value for %s", ErrConnectionString, name)
+		}
+		i++
+
+		for i < len(runes) && (runes[i] == ' ' || runes[i] == '\t') {
+			i++
+		}
+		var value string
+		if i < len(runes) && (runes[i] == '{' || runes[i] == '"' || runes[i] == '\'') {
+			closing := runes[i]
+			if closing == '{' {
+				closing = '}'
+			}
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("%w: unterminated value of %s", ErrConnectionString, name)
+				}
+				if runes[i] == closing {
+					if i+1 < len(runes) && runes[i+1] == closing {
+						sb.WriteRune(closing)
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			value = sb.String()
+			for i < len(runes) && (runes[i] == ' ' || runes[i] == '\t') {
+				i++
+			}
+			if i < len(runes) && runes[i] != ';' {
+				return nil, fmt.Errorf("%w: ; expected after the value of %s", ErrConnectionString, name)
+			}
+		} else {
+			start = i
+			for i < len(runes) && runes[i] != ';' {
+				i++
+			}
+			value = strings.TrimSpace(string(runes[start:i]))
+		}
+
+		if name == "" {
+			return nil, fmt.Errorf("%w: empty keyword", ErrConnectionString)
+		}
+		key := strings.ToLower(strings.Join(strings.Fields(name), " "))
+		pairs = append(pairs, connStringPair{name: name, key: key, value: value})
+	}
+
+	return pairs, nil
+
+}
+
+func connStringBool(pair connStringPair) (*bool, error) {
+	var v bool
+	switch strings.ToLower(pair.value) {
+	case "true", "yes", "1", "mandatory", "strict":
+		v = true
+	case "false", "no", "0", "optional":
+		v = false
+	default:
+		return nil, fmt.Errorf("%w: invalid %s", ErrConnectionString, pair.name)
+	}
+	return &v, nil
+}
+
+// Postgres and MySQL SSL modes. Modes falling back to plain connections
+// (allow, prefer, preferred) are taken as disable, as the drivers don't negotiate
+func connStringSSLMode(pair connStringPair) (string, error) {
+	switch strings.ToLower(pair.value) {
+	case "disable", "disabled", "allow", "prefer", "preferred":
+		return app.TLSDisable, nil
+	case "require", "required":
+		return app.TLSRequire, nil
+	case "verify-ca", "verify_ca":
+		return app.TLSVerifyCA, nil
+	case "verify-full", "verify_identity":
+		return app.TLSVerifyFull, nil
+	}
+	return "", fmt.Errorf("%w: invalid %s", ErrConnectionString, pair.name)
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"sql-proxy/src/app"
+)
+
+func TestSplitConnectionString(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		input string
+		want  []connStringPair
+		err   bool
+	}{
+		{name: "plain", input: "Provider=SQLOLEDB;Data Source=srv;User ID=u",
+			want: []connStringPair{
+				{name: "Provider", key: "provider", value: "SQLOLEDB"},
+				{name: "Data Source", key: "data source", value: "srv"},
+				{name: "User ID", key: "user id", value: "u"},
+			}},
+		{name: "spaces and empty pairs", input: " ;  Initial   Catalog = db ;; Pwd= p w ;",
+			want: []connStringPair{
+				{name: "Initial   Catalog", key: "initial catalog", value: "db"},
+				{name: "Pwd", key: "pwd", value: "p w"},
+			}},
+		{name: "quotes", input: `Password="a;b""c";User='it''s'`,
+			want: []connStringPair{
+				{name: "Password", key: "password", value: `a;b"c`},
+				{name: "User", key: "user", value: "it's"},
+			}},
+		{name: "braces", input: "Driver={ODBC Driver 18 for SQL Server};PWD={p;}}w} ",
+			want: []connStringPair{
+				{name: "Driver", key: "driver", value: "ODBC Driver 18 for SQL Server"},
+				{name: "PWD", key: "pwd", value: "p;}w"},
+			}},
+		{name: "equals in value", input: "Password=a=b",
+			want: []connStringPair{{name: "Password", key: "password", value: "a=b"}}},
+		{name: "empty", input: " ; "},
+
+		{name: "no value", input: "Server=srv;Trusted", err: true},
+		{name: "empty keyword", input: "=x", err: true},
+		{name: "unterminated quote", input: `Password="abc`, err: true},
+		{name: "unterminated brace", input: "Driver={SQL Server", err: true},
+		{name: "text after quoted value", input: `Password="a"b;User=u`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitConnectionString(tt.input)
+			if tt.err {
+				if !errors.Is(err, ErrConnectionString) {
+					t.Fatalf("error %v, want %v", err, ErrConnectionString)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+
+}
+
+func TestParseConnectionString(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		dbType string
+		input  string
+		want   DbConnInfo
+		err    bool
+	}{
+		{name: "OLE DB", input: "Provider=SQLOLEDB.1;Data Source=tcp:srv,1444;Initial Catalog=db;User ID=u;Password={p;w};Connect Timeout=15;Application Name=app;Persist Security Info=False",
+			want: DbConnInfo{DbType: "sqlserver", Host: "srv", Port: 1444, DbName: "db", User: "u", Password: "p;w",
+				ConnectTimeout: app.Duration(15 * time.Second), ApplicationName: "app"}},
+		{name: "local server", input: "Provider=MSOLEDBSQL;Server=(local)",
+			want: DbConnInfo{DbType: "sqlserver", Host: "localhost"}},
+		{name: "ODBC", input: "Driver={PostgreSQL Unicode};Server=pg;Port=5433;Database=db;Uid=u;Pwd=p;SSLMode=verify-full",
+			want: DbConnInfo{DbType: "postgres", Host: "pg", Port: 5433, DbName: "db", User: "u", Password: "p",
+				TLS: &app.TLSOptions{Mode: app.TLSVerifyFull}}},
+		{name: "MySQL through MSDASQL", input: "Provider=MSDASQL;Driver={MySQL ODBC 8.0 Unicode Driver};Server=my;SSLMode=required",
+			want: DbConnInfo{DbType: "mysql", Host: "my", TLS: &app.TLSOptions{Mode: app.TLSRequire}}},
+		{name: "db_type without provider", dbType: "postgres", input: "Host=pg;sslmode=prefer",
+			want: DbConnInfo{DbType: "postgres", Host: "pg", TLS: &app.TLSOptions{Mode: app.TLSDisable}}},
+		{name: "encrypt", input: "Provider=SQLOLEDB;Server=srv;Encrypt=yes",
+			want: DbConnInfo{DbType: "sqlserver", Host: "srv", TLS: &app.TLSOptions{Mode: app.TLSVerifyFull}}},
+		{name: "encrypt with trusted certificate", input: "Provider=SQLOLEDB;Server=srv;Encrypt=true;TrustServerCertificate=true",
+			want: DbConnInfo{DbType: "sqlserver", Host: "srv", TLS: &app.TLSOptions{Mode: app.TLSRequire}}},
+		{name: "no encryption keeps driver default", input: "Provider=SQLOLEDB;Server=srv;Encrypt=no",
+			want: DbConnInfo{DbType: "sqlserver", Host: "srv"}},
+		{name: "optional encryption", input: "Driver={ODBC Driver 18 for SQL Server};Server=srv;Encrypt=optional",
+			want: DbConnInfo{DbType: "sqlserver", Host: "srv"}},
+
+		{name: "no provider", input: "Server=srv", err: true},
+		{name: "provider conflict", input: "Provider=SQLOLEDB;Driver={PostgreSQL Unicode};Server=srv", err: true},
+		{name: "db_type conflict", dbType: "mysql", input: "Provider=SQLOLEDB;Server=srv", err: true},
+		{name: "unsupported provider", input: "Provider=Microsoft.Jet.OLEDB.4.0;Data Source=db.mdb", err: true},
+		{name: "unsupported driver", input: "Driver={SQLite3 ODBC Driver};Database=db", err: true},
+		{name: "unsupported db_type", dbType: "oracle", input: "Host=ora", err: true},
+		{name: "unsupported keyword", input: "Provider=SQLOLEDB;Server=srv;Failover Partner=srv2", err: true},
+		{name: "Windows authentication", input: "Provider=SQLOLEDB;Server=srv;Integrated Security=SSPI", err: true},
+		{name: "named instance", input: `Provider=SQLOLEDB;Server=srv\SQLEXPRESS`, err: true},
+		{name: "invalid port", input: "Provider=SQLOLEDB;Server=srv,port", err: true},
+		{name: "invalid timeout", input: "Provider=SQLOLEDB;Server=srv;Connect Timeout=-1", err: true},
+		{name: "invalid encrypt", input: "Provider=SQLOLEDB;Server=srv;Encrypt=maybe", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DbConnInfo{DbType: tt.dbType, ConnectionString: tt.input}
+			err := got.ParseConnectionString()
+			if got.ConnectionString != "" {
+				t.Error("connection string is not cleared")
+			}
+			if tt.err {
+				if !errors.Is(err, ErrConnectionString) {
+					t.Fatalf("error %v, want %v", err, ErrConnectionString)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+
+}
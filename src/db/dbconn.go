@@ -0,0 +1,152 @@
+package db
+
+import (
+	"slices"
+	"time"
+)
+
+// Marks the connection as used by a request
+func (c *DbConn) acquire() {
+	c.refs.Add(1)
+	c.touch()
+}
+
+func (c *DbConn) touch() {
+	c.lastUse.Store(time.Now().UnixNano())
+}
+
+// Last use
+func (c *DbConn) Timestamp() time.Time {
+	return time.Unix(0, c.lastUse.Load())
+}
+
+// Must be called when the request has finished with the connection
+func (c *DbConn) Release() {
+	if c.refs.Add(-1) == 0 && c.evicted.Load() {
+		c.close()
+	}
+}
+
+func (c *DbConn) InUse() bool {
+	return c.refs.Load() > 0
+}
+
+// Not used for longer than the timeout, never if the timeout is 0
+func (c *DbConn) idle(timeout time.Duration) bool {
+	return timeout > 0 && !c.InUse() && time.Since(c.Timestamp()).Abs() > timeout
+}
+
+// Number of requests using the connection now
+func (c *DbConn) ActiveRequests() int {
+	return int(c.refs.Load())
+}
+
+// Closes the connection now if unused, otherwise on the last Release.
+// Must be called after the connection was removed from the list
+func (c *DbConn) evict() {
+	c.evicted.Store(true)
+	if !c.InUse() {
+		c.close()
+	}
+}
+
+func (c *DbConn) close() {
+	c.closeOnce.Do(func() {
+		for _, stmt := range c.Statements() {
+			stmt.evict()
+		}
+		c.DB.Close()
+	})
+}
+
+// Returns a copy of the prepared statements list
+func (c *DbConn) Statements() []*DbStmt {
+	c.stmtMu.RLock()
+	defer c.stmtMu.RUnlock()
+
+	return slices.Clone(c.stmt)
+}
+
+func (c *DbConn) addStatement(stmt *DbStmt) {
+	c.stmtMu.Lock()
+	defer c.stmtMu.Unlock()
+
+	c.stmt = append(c.stmt, stmt)
+}
+
+func (c *DbConn) findStatement(id string) (*DbStmt, bool) {
+	c.stmtMu.RLock()
+	defer c.stmtMu.RUnlock()
+
+	for _, stmt := range c.stmt {
+		if stmt.Id == id {
+			return stmt, true
+		}
+	}
+	return nil, false
+}
+
+// Removes the statement from the list, it is closed when the last request releases it
+func (c *DbConn) removeStatement(stmt *DbStmt) bool {
+	return c.removeStatementIf(stmt, nil)
+}
+
+// Removes the statement if the condition, checked under the list lock, is met
+func (c *DbConn) removeStatementIf(stmt *DbStmt, cond func() bool) bool {
+	c.stmtMu.Lock()
+	i := slices.Index(c.stmt, stmt)
+	if i >= 0 && cond != nil && !cond() {
+		i = -1
+	}
+	if i >= 0 {
+		c.stmt = slices.Delete(c.stmt, i, i+1)
+	}
+	c.stmtMu.Unlock()
+
+	if i >= 0 {
+		stmt.evict()
+	}
+	return i >= 0
+}
+
+// Marks the statement and its connection as used by a request
+func (s *DbStmt) acquire() {
+	s.refs.Add(1)
+	s.Conn.acquire()
+	s.lastUse.Store(time.Now().UnixNano())
+}
+
+// Must be called when the request has finished with the statement
+func (s *DbStmt) Release() {
+	if s.refs.Add(-1) == 0 && s.evicted.Load() {
+		s.close()
+	}
+	s.Conn.Release()
+}
+
+func (s *DbStmt) InUse() bool {
+	return s.refs.Load() > 0
+}
+
+// Not used for longer than the timeout, never if the timeout is 0
+func (s *DbStmt) idle(timeout time.Duration) bool {
+	return timeout > 0 && !s.InUse() && time.Since(s.Timestamp()).Abs() > timeout
+}
+
+// Last use
+func (s *DbStmt) Timestamp() time.Time {
+	return time.Unix(0, s.lastUse.Load())
+}
+
+func (s *DbStmt) evict() {
+	s.evicted.Store(true)
+	if !s.InUse() {
+		s.close()
+	}
+}
+
+func (s *DbStmt) close() {
+	s.closeOnce.Do(func() {
+		s.Stmt.Close()
+	})
+}
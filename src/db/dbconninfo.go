@@ -4,18 +4,125 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/gob"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sql-proxy/src/app"
 )
 
+var ErrOptionNotAllowed = errors.New("Connection option is not allowed")
+
 func (o DbConnInfo) GetHash() ([32]byte, error) {
 	var buf bytes.Buffer
 	var hash [32]byte
 
+	// Maps are encoded in random order, options are hashed sorted by key
+	options := o.Options
+	o.Options = nil
+
 	enc := gob.NewEncoder(&buf)
 	err := enc.Encode(o)
 	if err != nil {
 		return hash, err
 	}
+	for _, key := range slices.Sorted(maps.Keys(options)) {
+		if err = enc.Encode([2]string{key, options[key]}); err != nil {
+			return hash, err
+		}
+	}
 
 	hash = sha256.Sum256(buf.Bytes())
 	return hash, nil
 }
+
+// Fills connection settings from the named profile. Values set in the profile
+// take precedence over the request, so the profile credentials can't be
+// sent to another server
+func (o *DbConnInfo) ApplyProfile(profiles map[string]app.Profile) bool {
+	if o.Profile == "" {
+		return true
+	}
+
+	profile, ok := profiles[o.Profile]
+	if !ok {
+		return false
+	}
+
+	o.DbType = profile.DbType
+	if profile.Host != "" {
+		o.Host = profile.Host
+	}
+	if profile.Port != 0 {
+		o.Port = profile.Port
+	}
+	if profile.User != "" {
+		o.User = profile.User
+	}
+	if profile.Password != "" {
+		o.Password = profile.Password
+	}
+	if profile.DbName != "" {
+		o.DbName = profile.DbName
+	}
+	if profile.SSL {
+		o.SSL = true
+	}
+	if profile.ReadOnly {
+		o.ReadOnly = true
+	}
+	if profile.ServiceName != "" || profile.SID != "" {
+		o.ServiceName = profile.ServiceName
+		o.SID = profile.SID
+	}
+	if profile.Charset != "" {
+		o.Charset = profile.Charset
+	}
+	if profile.Role != "" {
+		o.Role = profile.Role
+	}
+	if profile.DSN != "" || profile.Driver != "" {
+		o.DSN = profile.DSN
+		o.Driver = profile.Driver
+	}
+	if profile.TLS != nil {
+		o.TLS = profile.TLS
+	}
+	if profile.ConnectTimeout > 0 {
+		o.ConnectTimeout = profile.ConnectTimeout
+	}
+	if profile.ReadTimeout > 0 {
+		o.ReadTimeout = profile.ReadTimeout
+	}
+	if profile.ApplicationName != "" {
+		o.ApplicationName = profile.ApplicationName
+	}
+	if len(profile.Options) > 0 {
+		options := maps.Clone(o.Options)
+		if options == nil {
+			options = map[string]string{}
+		}
+		maps.Copy(options, profile.Options)
+		o.Options = options
+	}
+
+	return true
+}
+
+// Checks the settings given by the client, before the profile is applied.
+// Driver options may open files or change security settings, so only the allowed ones are accepted
+func (o *DbConnInfo) Validate(allowedOptions []string) error {
+
+	if o.TLS != nil {
+		if err := o.TLS.Validate(); err != nil {
+			return err
+		}
+	}
+	for key := range o.Options {
+		if !slices.Contains(allowedOptions, key) {
+			return fmt.Errorf("%w: '%s'", ErrOptionNotAllowed, key)
+		}
+	}
+	return nil
+
+}
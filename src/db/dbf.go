@@ -0,0 +1,48 @@
+package db
+
+import (
+	"net/url"
+
+	"sql-proxy/src/app"
+)
+
+type dbfDialect struct{ baseDialect }
+
+func (dbfDialect) DriverName() string {
+	return "dbf"
+}
+
+// DBF data source name: a directory of tables with optional code page
+func (dbfDialect) DSN(connInfo *DbConnInfo) (string, error) {
+
+	err := rejectOptions(connInfo, optionTLS, optionConnectTimeout, optionReadTimeout, optionApplicationName, optionOptions)
+	if err != nil {
+		return "", err
+	}
+
+	cfg := app.GetConfig().DBF
+	path, err := allowedDir(cfg.AllowedDirs, connInfo.DbName)
+	if err != nil {
+		return "", err
+	}
+
+	// Without the charset it is taken from the language driver byte of each file
+	charset := connInfo.Charset
+	if charset == "" {
+		charset = cfg.Charset
+	}
+	if charset == "" {
+		return path, nil
+	}
+	return path + "?" + url.Values{"charset": {charset}}.Encode(), nil
+
+}
+
+// The driver supports SELECT from tables only
+func (dbfDialect) HealthQuery() string {
+	return ""
+}
+
+func (dbfDialect) Capabilities() Capabilities {
+	return Capabilities{ReadOnly: true}
+}
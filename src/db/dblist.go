@@ -1,44 +1,50 @@
 package db
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
-	"fmt"
-	"net/url"
+	"database/sql/driver"
+	"errors"
+	"hash/maphash"
 	"sql-proxy/src/app"
 
 	"time"
 
-	"slices"
-
 	"github.com/google/uuid"
 )
 
-// Init map
+var shardSeed = maphash.MakeSeed()
+
+// Init maps
 func (o *DbList) Init() {
 
-	items := make(map[string]DbConn)
-	o.items = items
+	for i := range o.shards {
+		o.shards[i].items = make(map[string]*DbConn)
+		o.byHash[i].items = make(map[[32]byte]*DbConn)
+	}
 
 }
 
-// Gets SQL server connection by GUID
-func (o *DbList) GetById(id string, updateTimestamp bool) (*sql.DB, bool) {
+func (o *DbList) shard(id string) *idShard {
+	return &o.shards[maphash.String(shardSeed, id)&(shardCount-1)]
+}
 
-	o.mu.RLock()
+func (o *DbList) hashShard(hash [32]byte) *hashShard {
+	return &o.byHash[hash[0]&(shardCount-1)]
+}
 
-	if dbConn, ok := o.items[id]; ok {
-		if updateTimestamp {
-			o.mu.RUnlock()
-			o.mu.Lock()
-			dbConn.Timestamp = time.Now()
-			o.items[id] = dbConn
-			o.mu.Unlock()
-		}
-		return dbConn.DB, true
-	}
+// Takes SQL server connection by GUID for a request.
+// The caller must call Release on the returned connection when finished
+func (o *DbList) Acquire(id string) (*DbConn, bool) {
+
+	shard := o.shard(id)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
 
-	o.mu.RUnlock()
+	if dbConn, ok := shard.items[id]; ok {
+		dbConn.acquire()
+		return dbConn, true
+	}
 
 	app.Logger.Errorf("SQL connection with guid='%s' not found", id)
 	return nil, false
@@ -47,108 +53,151 @@ func (o *DbList) GetById(id string, updateTimestamp bool) (*sql.DB, bool) {
 
 // Gets the new SQL server connection with parameters given.
 // First lookups in pool, if fails opens new one and returns GUID value
-func (o *DbList) GetByParams(connInfo *DbConnInfo) (string, bool) {
+func (o *DbList) GetByParams(connInfo *DbConnInfo) (string, error) {
+	if IsDraining(connInfo.DbType, connInfo.Host, connInfo.Port) {
+		app.Logger.Errorf("Backend %s is draining, connection refused", BackendKey(connInfo.DbType, connInfo.Host, connInfo.Port))
+		return "", ErrBackendDraining
+	}
+
 	hash, err := connInfo.GetHash()
 	if err != nil {
 		errMsg := "Hash calculation failed"
 		app.Logger.Error(errMsg)
-		return errMsg, false
+		return "", errors.New(errMsg)
 	}
 
-	guid := ""
-
-	o.mu.RLock()
-
-	for key, dbConn := range o.items {
-
-		// Search existing connection by hash to reuse
-		if bytes.Equal(dbConn.Hash[:], hash[:]) {
-			guid = key
-			app.Logger.Debugf("DB connection with id %s found in the pool", guid)
-
-			// Perform checks
-			if err = dbConn.DB.Ping(); err == nil {
-				o.mu.RUnlock()
-				// Everything is ok, return guid
-				return guid, true
-			} else {
-				// Bad connection, need to clean
-				o.mu.RUnlock()
-				o.mu.Lock()
-				delete(o.items, guid)
-				o.mu.Unlock()
-				o.mu.RLock()
-				app.Logger.Debugf("DB connection with id %s is dead and removed from the pool", guid)
-			}
-		}
+	if guid, ok := o.findAlive(hash); ok {
+		return guid, nil
 	}
 
-	o.mu.RUnlock()
+	// At this step nothing found, create the new.
+	// Concurrent requests with the same parameters wait for a single pool
+	result, err, _ := o.creating.Do(string(hash[:]), func() (any, error) {
+		if guid, ok := o.findAlive(hash); ok {
+			return guid, nil
+		}
+		return o.getNewConnection(connInfo, hash)
+	})
+	if err != nil {
+		return "", err
+	}
 
-	// At this step nothing found, create the new
-	return o.getNewConnection(connInfo, hash)
+	return result.(string), nil
 }
 
-// Creates the new SQL connection regarding concurrency
-func (o *DbList) getNewConnection(connInfo *DbConnInfo, hash [32]byte) (string, bool) {
+// Searches existing connection by hash to reuse
+func (o *DbList) findAlive(hash [32]byte) (string, bool) {
 
-	o.mu.Lock()
-	defer o.mu.Unlock()
+	hs := o.hashShard(hash)
+	hs.mu.RLock()
+	found, ok := hs.items[hash]
+	if ok {
+		found.acquire()
+	}
+	hs.mu.RUnlock()
 
-	// Prepare DSN string
-	var dsn string
+	if !ok {
+		return "", false
+	}
 
-	encodedPassword := url.QueryEscape(connInfo.Password)
+	guid := found.Id
+	app.Logger.Debugf("DB connection with id %s found in the pool", guid)
 
-	switch connInfo.DbType {
-	case "postgres":
-		sslMode := "disable"
-		if connInfo.SSL {
-			sslMode = "enable"
-		}
-		dsn = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-			connInfo.Host, connInfo.Port, connInfo.User, encodedPassword, connInfo.DbName, sslMode)
-	case "sqlserver":
-		dsn = fmt.Sprintf("server=%s;user id=%s;password=%s;database=%s;port=%d",
-			connInfo.Host, connInfo.User, encodedPassword, connInfo.DbName, connInfo.Port)
-	case "mysql":
-		dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s",
-			connInfo.User, encodedPassword, connInfo.Host, connInfo.Port, connInfo.DbName)
-	default:
-		errMsg := fmt.Sprintf("No suitable driver implemented for server type '%s'", connInfo.DbType)
-		app.Logger.Error(errMsg)
-		return errMsg, false
+	// Perform checks outside the lock, the server may not respond for a while
+	err := healthCheck(context.Background(), found.DB, found.Dialect)
+	found.Release()
+	if err == nil {
+		// Everything is ok, return guid
+		return guid, true
+	}
+
+	// Bad connection, need to clean
+	o.remove(found)
+	app.Logger.Debugf("DB connection with id %s is dead and removed from the pool", guid)
+	return "", false
+}
+
+// Creates the new SQL connection
+func (o *DbList) getNewConnection(connInfo *DbConnInfo, hash [32]byte) (string, error) {
+
+	dialect, ok := GetDialect(connInfo.DbType)
+	if !ok {
+		err := unsupportedDbType(connInfo.DbType)
+		app.Logger.Error(err)
+		return "", err
+	}
+
+	// Prepare DSN string
+	dsn, err := dialect.DSN(connInfo)
+	if err != nil {
+		app.Logger.Errorf("Connection to %s '%s' rejected: %v", connInfo.DbType, connInfo.DbName, err)
+		return "", err
 	}
 
 	// Open new SQL server connection
-	var err error
 	var newDb *sql.DB
-
-	newDb, err = sql.Open(connInfo.DbType, dsn)
+	if cd, ok := dialect.(connectorDialect); ok {
+		var connector driver.Connector
+		if connector, err = cd.Connector(dsn, connInfo); err == nil {
+			newDb = sql.OpenDB(connector)
+		}
+	} else {
+		newDb, err = sql.Open(dialect.DriverName(), dsn)
+	}
 
 	// Check for failure
+	if errors.Is(err, ErrInvalidTLS) {
+		app.Logger.Error(err)
+		return "", err
+	}
 	if err != nil {
 		errMsg := "Error establishing SQL server connection"
-		app.Logger.Error(errMsg)
-		return errMsg, false
+		app.Logger.Errorf("%s: %v", errMsg, err)
+		return "", errors.New(errMsg)
 	}
 
 	// Check if alive
-	if err = newDb.Ping(); err != nil {
+	if err = healthCheck(context.Background(), newDb, dialect); err != nil {
 		errMsg := "Just created SQL connection is dead"
-		app.Logger.Error(errMsg)
-		return errMsg, false
+		app.Logger.Errorf("%s: %v", errMsg, err)
+		newDb.Close()
+		return "", errors.New(errMsg)
 	}
 
+	// Pool settings: global, then profile, then limited client request
+	applyPoolConfig(newDb, app.GetConfig().PoolFor(connInfo.Profile).Clamp(connInfo.Pool))
+
 	// Insert into pool
+	info := *connInfo
+	info.Password = ""
+	if info.TLS != nil {
+		tlsOptions := *info.TLS
+		tlsOptions.Key = ""
+		info.TLS = &tlsOptions
+	}
+
 	newId := uuid.New().String()
-	newItem := DbConn{
-		Hash:      hash,
-		DB:        newDb,
-		Timestamp: time.Now(),
+	newItem := &DbConn{
+		Id:      newId,
+		Hash:    hash,
+		DB:      newDb,
+		Dialect: dialect,
+		Info:    info,
+		Profile: connInfo.Profile,
+		Pool:    connInfo.Pool,
+		Created: time.Now(),
 	}
+	newItem.touch()
+
+	shard := o.shard(newId)
+	shard.mu.Lock()
+	shard.items[newId] = newItem
+	shard.mu.Unlock()
 
-	o.items[newId] = newItem
+	hs := o.hashShard(hash)
+	hs.mu.Lock()
+	hs.items[hash] = newItem
+	hs.mu.Unlock()
 
 	app.Logger.Debugf("New SQL connection with id %s was added to the pool: "+
 		"Host=%s, Port=%d, dbName=%s, user=%s, dbType=%s, Id=%s",
@@ -161,150 +210,169 @@ func (o *DbList) getNewConnection(connInfo *DbConnInfo, hash [32]byte) (string,
 		newId,
 	)
 
-	return newId, true
+	return newId, nil
+}
+
+// Applies pool limits to sql.DB, may be called for open pool too
+func applyPoolConfig(sqlDb *sql.DB, pool app.PoolConfig) {
+	sqlDb.SetMaxOpenConns(pool.MaxOpenConns)
+	if pool.MaxIdleConns > 0 {
+		sqlDb.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	sqlDb.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	sqlDb.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
 }
 
 // Deletes SQL server connection
 func (o *DbList) Delete(id string) {
-	o.mu.Lock()
-	defer o.mu.Unlock()
+	shard := o.shard(id)
+	shard.mu.RLock()
+	dbConn, ok := shard.items[id]
+	shard.mu.RUnlock()
 
-	delete(o.items, id)
+	if ok {
+		o.remove(dbConn)
+	}
 	app.Logger.Debugf("DB connection with id %s was deleted by query", id)
 
 }
 
+// Removes the connection from the list if it was not replaced yet,
+// it is closed when the last request releases it
+func (o *DbList) remove(dbConn *DbConn) bool {
+	return o.removeIf(dbConn, nil)
+}
+
+// Removes the connection if the condition is met. The condition is checked
+// under the list locks, so requests can't take the connection meanwhile
+func (o *DbList) removeIf(dbConn *DbConn, cond func() bool) bool {
+	shard := o.shard(dbConn.Id)
+	hs := o.hashShard(dbConn.Hash)
+	shard.mu.Lock()
+	hs.mu.Lock()
+
+	if cond != nil && !cond() {
+		hs.mu.Unlock()
+		shard.mu.Unlock()
+		return false
+	}
+
+	current, ok := shard.items[dbConn.Id]
+	removed := ok && current == dbConn
+	if removed {
+		delete(shard.items, dbConn.Id)
+	}
+	if current, ok := hs.items[dbConn.Hash]; ok && current == dbConn {
+		delete(hs.items, dbConn.Hash)
+	}
+	hs.mu.Unlock()
+	shard.mu.Unlock()
+
+	dbConn.evict()
+	return removed
+}
+
+// Removes the prepared statement if the condition is met. The condition is
+// checked under the list locks, so requests can't take the statement meanwhile
+func (o *DbList) removeStatementIf(stmt *DbStmt, cond func() bool) bool {
+	shard := o.shard(stmt.Conn.Id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	return stmt.Conn.removeStatementIf(stmt, cond)
+}
+
+// Returns connection by GUID without acquiring it
+func (o *DbList) Get(id string) (*DbConn, bool) {
+	shard := o.shard(id)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	dbConn, ok := shard.items[id]
+	return dbConn, ok
+}
+
+// Returns all connections
+func (o *DbList) List() []*DbConn {
+	var list []*DbConn
+	for i := range o.shards {
+		shard := &o.shards[i]
+		shard.mu.RLock()
+		for _, dbConn := range shard.items {
+			list = append(list, dbConn)
+		}
+		shard.mu.RUnlock()
+	}
+	return list
+}
+
 // *** SQL prepared statements ***
 
 // Saves SQL prepared statement
-func (o *DbList) PutPreparedStatement(id string, stmt *sql.Stmt) (string, bool) {
+func (o *DbList) PutPreparedStatement(id string, stmt *sql.Stmt, sqlQuery string) (string, bool) {
 
-	o.mu.Lock()
-	defer o.mu.Unlock()
+	shard := o.shard(id)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
 
-	dbConn, ok := o.items[id]
+	dbConn, ok := shard.items[id]
 	if !ok {
 		return "", false
 	}
 
 	newId := uuid.New().String()
-	dbStmt := DbStmt{
-		Id:        newId,
-		Stmt:      stmt,
-		Timestamp: time.Now(),
+	dbStmt := &DbStmt{
+		Id:      newId,
+		Stmt:    stmt,
+		Query:   sqlQuery,
+		Conn:    dbConn,
+		Created: time.Now(),
 	}
+	dbStmt.lastUse.Store(time.Now().UnixNano())
 
-	dbConn.Timestamp = time.Now()
-	dbConn.Stmt = append(dbConn.Stmt, dbStmt)
-	o.items[id] = dbConn
+	dbConn.touch()
+	dbConn.addStatement(dbStmt)
 
 	return newId, true
 }
 
-// Gets SQL prepared statement
-func (o *DbList) GetPreparedStatement(connId, stmtId string) (*sql.Stmt, bool) {
+// Takes SQL prepared statement for a request.
+// The caller must call Release on the returned statement when finished
+func (o *DbList) AcquirePreparedStatement(connId, stmtId string) (*DbStmt, bool) {
 
-	o.mu.RLock()
-	defer o.mu.RUnlock()
+	shard := o.shard(connId)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
 
-	dbConn, ok := o.items[connId]
+	dbConn, ok := shard.items[connId]
 	if !ok {
 		return nil, false
 	}
 
-	for i := range dbConn.Stmt {
-		if dbConn.Stmt[i].Id == stmtId {
-			return dbConn.Stmt[i].Stmt, true
-		}
+	dbStmt, ok := dbConn.findStatement(stmtId)
+	if !ok {
+		return nil, false
 	}
-	return nil, false
+
+	dbStmt.acquire()
+	return dbStmt, true
 }
 
 // Closes and deletes SQL prepared statement
 func (o *DbList) ClosePreparedStatement(connId, stmtId string) bool {
 
-	o.mu.Lock()
-	defer o.mu.Unlock()
+	shard := o.shard(connId)
+	shard.mu.RLock()
+	dbConn, ok := shard.items[connId]
+	shard.mu.RUnlock()
 
-	dbConn, ok := o.items[connId]
 	if !ok {
 		return false
 	}
-	for i := range dbConn.Stmt {
-		if dbConn.Stmt[i].Id == stmtId {
-			dbConn.Stmt[i].Stmt.Close()
-			dbConn.Stmt = slices.Delete(dbConn.Stmt, i, i+1)
-			break
-		}
-	}
 
+	if dbStmt, ok := dbConn.findStatement(stmtId); ok {
+		dbConn.removeStatement(dbStmt)
+	}
 	return true
 
 }
-
-// *** Maintenance ***
-
-func (o *DbList) RunMaintenance() {
-	ticker := time.NewTicker(2 * time.Minute)
-	defer ticker.Stop()
-
-	for {
-		<-ticker.C
-
-		// detect dead connections
-		var deadItems []string
-		var countConn, countDeadConn, countStmt int
-
-		o.mu.Lock()
-
-		for key, dbConn := range o.items {
-
-			var lostStmts []string
-			countConn++
-
-			if err := dbConn.DB.Ping(); err != nil {
-				// dead connection
-				deadItems = append(deadItems, key)
-				countDeadConn++
-			} else if time.Since(dbConn.Timestamp).Abs().Minutes() > 20 {
-				// connection not used for last 20 minutes
-				deadItems = append(deadItems, key)
-				countDeadConn++
-			}
-
-			// check prepared statements
-			for _, stmt := range dbConn.Stmt {
-				// prepared statements not used last 20 minutes
-				if time.Since(stmt.Timestamp).Abs().Minutes() > 20 {
-					lostStmts = append(lostStmts, stmt.Id)
-					countStmt++
-				}
-			}
-
-			// delete lost prepared statements
-			for _, lost := range lostStmts {
-				for i := range dbConn.Stmt {
-					if dbConn.Stmt[i].Id == lost {
-						dbConn.Stmt[i].Stmt.Close()
-						dbConn.Stmt = slices.Delete(dbConn.Stmt, i, i+1)
-						break
-					}
-				}
-			}
-
-		}
-
-		// remove dead connections
-		for _, item := range deadItems {
-			dbConn := o.items[item]
-			dbConn.DB.Close()
-			delete(o.items, item)
-		}
-
-		o.mu.Unlock()
-
-		app.Logger.Infof("Regular task: SQL connection pool size = %d", countConn)
-		app.Logger.Infof("Regular task: %d dead connections removed", countDeadConn)
-		app.Logger.Infof("Regular task: %d lost prepared statements removed", countStmt)
-	}
-}
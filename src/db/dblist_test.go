@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// Driver without a server: connections answer pings and prepare statements
+// which can't be executed
+type stubDriver struct{}
+
+func (stubDriver) Open(name string) (driver.Conn, error) { return stubConn{name}, nil }
+
+// Called on pings with the data source name, if set
+var stubPing atomic.Pointer[func(name string)]
+
+type stubConn struct{ name string }
+
+func (stubConn) Prepare(string) (driver.Stmt, error) { return stubStmt{}, nil }
+func (stubConn) Close() error                        { return nil }
+func (stubConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c stubConn) Ping(context.Context) error {
+	if ping := stubPing.Load(); ping != nil {
+		(*ping)(c.name)
+	}
+	return nil
+}
+
+type stubStmt struct{}
+
+func (stubStmt) Close() error                               { return nil }
+func (stubStmt) NumInput() int                              { return -1 }
+func (stubStmt) Exec([]driver.Value) (driver.Result, error) { return nil, errors.New("not supported") }
+func (stubStmt) Query([]driver.Value) (driver.Rows, error)  { return nil, errors.New("not supported") }
+
+type stubDialect struct{ baseDialect }
+
+func (stubDialect) DriverName() string                       { return "stub" }
+func (stubDialect) DSN(connInfo *DbConnInfo) (string, error) { return connInfo.DbName, nil }
+func (stubDialect) HealthQuery() string                      { return "" }
+
+func init() {
+	sql.Register("stub", stubDriver{})
+	RegisterDialect("stub", stubDialect{})
+}
+
+// Connections of many clients, as a busy proxy has
+const benchPools = 1000
+
+func newBenchList(b *testing.B) (*DbList, []*DbConnInfo, []string) {
+
+	list := &DbList{}
+	list.Init()
+	infos := make([]*DbConnInfo, benchPools)
+	ids := make([]string, benchPools)
+	for i := range infos {
+		infos[i] = &DbConnInfo{DbType: "stub", Host: "db", User: "user" + strconv.Itoa(i), DbName: "erp"}
+		id, err := list.GetByParams(infos[i])
+		if err != nil {
+			b.Fatal(err)
+		}
+		ids[i] = id
+	}
+	b.Cleanup(func() {
+		for _, id := range ids {
+			list.Delete(id)
+		}
+	})
+	return list, infos, ids
+
+}
+
+// POST /connection of a client with an open pool
+func BenchmarkGetByParams(b *testing.B) {
+
+	list, infos, _ := newBenchList(b)
+	var n atomic.Uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := list.GetByParams(infos[n.Add(1)%benchPools]); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+
+}
+
+// Connection lookup of every query request
+func BenchmarkAcquire(b *testing.B) {
+
+	list, _, ids := newBenchList(b)
+	var n atomic.Uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			dbConn, ok := list.Acquire(ids[n.Add(1)%benchPools])
+			if !ok {
+				b.Error("connection not found")
+				continue
+			}
+			dbConn.Release()
+		}
+	})
+
+}
+
+func BenchmarkGet(b *testing.B) {
+
+	list, _, ids := newBenchList(b)
+	var n atomic.Uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, ok := list.Get(ids[n.Add(1)%benchPools]); !ok {
+				b.Error("connection not found")
+			}
+		}
+	})
+
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var ErrUnsupportedDbType = errors.New("Unsupported server type")
+
+// Server type specific behaviour. Build tagged driver files register
+// dialects of their server types, so only the compiled in ones are available
+type Dialect interface {
+	DriverName() string                              // database/sql driver
+	DSN(connInfo *DbConnInfo) (string, error)        // connection string for the driver
+	Placeholder(n int) string                        // query parameter, numbered from 1
+	QuoteIdent(name string) string                   // table or column name for queries
+	Decoder(columnType *sql.ColumnType) ValueDecoder // result value conversion for JSON
+	HealthQuery() string                             // connection check, empty for ping only
+	Syntax() Syntax                                  // lexical rules, for rewriting queries
+	Capabilities() Capabilities
+}
+
+// Dialects of drivers configured in code, e.g. with TLS config.
+// The connector is made from the DSN built by the dialect
+type connectorDialect interface {
+	Connector(dsn string, connInfo *DbConnInfo) (driver.Connector, error)
+}
+
+// Dialects with per query settings of SELECT results, e.g. server side row limits
+type selectContextDialect interface {
+	SelectContext(ctx context.Context) context.Context
+}
+
+// Context of a SELECT query with the settings of the dialect
+func SelectContext(ctx context.Context, dialect Dialect) context.Context {
+
+	if sd, ok := dialect.(selectContextDialect); ok {
+		return sd.SelectContext(ctx)
+	}
+	return ctx
+
+}
+
+// What the server type supports beyond SELECT
+type Capabilities struct {
+	ReadOnly  bool // data can't be changed, PUT requests are rejected
+	TextBlobs bool // text BLOBs are read as strings decoded from the connection charset (Firebird)
+}
+
+// SQL lexical rules beyond the standard ones: single quoted strings, double quoted names, -- and /* */ comments
+type Syntax struct {
+	BackslashEscapes  bool // in strings (MySQL)
+	EscapeStrings     bool // E'...' strings with backslash escapes (Postgres)
+	DollarQuotes      bool // $tag$...$tag$ strings (Postgres)
+	AltQuotes         bool // q'[...]' strings (Oracle)
+	Brackets          bool // [name] identifiers (SQL Server)
+	Backticks         bool // `name` identifiers (MySQL, ClickHouse)
+	HashComments      bool // # comments (MySQL)
+	NestedComments    bool // /* /* */ */ (Postgres)
+	QuestionOperators bool // ?, ?| and ?& JSON operators (Postgres)
+}
+
+// Defaults for dialects: ? placeholders, double quoted names, values as is
+type baseDialect struct{}
+
+func (baseDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (baseDialect) QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// MySQL and ClickHouse style quoting
+func quoteBackticks(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (baseDialect) Decoder(*sql.ColumnType) ValueDecoder {
+	return decodeDefault
+}
+
+func (baseDialect) HealthQuery() string {
+	return "SELECT 1"
+}
+
+func (baseDialect) Syntax() Syntax {
+	return Syntax{}
+}
+
+func (baseDialect) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+var dialects = struct {
+	sync.RWMutex
+	items map[string]Dialect
+}{items: map[string]Dialect{}}
+
+// Registers the dialect of the server type, called from init of driver files
+func RegisterDialect(dbType string, dialect Dialect) {
+	dialects.Lock()
+	defer dialects.Unlock()
+
+	if _, found := dialects.items[dbType]; found {
+		panic("db: dialect registered twice for " + dbType)
+	}
+	dialects.items[dbType] = dialect
+}
+
+func GetDialect(dbType string) (Dialect, bool) {
+	dialects.RLock()
+	defer dialects.RUnlock()
+
+	dialect, found := dialects.items[dbType]
+	return dialect, found
+}
+
+// Server types compiled in, sorted
+func DbTypes() []string {
+	dialects.RLock()
+	defer dialects.RUnlock()
+
+	return slices.Sorted(maps.Keys(dialects.items))
+}
+
+func unsupportedDbType(dbType string) error {
+	return fmt.Errorf("%w '%s', available types: %s", ErrUnsupportedDbType, dbType, strings.Join(DbTypes(), ", "))
+}
+
+// Checks the pool is alive with the dialect health query
+func healthCheck(ctx context.Context, sqlDb *sql.DB, dialect Dialect) error {
+
+	query := dialect.HealthQuery()
+	if query == "" {
+		return sqlDb.PingContext(ctx)
+	}
+	var result any
+	return sqlDb.QueryRowContext(ctx, query).Scan(&result)
+
+}
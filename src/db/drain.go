@@ -0,0 +1,51 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+var ErrBackendDraining = errors.New("Backend is draining, no new connections accepted")
+
+// Backends which accept no new connections, existing ones are kept until closed
+var draining = struct {
+	mu    sync.RWMutex
+	items map[string]bool
+}{items: make(map[string]bool)}
+
+// Backend identity: server type, host and port
+func BackendKey(dbType, host string, port uint16) string {
+	return fmt.Sprintf("%s://%s:%d", dbType, host, port)
+}
+
+func SetDraining(dbType, host string, port uint16, drain bool) {
+	draining.mu.Lock()
+	defer draining.mu.Unlock()
+
+	if drain {
+		draining.items[BackendKey(dbType, host, port)] = true
+	} else {
+		delete(draining.items, BackendKey(dbType, host, port))
+	}
+}
+
+func IsDraining(dbType, host string, port uint16) bool {
+	draining.mu.RLock()
+	defer draining.mu.RUnlock()
+
+	return draining.items[BackendKey(dbType, host, port)]
+}
+
+func DrainingBackends() []string {
+	draining.mu.RLock()
+	defer draining.mu.RUnlock()
+
+	list := make([]string, 0, len(draining.items))
+	for key := range draining.items {
+		list = append(list, key)
+	}
+	slices.Sort(list)
+	return list
+}
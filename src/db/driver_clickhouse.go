@@ -0,0 +1,47 @@
+//go:build clickhouse
+// +build clickhouse
+
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"sql-proxy/src/app"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func init() {
+	RegisterDialect("clickhouse", clickhouseDialect{})
+}
+
+// The tls block is applied as TLS config, the driver has no DSN parameters for certificates
+func (clickhouseDialect) Connector(dsn string, connInfo *DbConnInfo) (driver.Connector, error) {
+
+	options, err := clickhouse.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if connInfo.TLS != nil {
+		tlsConf, err := tlsConfig(connInfo)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConf != nil {
+			options.TLS = tlsConf
+		}
+	}
+	return clickhouse.Connector(options), nil
+
+}
+
+// The result limit is set per query from the current config, so reloads apply to open pools
+func (clickhouseDialect) SelectContext(ctx context.Context) context.Context {
+
+	settings := clickhouseSelectSettings(app.GetConfig())
+	if settings == nil {
+		return ctx
+	}
+	return clickhouse.Context(ctx, clickhouse.WithSettings(settings))
+
+}
@@ -0,0 +1,10 @@
+//go:build dbf
+// +build dbf
+
+package db
+
+import _ "sql-proxy/src/dbf"
+
+func init() {
+	RegisterDialect("dbf", dbfDialect{})
+}
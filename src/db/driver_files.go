@@ -0,0 +1,10 @@
+//go:build files
+// +build files
+
+package db
+
+import _ "sql-proxy/src/files"
+
+func init() {
+	RegisterDialect("files", filesDialect{})
+}
@@ -0,0 +1,10 @@
+//go:build firebird
+// +build firebird
+
+package db
+
+import _ "github.com/nakagami/firebirdsql"
+
+func init() {
+	RegisterDialect("firebird", firebirdDialect{})
+}
@@ -3,4 +3,193 @@
 
 package db
 
-import _ "github.com/go-sql-driver/mysql"
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"sql-proxy/src/app"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const mysqlDefaultPort = 3306
+
+func init() {
+	RegisterDialect("mysql", mysqlDialect{})
+}
+
+type mysqlDialect struct{ baseDialect }
+
+func (mysqlDialect) DriverName() string {
+	return "mysql"
+}
+
+// The driver formats the DSN itself, passwords need no escaping
+func (mysqlDialect) DSN(connInfo *DbConnInfo) (string, error) {
+
+	config := mysql.NewConfig()
+	config.User = connInfo.User
+	config.Passwd = connInfo.Password
+	config.Net = "tcp"
+	config.Addr = net.JoinHostPort(connInfo.Host, strconv.Itoa(int(cmp.Or(connInfo.Port, mysqlDefaultPort))))
+	config.DBName = connInfo.DbName
+	config.Timeout = time.Duration(connInfo.ConnectTimeout)
+	config.ReadTimeout = time.Duration(connInfo.ReadTimeout)
+	if tlsMode(connInfo) == app.TLSDisable {
+		config.TLSConfig = "false"
+	}
+	if name := connInfo.ApplicationName; name != "" {
+		// Attributes are a list of key:value pairs
+		if strings.ContainsAny(name, ",:") {
+			return "", notSupported(connInfo, "application_name with ',' or ':'")
+		}
+		config.ConnectionAttributes = "program_name:" + name
+	}
+
+	dsn := config.FormatDSN()
+	if len(connInfo.Options) > 0 {
+		query := url.Values{}
+		for _, key := range slices.Sorted(maps.Keys(connInfo.Options)) {
+			query.Set(key, connInfo.Options[key])
+		}
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + query.Encode()
+	}
+	return dsn, nil
+
+}
+
+func (mysqlDialect) QuoteIdent(name string) string {
+	return quoteBackticks(name)
+}
+
+func (mysqlDialect) Syntax() Syntax {
+	return Syntax{BackslashEscapes: true, Backticks: true, HashComments: true}
+}
+
+// TLS config is set directly, named configs registered in the driver would never be removed
+func (mysqlDialect) Connector(dsn string, connInfo *DbConnInfo) (driver.Connector, error) {
+
+	config, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	tlsConf, err := tlsConfig(connInfo)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConf != nil {
+		config.TLS = tlsConf
+	}
+	return mysql.NewConnector(config)
+
+}
+
+// INOUT and OUT parameters are passed in session variables, read after the result sets
+func (d mysqlDialect) CallProcedure(ctx context.Context, conn *sql.Conn, call *ProcedureCall) (*sql.Rows, procedureOutputs, error) {
+
+	name, err := quoteQualified(d, call.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var args []any
+	var variables []string
+	list := make([]string, len(call.Params))
+	for i, p := range call.Params {
+		v, err := p.inValue()
+		if err != nil {
+			return nil, nil, err
+		}
+		if p.Direction == ParamIn {
+			args = append(args, v)
+			list[i] = "?"
+			continue
+		}
+		// Set for OUT ones too, the connection may keep values of previous calls
+		variable := "@sqlproxy_p" + strconv.Itoa(i+1)
+		if _, err = conn.ExecContext(ctx, "SET "+variable+" = ?", v); err != nil {
+			return nil, nil, err
+		}
+		variables = append(variables, variable)
+		list[i] = variable
+	}
+
+	rows, err := conn.QueryContext(ctx, "CALL "+name+"("+strings.Join(list, ", ")+")", args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outputs := func() (map[string]any, any, error) {
+		if len(variables) == 0 {
+			return map[string]any{}, nil, nil
+		}
+		rows, err := conn.QueryContext(ctx, "SELECT "+strings.Join(variables, ", "))
+		if err != nil {
+			return nil, nil, err
+		}
+		values, err := scanOutputs(rows, call.Params)
+		return values, nil, err
+	}
+	return rows, outputs, nil
+
+}
+
+// Catalog queries, databases and schemas are the same in MySQL
+var mysqlSchemaQueries = map[string]string{
+	"databases": `SELECT SCHEMA_NAME AS name FROM information_schema.SCHEMATA`,
+	"schemas":   `SELECT SCHEMA_NAME AS name FROM information_schema.SCHEMATA`,
+	"tables": `SELECT TABLE_SCHEMA AS schema_name, TABLE_NAME AS table_name,
+		CASE TABLE_TYPE WHEN 'VIEW' THEN 'view' ELSE 'table' END AS table_type
+		FROM information_schema.TABLES`,
+	"columns": `SELECT TABLE_SCHEMA AS schema_name, TABLE_NAME AS table_name, COLUMN_NAME AS column_name,
+		ORDINAL_POSITION AS position, DATA_TYPE AS data_type, CHARACTER_MAXIMUM_LENGTH AS max_length,
+		NUMERIC_PRECISION AS numeric_precision, NUMERIC_SCALE AS numeric_scale,
+		IS_NULLABLE AS is_nullable, COLUMN_DEFAULT AS column_default,
+		CASE WHEN EXTRA LIKE '%auto_increment%' THEN 1 ELSE 0 END AS is_identity
+		FROM information_schema.COLUMNS`,
+	"keys": `SELECT TABLE_SCHEMA AS schema_name, TABLE_NAME AS table_name, CONSTRAINT_NAME AS constraint_name,
+		CASE WHEN CONSTRAINT_NAME = 'PRIMARY' THEN 'primary' ELSE 'foreign' END AS key_type,
+		COLUMN_NAME AS column_name, ORDINAL_POSITION AS position,
+		REFERENCED_TABLE_SCHEMA AS ref_schema, REFERENCED_TABLE_NAME AS ref_table, REFERENCED_COLUMN_NAME AS ref_column
+		FROM information_schema.KEY_COLUMN_USAGE
+		WHERE CONSTRAINT_NAME = 'PRIMARY' OR REFERENCED_TABLE_NAME IS NOT NULL`,
+	"indexes": `SELECT TABLE_SCHEMA AS schema_name, TABLE_NAME AS table_name, INDEX_NAME AS index_name,
+		CASE WHEN NON_UNIQUE = 0 THEN 1 ELSE 0 END AS is_unique,
+		CASE WHEN INDEX_NAME = 'PRIMARY' THEN 1 ELSE 0 END AS is_primary,
+		COLUMN_NAME AS column_name, SEQ_IN_INDEX AS position
+		FROM information_schema.STATISTICS`,
+	"procedures": `SELECT ROUTINE_SCHEMA AS schema_name, ROUTINE_NAME AS routine_name, LOWER(ROUTINE_TYPE) AS routine_type
+		FROM information_schema.ROUTINES`,
+}
+
+func (mysqlDialect) SchemaQuery(object string) string {
+	return mysqlSchemaQueries[object]
+}
+
+// EXPLAIN FORMAT=JSON. EXPLAIN ANALYZE gives a text tree only, it is not supported
+func (mysqlDialect) Explain(ctx context.Context, conn *sql.Conn, query string, analyze bool) (*Plan, error) {
+
+	if analyze {
+		return nil, fmt.Errorf("%w with analyze for mysql", ErrExplainNotSupported)
+	}
+	var raw string
+	if err := conn.QueryRowContext(ctx, "EXPLAIN FORMAT=JSON "+query).Scan(&raw); err != nil {
+		return nil, err
+	}
+	return parseMysqlPlan(raw)
+
+}
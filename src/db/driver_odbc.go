@@ -0,0 +1,11 @@
+//go:build odbc
+// +build odbc
+
+package db
+
+// Requires cgo and unixODBC development files (unixodbc-dev)
+import _ "github.com/alexbrainman/odbc"
+
+func init() {
+	RegisterDialect("odbc", odbcDialect{})
+}
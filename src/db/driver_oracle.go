@@ -0,0 +1,10 @@
+//go:build oracle
+// +build oracle
+
+package db
+
+import _ "github.com/sijms/go-ora/v2"
+
+func init() {
+	RegisterDialect("oracle", oracleDialect{})
+}
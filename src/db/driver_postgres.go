@@ -4,3 +4,7 @@
 package db
 
 import _ "github.com/lib/pq"
+
+func init() {
+	RegisterDialect("postgres", postgresDialect{})
+}
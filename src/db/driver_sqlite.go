@@ -0,0 +1,19 @@
+//go:build sqlite
+// +build sqlite
+
+package db
+
+import (
+	"database/sql/driver"
+
+	"sql-proxy/src/sqlitedb"
+)
+
+func init() {
+	RegisterDialect("sqlite", sqliteDialect{})
+}
+
+// ATTACH is disabled, it would open files outside of the allowed directories
+func (sqliteDialect) Connector(dsn string, connInfo *DbConnInfo) (driver.Connector, error) {
+	return sqlitedb.NewConnector(dsn), nil
+}
@@ -3,4 +3,124 @@
 
 package db
 
-import _ "github.com/denisenkom/go-mssqldb"
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/denisenkom/go-mssqldb/msdsn"
+)
+
+func init() {
+	RegisterDialect("sqlserver", sqlserverDialect{})
+}
+
+// The tls block is applied as TLS config, the driver reads the CA from files only
+func (sqlserverDialect) Connector(dsn string, connInfo *DbConnInfo) (driver.Connector, error) {
+
+	config, _, err := msdsn.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if connInfo.TLS != nil {
+		tlsConf, err := tlsConfig(connInfo)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConf != nil {
+			config.TLSConfig = tlsConf
+			config.HostInCertificateProvided = connInfo.TLS.ServerName != ""
+		}
+	}
+	return mssql.NewConnectorConfig(config), nil
+
+}
+
+// Procedure name is sent as the query, the driver makes an RPC call with named parameters
+func (d sqlserverDialect) CallProcedure(ctx context.Context, conn *sql.Conn, call *ProcedureCall) (*sql.Rows, procedureOutputs, error) {
+
+	name, err := quoteQualified(d, call.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var args []any
+	dests := make([]any, len(call.Params))
+	for i, p := range call.Params {
+		if p.Name == "" {
+			return nil, nil, fmt.Errorf("%w: parameters of SQL Server procedures must have names", ErrInvalidParam)
+		}
+		v, err := p.inValue()
+		if err != nil {
+			return nil, nil, err
+		}
+		paramName := strings.TrimPrefix(p.Name, "@")
+		if p.Direction == ParamIn {
+			args = append(args, sql.Named(paramName, v))
+			continue
+		}
+		if dests[i], err = sqlserverOutDest(&p, v); err != nil {
+			return nil, nil, err
+		}
+		args = append(args, sql.Named(paramName, sql.Out{Dest: dests[i]}))
+	}
+
+	var status mssql.ReturnStatus
+	rows, err := conn.QueryContext(ctx, name, append(args, &status)...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Output values are set when all the results are read
+	outputs := func() (map[string]any, any, error) {
+		values := map[string]any{}
+		for i, p := range call.Params {
+			switch dest := dests[i].(type) {
+			case nil:
+			case *mssql.NVarCharMax:
+				values[p.Name] = p.outValue(string(*dest))
+			default:
+				values[p.Name] = p.outValue(dest)
+			}
+		}
+		return values, int64(status), nil
+	}
+	return rows, outputs, nil
+
+}
+
+// Typed output destination, the driver declares the parameter by it. Text of OUT
+// parameters is limited to 4000 characters, INOUT ones are nvarchar(max) and can't return NULL
+func sqlserverOutDest(p *ProcedureParam, v any) (any, error) {
+
+	switch p.Type {
+	case "int":
+		i, ok := v.(int64)
+		return &sql.NullInt64{Int64: i, Valid: ok}, nil
+	case "float":
+		f, ok := v.(float64)
+		return &sql.NullFloat64{Float64: f, Valid: ok}, nil
+	case "bool":
+		b, ok := v.(bool)
+		return &sql.NullBool{Bool: b, Valid: ok}, nil
+	case "binary":
+		return nil, fmt.Errorf("%w: binary output parameters are not supported for SQL Server", ErrInvalidParam)
+	}
+
+	// Text, decimals and dates are converted by the server
+	switch v := v.(type) {
+	case nil:
+		return &sql.NullString{}, nil
+	case time.Time:
+		text := mssql.NVarCharMax(v.Format("2006-01-02T15:04:05.9999999"))
+		return &text, nil
+	default:
+		text := mssql.NVarCharMax(fmt.Sprint(v))
+		return &text, nil
+	}
+
+}
@@ -0,0 +1,394 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrExplainNotSupported = errors.New("Query plans are not supported")
+
+// Query plan in the same shape for all servers
+type Plan struct {
+	DbType          string    `json:"db_type"`
+	Analyzed        bool      `json:"analyzed"` // the query was executed, actual values are given
+	PlanningTimeMs  *float64  `json:"planning_time_ms"`
+	ExecutionTimeMs *float64  `json:"execution_time_ms"`
+	Root            *PlanNode `json:"root"`
+	Raw             string    `json:"raw"` // plan as returned by the server, JSON or XML
+}
+
+// Plan operation. Costs are in server units, actual values are totals of all loops
+type PlanNode struct {
+	NodeType      string         `json:"node_type"`
+	Relation      string         `json:"relation,omitempty"` // table, view or function
+	EstimatedRows *float64       `json:"estimated_rows"`
+	EstimatedCost *float64       `json:"estimated_cost"` // with the child nodes
+	ActualRows    *float64       `json:"actual_rows"`
+	ActualTimeMs  *float64       `json:"actual_time_ms"`
+	Loops         *float64       `json:"loops"`
+	Details       map[string]any `json:"details,omitempty"` // server specific attributes
+	Children      []*PlanNode    `json:"children"`
+}
+
+// Dialects returning query plans. The plan is taken on a single connection,
+// as it may be switched by session settings
+type explainDialect interface {
+	Explain(ctx context.Context, conn *sql.Conn, query string, analyze bool) (*Plan, error)
+}
+
+// Returns the query plan. With analyze the query is executed
+// in a transaction which is rolled back
+func Explain(ctx context.Context, dbConn *DbConn, query string, analyze bool) (*Plan, error) {
+
+	ed, ok := dbConn.Dialect.(explainDialect)
+	if !ok {
+		return nil, fmt.Errorf("%w for %s", ErrExplainNotSupported, dbConn.Info.DbType)
+	}
+
+	conn, err := dbConn.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	plan, err := ed.Explain(ctx, conn, query, analyze)
+	if err != nil {
+		return nil, err
+	}
+	plan.DbType = dbConn.Info.DbType
+	plan.Analyzed = analyze
+	return plan, nil
+
+}
+
+// Runs the plan query in a transaction rolled back afterwards
+func explainInTx(ctx context.Context, conn *sql.Conn, explain func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return explain(tx)
+}
+
+// Closes the connection instead of returning it to the pool, when
+// a session setting could not be reset
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(any) error { return driver.ErrBadConn })
+}
+
+// Numbers are given as JSON numbers or strings
+func planFloat(v any) *float64 {
+	var f float64
+	switch x := v.(type) {
+	case float64:
+		f = x
+	case json.Number:
+		parsed, err := x.Float64()
+		if err != nil {
+			return nil
+		}
+		f = parsed
+	case string:
+		parsed, err := strconv.ParseFloat(x, 64)
+		if err != nil {
+			return nil
+		}
+		f = parsed
+	default:
+		return nil
+	}
+	return &f
+}
+
+func planProduct(a, b *float64) *float64 {
+	if a == nil || b == nil {
+		return a
+	}
+	v := *a * *b
+	return &v
+}
+
+// Postgres EXPLAIN (FORMAT JSON): [{"Plan": {...}, "Planning Time": 0.1, "Execution Time": 2}]
+func parsePostgresPlan(raw string) (*Plan, error) {
+
+	var result []map[string]any
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		return nil, fmt.Errorf("invalid plan: %w", err)
+	}
+	if len(result) == 0 {
+		return nil, errors.New("invalid plan: empty result")
+	}
+	root, ok := result[0]["Plan"].(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid plan: no Plan node")
+	}
+	return &Plan{
+		PlanningTimeMs:  planFloat(result[0]["Planning Time"]),
+		ExecutionTimeMs: planFloat(result[0]["Execution Time"]),
+		Root:            postgresPlanNode(root),
+		Raw:             raw,
+	}, nil
+
+}
+
+var postgresNodeKeys = []string{
+	"Node Type", "Relation Name", "CTE Name", "Function Name", "Plan Rows", "Total Cost",
+	"Actual Rows", "Actual Total Time", "Actual Loops", "Plans",
+}
+
+// Actual rows and time of Postgres are per loop
+func postgresPlanNode(obj map[string]any) *PlanNode {
+
+	node := &PlanNode{
+		NodeType:      fmt.Sprint(obj["Node Type"]),
+		EstimatedRows: planFloat(obj["Plan Rows"]),
+		EstimatedCost: planFloat(obj["Total Cost"]),
+		Loops:         planFloat(obj["Actual Loops"]),
+		Details:       map[string]any{},
+		Children:      []*PlanNode{},
+	}
+	node.ActualRows = planProduct(planFloat(obj["Actual Rows"]), node.Loops)
+	node.ActualTimeMs = planProduct(planFloat(obj["Actual Total Time"]), node.Loops)
+	for _, key := range []string{"Relation Name", "CTE Name", "Function Name"} {
+		if name, ok := obj[key].(string); ok {
+			node.Relation = name
+			break
+		}
+	}
+
+	for key, v := range obj {
+		if !slices.Contains(postgresNodeKeys, key) {
+			node.Details[key] = v
+		}
+	}
+	if plans, ok := obj["Plans"].([]any); ok {
+		for _, p := range plans {
+			if child, ok := p.(map[string]any); ok {
+				node.Children = append(node.Children, postgresPlanNode(child))
+			}
+		}
+	}
+	return node
+
+}
+
+// MySQL access types in plain words
+var mysqlAccessTypes = map[string]string{
+	"ALL":         "Full Table Scan",
+	"index":       "Full Index Scan",
+	"range":       "Index Range Scan",
+	"ref":         "Index Lookup",
+	"eq_ref":      "Unique Index Lookup",
+	"ref_or_null": "Index Lookup",
+	"const":       "Constant Row",
+	"system":      "Constant Row",
+	"fulltext":    "Fulltext Index",
+}
+
+// MySQL EXPLAIN FORMAT=JSON: {"query_block": {...}}. Objects become nodes named
+// by their key (ordering_operation, nested_loop...), tables are leaf nodes
+func parseMysqlPlan(raw string) (*Plan, error) {
+
+	var result map[string]any
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		return nil, fmt.Errorf("invalid plan: %w", err)
+	}
+	block, ok := result["query_block"].(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid plan: no query_block")
+	}
+	return &Plan{Root: mysqlPlanNode("query_block", block), Raw: raw}, nil
+
+}
+
+func mysqlPlanNode(key string, obj map[string]any) *PlanNode {
+
+	node := &PlanNode{NodeType: key, Details: map[string]any{}, Children: []*PlanNode{}}
+	if key == "table" {
+		access := fmt.Sprint(obj["access_type"])
+		node.NodeType = cmp.Or(mysqlAccessTypes[access], access)
+		node.Relation, _ = obj["table_name"].(string)
+		node.EstimatedRows = planFloat(obj["rows_produced_per_join"])
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(obj)) {
+		switch v := obj[k].(type) {
+		case map[string]any:
+			if k == "cost_info" {
+				node.EstimatedCost = planFloat(cmp.Or(v["query_cost"], v["prefix_cost"]))
+				continue
+			}
+			node.Children = append(node.Children, mysqlPlanNode(k, v))
+		case []any:
+			if !slices.ContainsFunc(v, func(e any) bool { _, ok := e.(map[string]any); return ok }) {
+				node.Details[k] = v
+				continue
+			}
+			// nested_loop, query_specifications and subqueries: lists of tables or query blocks
+			list := &PlanNode{NodeType: k, Details: map[string]any{}, Children: []*PlanNode{}}
+			for _, e := range v {
+				if elem, ok := e.(map[string]any); ok {
+					list.Children = append(list.Children, mysqlListElement(elem))
+				}
+			}
+			node.Children = append(node.Children, list)
+		default:
+			if k != "table_name" {
+				node.Details[k] = v
+			}
+		}
+	}
+	return node
+
+}
+
+// Element with a single table or query block, other values are its details
+func mysqlListElement(elem map[string]any) *PlanNode {
+	for _, key := range []string{"table", "query_block"} {
+		if inner, ok := elem[key].(map[string]any); ok {
+			node := mysqlPlanNode(key, inner)
+			for k, v := range elem {
+				if k != key {
+					node.Details[k] = v
+				}
+			}
+			return node
+		}
+	}
+	return mysqlPlanNode("item", elem)
+}
+
+// Element of the SQL Server showplan XML with its attributes and children
+type showplanElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr        `xml:",any,attr"`
+	Children []showplanElement `xml:",any"`
+}
+
+func (e *showplanElement) attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Descendants named name, not looking into nested operators (RelOp)
+func (e *showplanElement) find(name string, found []*showplanElement) []*showplanElement {
+	for i := range e.Children {
+		child := &e.Children[i]
+		switch {
+		case child.XMLName.Local == name:
+			found = append(found, child)
+		case child.XMLName.Local != "RelOp":
+			found = child.find(name, found)
+		}
+	}
+	return found
+}
+
+// SQL Server showplan XML documents, one per batch or statement. Statements
+// are children of the root node if there are several
+func parseSqlserverPlan(docs []string) (*Plan, error) {
+
+	var statements []*PlanNode
+	for _, raw := range docs {
+		// Text is decoded by the driver, the declared utf-16 is ignored
+		decoder := xml.NewDecoder(strings.NewReader(raw))
+		decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+		var doc showplanElement
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("invalid plan: %w", err)
+		}
+		for _, stmt := range doc.find("StmtSimple", nil) {
+			node := &PlanNode{
+				NodeType:      cmp.Or(stmt.attr("StatementType"), "Statement"),
+				EstimatedRows: planFloat(stmt.attr("StatementEstRows")),
+				EstimatedCost: planFloat(stmt.attr("StatementSubTreeCost")),
+				Details:       map[string]any{"StatementText": stmt.attr("StatementText")},
+				Children:      []*PlanNode{},
+			}
+			for _, plan := range stmt.find("QueryPlan", nil) {
+				for _, relOp := range plan.find("RelOp", nil) {
+					node.Children = append(node.Children, sqlserverPlanNode(relOp))
+				}
+			}
+			statements = append(statements, node)
+		}
+	}
+
+	plan := &Plan{Raw: strings.Join(docs, "\n")}
+	switch len(statements) {
+	case 0:
+		return nil, errors.New("invalid plan: no statements")
+	case 1:
+		plan.Root = statements[0]
+	default:
+		plan.Root = &PlanNode{NodeType: "Batch", Details: map[string]any{}, Children: statements}
+	}
+	return plan, nil
+
+}
+
+var sqlserverNodeAttrs = []string{"PhysicalOp", "EstimateRows", "EstimatedTotalSubtreeCost"}
+
+// Actual values are summed over threads, the elapsed time is the longest one
+func sqlserverPlanNode(relOp *showplanElement) *PlanNode {
+
+	node := &PlanNode{
+		NodeType:      relOp.attr("PhysicalOp"),
+		EstimatedRows: planFloat(relOp.attr("EstimateRows")),
+		EstimatedCost: planFloat(relOp.attr("EstimatedTotalSubtreeCost")),
+		Details:       map[string]any{},
+		Children:      []*PlanNode{},
+	}
+	for _, a := range relOp.Attrs {
+		if !slices.Contains(sqlserverNodeAttrs, a.Name.Local) {
+			node.Details[a.Name.Local] = a.Value
+		}
+	}
+	if objects := relOp.find("Object", nil); len(objects) > 0 {
+		trim := func(s string) string { return strings.Trim(s, "[]") }
+		node.Relation = trim(objects[0].attr("Table"))
+		if index := objects[0].attr("Index"); index != "" {
+			node.Details["Index"] = trim(index)
+		}
+	}
+
+	for _, counters := range relOp.find("RunTimeCountersPerThread", nil) {
+		add := func(total **float64, v *float64) {
+			if v != nil {
+				if *total == nil {
+					*total = new(float64)
+				}
+				**total += *v
+			}
+		}
+		add(&node.ActualRows, planFloat(counters.attr("ActualRows")))
+		add(&node.Loops, planFloat(counters.attr("ActualExecutions")))
+		if elapsed := planFloat(counters.attr("ActualElapsedms")); elapsed != nil {
+			if node.ActualTimeMs == nil || *elapsed > *node.ActualTimeMs {
+				node.ActualTimeMs = elapsed
+			}
+		}
+	}
+
+	for _, child := range relOp.find("RelOp", nil) {
+		node.Children = append(node.Children, sqlserverPlanNode(child))
+	}
+	return node
+
+}
@@ -0,0 +1,22 @@
+package db
+
+import "sql-proxy/src/app"
+
+type filesDialect struct{ baseDialect }
+
+func (filesDialect) DriverName() string {
+	return "files"
+}
+
+// Directory of CSV, TSV and XLSX files loaded into an in-memory database
+func (filesDialect) DSN(connInfo *DbConnInfo) (string, error) {
+	err := rejectOptions(connInfo, optionTLS, optionConnectTimeout, optionReadTimeout, optionApplicationName, optionOptions)
+	if err != nil {
+		return "", err
+	}
+	return allowedDir(app.GetConfig().Files.AllowedDirs, connInfo.DbName)
+}
+
+func (filesDialect) Capabilities() Capabilities {
+	return Capabilities{ReadOnly: true}
+}
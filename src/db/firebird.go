@@ -0,0 +1,56 @@
+package db
+
+import (
+	"cmp"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const firebirdDefaultPort = 3050
+
+type firebirdDialect struct{ baseDialect }
+
+func (firebirdDialect) DriverName() string {
+	return "firebirdsql"
+}
+
+// Firebird connection string. db_name is the database file path or alias on
+// the server, charset is UTF8 unless set, e.g. WIN1251 for legacy databases
+func (firebirdDialect) DSN(connInfo *DbConnInfo) (string, error) {
+
+	err := rejectOptions(connInfo, optionTLS, optionConnectTimeout, optionReadTimeout, optionApplicationName)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	for key, value := range connInfo.Options {
+		query.Set(key, value)
+	}
+	query.Set("charset", cmp.Or(strings.ToUpper(connInfo.Charset), "UTF8"))
+	if connInfo.Role != "" {
+		query.Set("role", connInfo.Role)
+	}
+
+	port := cmp.Or(connInfo.Port, firebirdDefaultPort)
+	dsn := url.URL{
+		User:     url.UserPassword(connInfo.User, connInfo.Password),
+		Host:     net.JoinHostPort(connInfo.Host, strconv.Itoa(int(port))),
+		Path:     "/" + strings.TrimPrefix(strings.ReplaceAll(connInfo.DbName, "\\", "/"), "/"),
+		RawQuery: query.Encode(),
+	}
+
+	// The driver expects no scheme
+	return strings.TrimPrefix(dsn.String(), "//"), nil
+
+}
+
+func (firebirdDialect) HealthQuery() string {
+	return "SELECT 1 FROM RDB$DATABASE"
+}
+
+func (firebirdDialect) Capabilities() Capabilities {
+	return Capabilities{TextBlobs: true}
+}
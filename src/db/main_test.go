@@ -0,0 +1,16 @@
+package db
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"sql-proxy/src/app"
+)
+
+func TestMain(m *testing.M) {
+	logger := app.NewConsoleLogger()
+	logger.Logger.SetOutput(io.Discard)
+	app.InitLogger(logger)
+	os.Exit(m.Run())
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"sql-proxy/src/app"
+	"sync"
+	"time"
+)
+
+// Max number of concurrent health checks
+const maxConcurrentPings = 16
+
+// Maintenance task result for a single pool
+type poolCheck struct {
+	conn      *DbConn
+	pool      app.PoolConfig
+	dead      bool
+	idle      bool
+	lostStmts []*DbStmt
+}
+
+func (o *DbList) RunMaintenance() {
+
+	for {
+		<-time.After(app.GetConfig().Maintenance.Interval)
+
+		start := time.Now()
+		o.maintain()
+		metricMaintenanceDuration.Observe(time.Since(start).Seconds())
+	}
+}
+
+// Checks pools outside the list lock, so a server which does not respond
+// does not block requests. Pools and statements used by requests are not
+// closed until released
+func (o *DbList) maintain() {
+	// Settings may be changed by config reload
+	cfg := app.GetConfig()
+
+	// Take a snapshot
+	conns := o.List()
+	checks := make([]*poolCheck, 0, len(conns))
+	for _, dbConn := range conns {
+		pool := cfg.PoolFor(dbConn.Profile).Clamp(dbConn.Pool)
+		check := &poolCheck{conn: dbConn, pool: pool}
+
+		// connection not used for a long time
+		check.idle = dbConn.idle(pool.IdleTimeout)
+
+		// prepared statements not used for a long time
+		for _, stmt := range dbConn.Statements() {
+			if stmt.idle(pool.StmtIdleTimeout) {
+				check.lostStmts = append(check.lostStmts, stmt)
+			}
+		}
+
+		applyPoolConfig(dbConn.DB, pool)
+		checks = append(checks, check)
+	}
+
+	// Detect dead connections concurrently
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentPings)
+	for _, check := range checks {
+		if check.idle {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(check *poolCheck) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.Maintenance.PingTimeout)
+			defer cancel()
+			check.dead = healthCheck(ctx, check.conn.DB, check.conn.Dialect) != nil
+		}(check)
+	}
+	wg.Wait()
+
+	// Remove dead and idle connections and lost statements,
+	// in use items are closed on release
+	var countConn, countStmt, countEvicted, countEvictedStmt int
+	for _, check := range checks {
+		dbConn := check.conn
+
+		switch {
+		case check.dead:
+			if o.remove(dbConn) {
+				countEvicted++
+				metricPoolsEvicted.WithLabelValues("dead").Inc()
+			}
+			continue
+		case check.idle:
+			// The connection may be used again during the pings
+			if o.removeIf(dbConn, func() bool { return dbConn.idle(check.pool.IdleTimeout) }) {
+				countEvicted++
+				metricPoolsEvicted.WithLabelValues("idle").Inc()
+				continue
+			}
+		}
+
+		for _, lost := range check.lostStmts {
+			if o.removeStatementIf(lost, func() bool { return lost.idle(check.pool.StmtIdleTimeout) }) {
+				countEvictedStmt++
+			}
+		}
+
+		countConn++
+		countStmt += len(dbConn.Statements())
+	}
+
+	metricPools.Set(float64(countConn))
+	metricStatements.Set(float64(countStmt))
+	metricStatementsEvicted.Add(float64(countEvictedStmt))
+
+	app.Logger.Debugf("Regular task: pool size = %d, %d connections and %d prepared statements removed",
+		countConn, countEvicted, countEvictedStmt)
+}
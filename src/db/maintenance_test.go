@@ -0,0 +1,70 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// Pools and statements used while the other pools are pinged are kept
+func TestMaintainUsedDuringCheck(t *testing.T) {
+
+	list := &DbList{}
+	list.Init()
+	open := func(name string) *DbConn {
+		id, err := list.GetByParams(&DbConnInfo{DbType: "stub", Host: "db", DbName: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { list.Delete(id) })
+		dbConn, _ := list.Get(id)
+		return dbConn
+	}
+	prepare := func(dbConn *DbConn) *DbStmt {
+		stmt, err := dbConn.DB.Prepare("SELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stmtId, _ := list.PutPreparedStatement(dbConn.Id, stmt, "SELECT 1")
+		dbStmt, _ := dbConn.findStatement(stmtId)
+		return dbStmt
+	}
+	old := time.Now().Add(-24 * time.Hour).UnixNano()
+
+	// Idle pool and statement used by a request during the check
+	used, active, unused := open("used"), open("active"), open("unused")
+	usedStmt, unusedStmt := prepare(active), prepare(active)
+	used.lastUse.Store(old)
+	unused.lastUse.Store(old)
+	usedStmt.lastUse.Store(old)
+	unusedStmt.lastUse.Store(old)
+
+	ping := func(name string) {
+		if name != "active" {
+			return
+		}
+		if dbConn, ok := list.Acquire(used.Id); ok {
+			dbConn.Release()
+		}
+		if dbStmt, ok := list.AcquirePreparedStatement(active.Id, usedStmt.Id); ok {
+			dbStmt.Release()
+		}
+	}
+	stubPing.Store(&ping)
+	defer stubPing.Store(nil)
+
+	list.maintain()
+
+	if _, ok := list.Get(used.Id); !ok {
+		t.Error("pool used during the check is removed")
+	}
+	if _, ok := list.Get(unused.Id); ok {
+		t.Error("idle pool is kept")
+	}
+	if _, ok := active.findStatement(usedStmt.Id); !ok {
+		t.Error("statement used during the check is removed")
+	}
+	if _, ok := active.findStatement(unusedStmt.Id); ok {
+		t.Error("idle statement is kept")
+	}
+
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics of the connection pool, updated by the maintenance task
+var (
+	metricPools = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sqlproxy_pools",
+		Help: "SQL connection pools open",
+	})
+	metricStatements = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sqlproxy_prepared_statements",
+		Help: "Prepared statements open",
+	})
+	metricPoolsEvicted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqlproxy_pools_evicted_total",
+		Help: "SQL connection pools removed by the maintenance task",
+	}, []string{"reason"})
+	metricStatementsEvicted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sqlproxy_prepared_statements_evicted_total",
+		Help: "Prepared statements removed by the maintenance task",
+	})
+	metricMaintenanceDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sqlproxy_maintenance_duration_seconds",
+		Help:    "Duration of the maintenance task run",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+	})
+)
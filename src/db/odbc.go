@@ -0,0 +1,83 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"sql-proxy/src/app"
+)
+
+var ErrOdbcNotAllowed = errors.New("ODBC data source or driver is not allowed")
+
+type odbcDialect struct{ baseDialect }
+
+func (odbcDialect) DriverName() string {
+	return "odbc"
+}
+
+// ODBC connection string, by data source name configured in odbc.ini
+// or by driver name from odbcinst.ini. Both must be allowed in the config,
+// as ODBC drivers may open local files
+func (odbcDialect) DSN(connInfo *DbConnInfo) (string, error) {
+
+	err := rejectOptions(connInfo, optionTLS, optionConnectTimeout, optionReadTimeout, optionApplicationName)
+	if err != nil {
+		return "", err
+	}
+
+	cfg := app.GetConfig().ODBC
+	var attrs []string
+
+	switch {
+	case connInfo.DSN != "":
+		if !slices.Contains(cfg.AllowedDSNs, connInfo.DSN) {
+			return "", ErrOdbcNotAllowed
+		}
+		attrs = append(attrs, "DSN="+odbcValue(connInfo.DSN))
+	case connInfo.Driver != "":
+		if !slices.Contains(cfg.AllowedDrivers, connInfo.Driver) {
+			return "", ErrOdbcNotAllowed
+		}
+		attrs = append(attrs, "DRIVER={"+strings.ReplaceAll(connInfo.Driver, "}", "}}")+"}")
+		if connInfo.Host != "" {
+			attrs = append(attrs, "SERVER="+odbcValue(connInfo.Host))
+		}
+		if connInfo.Port != 0 {
+			attrs = append(attrs, fmt.Sprintf("PORT=%d", connInfo.Port))
+		}
+		if connInfo.DbName != "" {
+			attrs = append(attrs, "DATABASE="+odbcValue(connInfo.DbName))
+		}
+	default:
+		return "", errors.New("ODBC data source or driver name is required")
+	}
+
+	if connInfo.User != "" {
+		attrs = append(attrs, "UID="+odbcValue(connInfo.User))
+	}
+	if connInfo.Password != "" {
+		attrs = append(attrs, "PWD="+odbcValue(connInfo.Password))
+	}
+	for _, key := range slices.Sorted(maps.Keys(connInfo.Options)) {
+		attrs = append(attrs, key+"="+odbcValue(connInfo.Options[key]))
+	}
+
+	return strings.Join(attrs, ";"), nil
+
+}
+
+// SQL of the data source is not known
+func (odbcDialect) HealthQuery() string {
+	return ""
+}
+
+// Values with special characters are enclosed in braces
+func odbcValue(v string) string {
+	if !strings.ContainsAny(v, ";{}= ") {
+		return v
+	}
+	return "{" + strings.ReplaceAll(v, "}", "}}") + "}"
+}
@@ -0,0 +1,143 @@
+//go:build odbc
+// +build odbc
+
+package db
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"sql-proxy/src/app"
+)
+
+// Driver name of libsqlite3odbc in odbcinst.ini
+const sqliteOdbcDriver = "SQLite3"
+
+func setupOdbc(t *testing.T) {
+
+	t.Setenv("CONFIG_FILE", "")
+	_, err := app.LoadConfig("", func(cfg *app.Config) {
+		cfg.ODBC.AllowedDSNs = []string{"erp"}
+		cfg.ODBC.AllowedDrivers = []string{sqliteOdbcDriver, "PostgreSQL Unicode"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		app.LoadConfig("", nil)
+	})
+
+}
+
+func TestOdbcDSN(t *testing.T) {
+
+	setupOdbc(t)
+
+	tests := []struct {
+		name string
+		info DbConnInfo
+		want string
+		err  error
+	}{
+		{name: "data source", info: DbConnInfo{DSN: "erp", User: "u", Password: "p;w"},
+			want: "DSN=erp;UID=u;PWD={p;w}"},
+		{name: "driver", info: DbConnInfo{Driver: "PostgreSQL Unicode", Host: "pg", Port: 5432, DbName: "db",
+			Options: map[string]string{"SSLMode": "require", "B": "{x}"}},
+			want: "DRIVER={PostgreSQL Unicode};SERVER=pg;PORT=5432;DATABASE=db;B={{x}}};SSLMode=require"},
+		{name: "data source not allowed", info: DbConnInfo{DSN: "other"}, err: ErrOdbcNotAllowed},
+		{name: "driver not allowed", info: DbConnInfo{Driver: "Microsoft Access Driver (*.mdb)"}, err: ErrOdbcNotAllowed},
+		{name: "driver name case", info: DbConnInfo{Driver: "sqlite3"}, err: ErrOdbcNotAllowed},
+		{name: "TLS", info: DbConnInfo{DSN: "erp", TLS: &app.TLSOptions{Mode: app.TLSRequire}}, err: ErrOptionNotSupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.info.DbType = "odbc"
+			got, err := odbcDialect{}.DSN(&tt.info)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+
+}
+
+// Query through the SQLite ODBC driver, skipped if unixODBC or libsqlite3odbc is not installed
+func TestOdbcQuery(t *testing.T) {
+
+	out, err := exec.Command("odbcinst", "-q", "-d").Output()
+	if err != nil {
+		t.Skip("unixODBC is not installed")
+	}
+	if !bytes.Contains(out, []byte("["+sqliteOdbcDriver+"]")) {
+		t.Skip("SQLite ODBC driver (libsqlite3odbc) is not installed")
+	}
+	setupOdbc(t)
+
+	list := &DbList{}
+	list.Init()
+	id, err := list.GetByParams(&DbConnInfo{DbType: "odbc", Driver: sqliteOdbcDriver, DbName: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer list.Delete(id)
+
+	dbConn, ok := list.Acquire(id)
+	if !ok {
+		t.Fatal("connection not found")
+	}
+	defer dbConn.Release()
+
+	_, err = dbConn.DB.Exec("CREATE TABLE items (id INTEGER, name TEXT, data BLOB)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dbConn.DB.Exec("INSERT INTO items VALUES (?, ?, ?)", 1, "Иван", []byte{0, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := dbConn.DB.Query("SELECT id, name, data FROM items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	decoders, err := ValueDecoders("odbc", rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoders) != 3 {
+		t.Fatalf("%d decoders, want 3", len(decoders))
+	}
+	if !rows.Next() {
+		t.Fatal("no rows")
+	}
+	var id64 int64
+	var name, data any
+	if err = rows.Scan(&id64, &name, &data); err != nil {
+		t.Fatal(err)
+	}
+	if id64 != 1 {
+		t.Errorf("id %d, want 1", id64)
+	}
+	if got := decoders[1](name); got != "Иван" {
+		t.Errorf("name %#v, want Иван", got)
+	}
+	if got := decoders[2](data); got != "\x00\x01\x02" {
+		t.Errorf("data %#v", got)
+	}
+
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"sql-proxy/src/app"
+)
+
+var (
+	ErrOptionNotSupported = errors.New("Connection option is not supported")
+	ErrInvalidTLS         = errors.New("Invalid TLS settings")
+)
+
+// Connection settings which may be not supported by a driver
+const (
+	optionTLS             = "tls"
+	optionConnectTimeout  = "connect_timeout"
+	optionReadTimeout     = "read_timeout"
+	optionApplicationName = "application_name"
+	optionOptions         = "options"
+)
+
+// Rejects the settings the driver can't apply, instead of ignoring them silently
+func rejectOptions(connInfo *DbConnInfo, names ...string) error {
+
+	for _, name := range names {
+		var set bool
+		switch name {
+		case optionTLS:
+			set = connInfo.TLS != nil && connInfo.TLS.Mode != "" && connInfo.TLS.Mode != app.TLSDisable
+		case optionConnectTimeout:
+			set = connInfo.ConnectTimeout > 0
+		case optionReadTimeout:
+			set = connInfo.ReadTimeout > 0
+		case optionApplicationName:
+			set = connInfo.ApplicationName != ""
+		case optionOptions:
+			set = len(connInfo.Options) > 0
+		}
+		if set {
+			return notSupported(connInfo, name)
+		}
+	}
+	return nil
+
+}
+
+func notSupported(connInfo *DbConnInfo, name string) error {
+	return fmt.Errorf("%w: %s for %s", ErrOptionNotSupported, name, connInfo.DbType)
+}
+
+// Modes of the ssl flag other than require. ClickHouse and Oracle drivers
+// verified the server certificate with it before the tls block was added
+var sslFlagModes = map[string]string{
+	"clickhouse": app.TLSVerifyFull,
+	"oracle":     app.TLSVerifyFull,
+}
+
+// TLS mode of the tls block, or the mode of the ssl flag.
+// Empty if not set, so the driver default is kept
+func tlsMode(connInfo *DbConnInfo) string {
+	switch {
+	case connInfo.TLS != nil && connInfo.TLS.Mode != "":
+		return connInfo.TLS.Mode
+	case connInfo.SSL:
+		return cmp.Or(sslFlagModes[connInfo.DbType], app.TLSRequire)
+	}
+	return ""
+}
+
+// Certificates and key in PEM, files are read for profiles
+func tlsPEM(options *app.TLSOptions) (ca, cert, key []byte, err error) {
+
+	read := func(text, file string) ([]byte, error) {
+		if file == "" {
+			return []byte(text), nil
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidTLS, err)
+		}
+		return data, nil
+	}
+
+	if ca, err = read(options.CA, options.CAFile); err != nil {
+		return nil, nil, nil, err
+	}
+	if cert, err = read(options.Cert, options.CertFile); err != nil {
+		return nil, nil, nil, err
+	}
+	if key, err = read(options.Key, options.KeyFile); err != nil {
+		return nil, nil, nil, err
+	}
+	return ca, cert, key, nil
+
+}
+
+// Go TLS config for the drivers accepting it, nil if TLS is not used
+func tlsConfig(connInfo *DbConnInfo) (*tls.Config, error) {
+
+	mode := tlsMode(connInfo)
+	if mode == "" || mode == app.TLSDisable {
+		return nil, nil
+	}
+
+	config := &tls.Config{ServerName: connInfo.Host}
+	if connInfo.TLS == nil {
+		config.InsecureSkipVerify = mode == app.TLSRequire
+		return config, nil
+	}
+
+	ca, cert, key, err := tlsPEM(connInfo.TLS)
+	if err != nil {
+		return nil, err
+	}
+	if len(ca) > 0 {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("%w: no certificates found in CA", ErrInvalidTLS)
+		}
+	}
+	if len(cert) > 0 {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidTLS, err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	if connInfo.TLS.ServerName != "" {
+		config.ServerName = connInfo.TLS.ServerName
+	}
+
+	switch mode {
+	case app.TLSRequire:
+		config.InsecureSkipVerify = true
+	case app.TLSVerifyCA:
+		// The chain is verified, but not the name
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = verifyChain(config.RootCAs)
+	}
+	return config, nil
+
+}
+
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+
+		if len(rawCerts) == 0 {
+			return errors.New("no server certificate")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+
+		options := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+		for _, cert := range certs[1:] {
+			options.Intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(options)
+		return err
+
+	}
+}
+
+// Whole seconds for drivers not accepting fractions, rounded up
+func seconds(d app.Duration) int {
+	return int(math.Ceil(time.Duration(d).Seconds()))
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"sql-proxy/src/app"
+)
+
+const oracleDefaultPort = 1521
+
+type oracleDialect struct{ baseDialect }
+
+func (oracleDialect) DriverName() string {
+	return "oracle"
+}
+
+// Oracle connection URL. The database is identified by service name, db_name
+// is used if it is not set, or by SID for old style configurations
+func (oracleDialect) DSN(connInfo *DbConnInfo) (string, error) {
+
+	query := url.Values{}
+	serviceName := cmp.Or(connInfo.ServiceName, connInfo.DbName)
+	if connInfo.SID != "" {
+		query.Set("SID", connInfo.SID)
+		serviceName = ""
+	}
+
+	// The driver takes certificates from a wallet only, it may be set by options
+	switch tlsMode(connInfo) {
+	case app.TLSRequire:
+		query.Set("SSL", "true")
+		query.Set("SSL VERIFY", "false")
+	case app.TLSVerifyCA, app.TLSVerifyFull:
+		query.Set("SSL", "true")
+		query.Set("SSL VERIFY", "true")
+	}
+	if tls := connInfo.TLS; tls != nil {
+		switch {
+		case tls.CA != "" || tls.CAFile != "":
+			return "", notSupported(connInfo, "tls.ca, use WALLET option")
+		case tls.Cert != "" || tls.CertFile != "":
+			return "", notSupported(connInfo, "tls.cert, use WALLET option")
+		case tls.ServerName != "":
+			return "", notSupported(connInfo, "tls.server_name")
+		}
+	}
+
+	// Single timeout for connecting and reading
+	if timeout := max(connInfo.ConnectTimeout, connInfo.ReadTimeout); timeout > 0 {
+		query.Set("CONNECTION TIMEOUT", strconv.Itoa(seconds(timeout)))
+	}
+	if connInfo.ApplicationName != "" {
+		query.Set("PROGRAM", connInfo.ApplicationName)
+	}
+	for key, value := range connInfo.Options {
+		query.Set(key, value)
+	}
+
+	port := cmp.Or(connInfo.Port, oracleDefaultPort)
+	dsn := url.URL{
+		Scheme:   "oracle",
+		User:     url.UserPassword(connInfo.User, connInfo.Password),
+		Host:     net.JoinHostPort(connInfo.Host, strconv.Itoa(int(port))),
+		Path:     "/" + serviceName,
+		RawQuery: query.Encode(),
+	}
+	return dsn.String(), nil
+
+}
+
+func (oracleDialect) Placeholder(n int) string {
+	return ":" + strconv.Itoa(n)
+}
+
+func (oracleDialect) Syntax() Syntax {
+	return Syntax{AltQuotes: true}
+}
+
+func (oracleDialect) HealthQuery() string {
+	return "SELECT 1 FROM DUAL"
+}
+
+// Column type name as reported by the driver
+func (oracleDialect) Decoder(columnType *sql.ColumnType) ValueDecoder {
+	return oracleDecoder(columnType.DatabaseTypeName())
+}
+
+// Oracle values by column type name, as reported by the driver
+func oracleDecoder(typeName string) ValueDecoder {
+
+	switch typeName {
+	case "NUMBER":
+		// Returned as decimal string to keep precision, written as JSON number
+		return func(v any) any {
+			if s, ok := v.(string); ok && isJsonNumber(s) {
+				return json.Number(s)
+			}
+			return v
+		}
+	case "DATE", "TIMESTAMP", "TimeStampDTY":
+		// No time zone in the database, local time written as is
+		return func(v any) any {
+			if t, ok := v.(time.Time); ok {
+				return t.Format("2006-01-02T15:04:05.999999999")
+			}
+			return v
+		}
+	case "OCIBlobLocator", "RAW", "LongRaw", "VarRaw", "LongVarRaw":
+		return func(v any) any {
+			if b, ok := v.([]byte); ok {
+				return base64.StdEncoding.EncodeToString(b)
+			}
+			return v
+		}
+	default:
+		// CLOB is read by the driver as string
+		return decodeDefault
+	}
+
+}
+
+func isJsonNumber(s string) bool {
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) {
+		return false
+	}
+	var n json.Number
+	return json.Unmarshal([]byte(s), &n) == nil
+}
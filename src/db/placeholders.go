@@ -0,0 +1,298 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrQuerySyntax = errors.New("SQL syntax error")
+
+type sqlTokenKind int
+
+const (
+	sqlSpace sqlTokenKind = iota
+	sqlComment
+	sqlString
+	sqlQuotedName
+	sqlWord
+	sqlNumber
+	sqlQuestion
+	sqlOther
+)
+
+// Keywords followed by values, ? after them is a parameter, not an operator
+var valueKeywords = []string{
+	"ALL", "AND", "ANY", "AS", "AT", "BETWEEN", "BY", "CASE", "DEFAULT", "DISTINCT", "ELSE", "ESCAPE",
+	"EXISTS", "FETCH", "FIRST", "FROM", "FOR", "HAVING", "ILIKE", "IN", "INTERVAL", "IS", "LIKE", "LIMIT",
+	"NEXT", "NOT", "OFFSET", "ON", "OR", "RETURN", "RETURNING", "ROWS", "SELECT", "SET", "SIMILAR", "SOME",
+	"THEN", "TO", "USING", "VALUES", "WHEN", "WHERE",
+}
+
+// Rewrites ? parameters into the placeholders of the dialect: $1, @p1, :1.
+// String literals, quoted names and comments are kept as is. For Postgres
+// ?, ?| and ?& JSON operators are kept too, ?? may be used for ? where it is ambiguous
+func TranslatePlaceholders(query string, dialect Dialect) (string, error) {
+
+	if dialect.Placeholder(1) == "?" {
+		return query, nil
+	}
+	syntax := dialect.Syntax()
+
+	var sb strings.Builder
+	sb.Grow(len(query) + 16)
+	n := 0
+	var prevKind sqlTokenKind = sqlOther
+	prevText := ""
+
+	for i := 0; i < len(query); {
+		end, kind, err := scanSqlToken(query, i, syntax)
+		if err != nil {
+			return "", err
+		}
+		text := query[i:end]
+
+		if kind == sqlQuestion {
+			switch {
+			case !syntax.QuestionOperators:
+				n++
+				text = dialect.Placeholder(n)
+			case strings.HasPrefix(query[end:], "?"):
+				// Escaped operator
+				end++
+			case strings.HasPrefix(query[end:], "||"):
+				// Parameter followed by concatenation
+				n++
+				text = dialect.Placeholder(n)
+			case strings.HasPrefix(query[end:], "|"), strings.HasPrefix(query[end:], "&"):
+				end++
+				text = query[i:end]
+			case isOperand(prevKind, prevText):
+				// JSON key exists operator
+			default:
+				n++
+				text = dialect.Placeholder(n)
+			}
+		}
+
+		sb.WriteString(text)
+		if kind != sqlSpace && kind != sqlComment {
+			prevKind, prevText = kind, query[i:end]
+		}
+		i = end
+	}
+
+	return sb.String(), nil
+
+}
+
+// Query text compared ignoring comments, spacing, trailing ; and the case of words.
+// Quoted text is kept as is, queries which fail to scan are only trimmed
+func NormalizeQuery(query string) string {
+
+	var sb strings.Builder
+	space := false
+	for i := 0; i < len(query); {
+		end, kind, err := scanSqlToken(query, i, Syntax{})
+		if err != nil {
+			return strings.TrimSpace(query)
+		}
+		switch kind {
+		case sqlSpace, sqlComment:
+			space = sb.Len() > 0
+		default:
+			if space {
+				sb.WriteByte(' ')
+				space = false
+			}
+			if kind == sqlWord {
+				sb.WriteString(strings.ToUpper(query[i:end]))
+			} else {
+				sb.WriteString(query[i:end])
+			}
+		}
+		i = end
+	}
+	return strings.TrimSpace(strings.TrimRight(sb.String(), "; "))
+
+}
+
+// The token before ? is a value, so ? is an operator
+func isOperand(kind sqlTokenKind, text string) bool {
+	switch kind {
+	case sqlString, sqlQuotedName, sqlNumber:
+		return true
+	case sqlWord:
+		return !slices.Contains(valueKeywords, strings.ToUpper(text))
+	case sqlOther:
+		return text == ")" || text == "]"
+	}
+	return false
+}
+
+// Finds the end of the token starting at i
+func scanSqlToken(query string, i int, syntax Syntax) (int, sqlTokenKind, error) {
+
+	c := query[i]
+	next := byte(0)
+	if i+1 < len(query) {
+		next = query[i+1]
+	}
+
+	switch {
+	case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		end := i + 1
+		for end < len(query) && strings.IndexByte(" \t\r\n", query[end]) >= 0 {
+			end++
+		}
+		return end, sqlSpace, nil
+	case c == '-' && next == '-', c == '#' && syntax.HashComments:
+		end := strings.IndexByte(query[i:], '\n')
+		if end < 0 {
+			return len(query), sqlComment, nil
+		}
+		return i + end + 1, sqlComment, nil
+	case c == '/' && next == '*':
+		end, err := scanBlockComment(query, i, syntax.NestedComments)
+		return end, sqlComment, err
+	case c == '\'':
+		end, err := scanQuoted(query, i+1, '\'', syntax.BackslashEscapes)
+		return end, sqlString, err
+	case (c == 'E' || c == 'e') && next == '\'' && syntax.EscapeStrings:
+		end, err := scanQuoted(query, i+2, '\'', true)
+		return end, sqlString, err
+	case (c == 'Q' || c == 'q') && next == '\'' && syntax.AltQuotes:
+		end, err := scanAltQuoted(query, i+2)
+		return end, sqlString, err
+	case (c == 'N' || c == 'n') && (next == 'Q' || next == 'q') && syntax.AltQuotes &&
+		i+2 < len(query) && query[i+2] == '\'':
+		end, err := scanAltQuoted(query, i+3)
+		return end, sqlString, err
+	case c == '"':
+		end, err := scanQuoted(query, i+1, '"', syntax.BackslashEscapes)
+		return end, sqlQuotedName, err
+	case c == '`' && syntax.Backticks:
+		end, err := scanQuoted(query, i+1, '`', false)
+		return end, sqlQuotedName, err
+	case c == '[' && syntax.Brackets:
+		end, err := scanQuoted(query, i+1, ']', false)
+		return end, sqlQuotedName, err
+	case c == '$' && syntax.DollarQuotes:
+		if end, ok, err := scanDollarQuoted(query, i); ok || err != nil {
+			return end, sqlString, err
+		}
+		return i + 1, sqlOther, nil
+	case isWordChar(c) && (c < '0' || c > '9'):
+		end := i + 1
+		for end < len(query) && (isWordChar(query[end]) || query[end] == '$') {
+			end++
+		}
+		return end, sqlWord, nil
+	case c >= '0' && c <= '9':
+		end := i + 1
+		for end < len(query) && (isWordChar(query[end]) || query[end] == '.') {
+			end++
+		}
+		return end, sqlNumber, nil
+	case c == '?':
+		return i + 1, sqlQuestion, nil
+	}
+	return i + 1, sqlOther, nil
+
+}
+
+// Letters, digits, _ and bytes of non-ASCII characters
+func isWordChar(c byte) bool {
+	return c == '_' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// End of the quoted text starting at i, the closing quote is doubled inside
+func scanQuoted(query string, i int, quote byte, backslashEscapes bool) (int, error) {
+
+	for i < len(query) {
+		switch {
+		case query[i] == '\\' && backslashEscapes:
+			i += 2
+		case query[i] == quote && i+1 < len(query) && query[i+1] == quote:
+			i += 2
+		case query[i] == quote:
+			return i + 1, nil
+		default:
+			i++
+		}
+	}
+	return 0, fmt.Errorf("%w: unterminated %c", ErrQuerySyntax, quote)
+
+}
+
+func scanBlockComment(query string, i int, nested bool) (int, error) {
+
+	depth := 0
+	for i+1 < len(query) {
+		switch {
+		case query[i] == '/' && query[i+1] == '*':
+			if depth == 0 || nested {
+				depth++
+			}
+			i += 2
+		case query[i] == '*' && query[i+1] == '/':
+			depth--
+			i += 2
+			if depth == 0 {
+				return i, nil
+			}
+		default:
+			i++
+		}
+	}
+	return 0, fmt.Errorf("%w: unterminated comment", ErrQuerySyntax)
+
+}
+
+// Oracle q'[...]' literal, the quote follows the closing delimiter
+func scanAltQuoted(query string, i int) (int, error) {
+
+	if i >= len(query) {
+		return 0, fmt.Errorf("%w: unterminated q'", ErrQuerySyntax)
+	}
+	closing := query[i]
+	switch closing {
+	case '[':
+		closing = ']'
+	case '{':
+		closing = '}'
+	case '(':
+		closing = ')'
+	case '<':
+		closing = '>'
+	}
+	end := strings.Index(query[i+1:], string(closing)+"'")
+	if end < 0 {
+		return 0, fmt.Errorf("%w: unterminated q'", ErrQuerySyntax)
+	}
+	return i + 1 + end + 2, nil
+
+}
+
+// Postgres $tag$...$tag$ literal. Not found for $1 and other uses of $
+func scanDollarQuoted(query string, i int) (int, bool, error) {
+
+	end := i + 1
+	for end < len(query) && isWordChar(query[end]) {
+		if end == i+1 && query[end] >= '0' && query[end] <= '9' {
+			return 0, false, nil
+		}
+		end++
+	}
+	if end >= len(query) || query[end] != '$' {
+		return 0, false, nil
+	}
+	tag := query[i : end+1]
+	closing := strings.Index(query[end+1:], tag)
+	if closing < 0 {
+		return 0, true, fmt.Errorf("%w: unterminated %s", ErrQuerySyntax, tag)
+	}
+	return end + 1 + closing + len(tag), true, nil
+
+}
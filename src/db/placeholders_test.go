@@ -0,0 +1,121 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTranslatePlaceholders(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		dialect Dialect
+		query   string
+		want    string
+		err     error
+	}{
+		{name: "postgres", dialect: postgresDialect{},
+			query: "SELECT * FROM t WHERE a = ? AND b IN (?, ?)",
+			want:  "SELECT * FROM t WHERE a = $1 AND b IN ($2, $3)"},
+		{name: "literal", dialect: postgresDialect{},
+			query: "SELECT 'it''s ?', ? FROM t",
+			want:  "SELECT 'it''s ?', $1 FROM t"},
+		{name: "escape string", dialect: postgresDialect{},
+			query: `SELECT E'\' ?', e'?', ?`,
+			want:  `SELECT E'\' ?', e'?', $1`},
+		{name: "dollar quotes", dialect: postgresDialect{},
+			query: "SELECT $$ ? $$, $tag$ it's ? $tag$, ?",
+			want:  "SELECT $$ ? $$, $tag$ it's ? $tag$, $1"},
+		{name: "nested comments", dialect: postgresDialect{},
+			query: "SELECT /* ? /* ? */ ? */ ? -- ?\nFROM t",
+			want:  "SELECT /* ? /* ? */ ? */ $1 -- ?\nFROM t"},
+		{name: "quoted name", dialect: postgresDialect{},
+			query: `SELECT "col?" FROM t WHERE "a" = ?`,
+			want:  `SELECT "col?" FROM t WHERE "a" = $1`},
+		{name: "JSON key operator", dialect: postgresDialect{},
+			query: "SELECT data ? 'key', (data) ? 'k', \"d\" ? 'k' FROM t WHERE id = ?",
+			want:  "SELECT data ? 'key', (data) ? 'k', \"d\" ? 'k' FROM t WHERE id = $1"},
+		{name: "JSON any and all operators", dialect: postgresDialect{},
+			query: "SELECT data ?| array['a'], data ?& array['b'] FROM t WHERE id = ?",
+			want:  "SELECT data ?| array['a'], data ?& array['b'] FROM t WHERE id = $1"},
+		{name: "escaped operator", dialect: postgresDialect{},
+			query: "SELECT * FROM t WHERE ?? 'key' AND id = ?",
+			want:  "SELECT * FROM t WHERE ? 'key' AND id = $1"},
+		{name: "parameter before concatenation", dialect: postgresDialect{},
+			query: "SELECT name || ? || ? FROM t",
+			want:  "SELECT name || $1 || $2 FROM t"},
+		{name: "offset and fetch", dialect: postgresDialect{},
+			query: "SELECT * FROM t ORDER BY id OFFSET ? ROWS FETCH FIRST ? ROWS ONLY",
+			want:  "SELECT * FROM t ORDER BY id OFFSET $1 ROWS FETCH FIRST $2 ROWS ONLY"},
+		{name: "fetch next", dialect: postgresDialect{},
+			query: "SELECT * FROM t FETCH NEXT ? ROWS ONLY",
+			want:  "SELECT * FROM t FETCH NEXT $1 ROWS ONLY"},
+		{name: "keywords before values", dialect: postgresDialect{},
+			query: "SELECT CASE WHEN a THEN ? ELSE ? END, now() - INTERVAL ?, x AS ? FROM t LIMIT ?",
+			want:  "SELECT CASE WHEN a THEN $1 ELSE $2 END, now() - INTERVAL $3, x AS $4 FROM t LIMIT $5"},
+		{name: "return", dialect: postgresDialect{},
+			query: "RETURN ?",
+			want:  "RETURN $1"},
+		{name: "sql server", dialect: sqlserverDialect{},
+			query: "SELECT [a?], '?' FROM t -- ?\nWHERE b = ? AND c = ?",
+			want:  "SELECT [a?], '?' FROM t -- ?\nWHERE b = @p1 AND c = @p2"},
+		{name: "sql server without JSON operators", dialect: sqlserverDialect{},
+			query: "SELECT a FROM t WHERE b ? 1",
+			want:  "SELECT a FROM t WHERE b @p1 1"},
+		{name: "oracle alternative quotes", dialect: oracleDialect{},
+			query: "SELECT q'[it's ?]', Nq'{?}', q'<?>', ? FROM dual WHERE x = ?",
+			want:  "SELECT q'[it's ?]', Nq'{?}', q'<?>', :1 FROM dual WHERE x = :2"},
+		{name: "question mark dialect", dialect: stubDialect{},
+			query: "SELECT '?' FROM t WHERE a = ?",
+			want:  "SELECT '?' FROM t WHERE a = ?"},
+
+		{name: "unterminated string", dialect: postgresDialect{}, query: "SELECT 'abc = ?", err: ErrQuerySyntax},
+		{name: "unterminated comment", dialect: postgresDialect{}, query: "SELECT /* /* */ ?", err: ErrQuerySyntax},
+		{name: "unterminated dollar quote", dialect: postgresDialect{}, query: "SELECT $a$ ?", err: ErrQuerySyntax},
+		{name: "unterminated bracket", dialect: sqlserverDialect{}, query: "SELECT [a FROM t WHERE b = ?", err: ErrQuerySyntax},
+		{name: "unterminated alternative quote", dialect: oracleDialect{}, query: "SELECT q'[abc]", err: ErrQuerySyntax},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TranslatePlaceholders(tt.query, tt.dialect)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+
+}
+
+func TestNormalizeQuery(t *testing.T) {
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select  a,\n\tb from T -- comment\n WHERE x = 'Abc  d';", "SELECT A, B FROM T WHERE X = 'Abc  d'"},
+		{"/* lead */ SELECT 1 /* tail */ ;", "SELECT 1"},
+		{"SELECT 1;;", "SELECT 1"},
+		{`select "Mixed Case" from t`, `SELECT "Mixed Case" FROM T`},
+		{"SELECT /* a /* b */ 1", "SELECT 1"},
+		{"SELECT * FROM t WHERE id = ?", "SELECT * FROM T WHERE ID = ?"},
+		{"  SELECT 'abc  ", "SELECT 'abc"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeQuery(tt.query); got != tt.want {
+			t.Errorf("NormalizeQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+}
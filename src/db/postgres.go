@@ -0,0 +1,187 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"sql-proxy/src/app"
+)
+
+type postgresDialect struct{ baseDialect }
+
+func (postgresDialect) DriverName() string {
+	return "postgres"
+}
+
+// lib/pq key=value connection string. Certificates are passed inline,
+// the driver has no read timeout, it is applied as statement_timeout
+func (postgresDialect) DSN(connInfo *DbConnInfo) (string, error) {
+
+	params := []string{
+		pqParam("host", connInfo.Host),
+		pqParam("user", connInfo.User),
+		pqParam("password", connInfo.Password),
+		pqParam("dbname", connInfo.DbName),
+	}
+	if connInfo.Port != 0 {
+		params = append(params, pqParam("port", strconv.Itoa(int(connInfo.Port))))
+	}
+
+	sslMode := tlsMode(connInfo)
+	if sslMode == "" {
+		sslMode = app.TLSDisable
+	}
+	params = append(params, pqParam("sslmode", sslMode))
+	if tlsOptions := connInfo.TLS; tlsOptions != nil && sslMode != app.TLSDisable {
+		if tlsOptions.ServerName != "" {
+			return "", notSupported(connInfo, "tls.server_name")
+		}
+		ca, cert, key, err := tlsPEM(tlsOptions)
+		if err != nil {
+			return "", err
+		}
+		if len(ca) > 0 || len(cert) > 0 {
+			params = append(params, pqParam("sslinline", "true"))
+		}
+		if len(ca) > 0 {
+			params = append(params, pqParam("sslrootcert", string(ca)))
+		}
+		if len(cert) > 0 {
+			params = append(params, pqParam("sslcert", string(cert)), pqParam("sslkey", string(key)))
+		}
+	}
+
+	if connInfo.ConnectTimeout > 0 {
+		params = append(params, pqParam("connect_timeout", strconv.Itoa(seconds(connInfo.ConnectTimeout))))
+	}
+	if connInfo.ReadTimeout > 0 {
+		ms := time.Duration(connInfo.ReadTimeout).Milliseconds()
+		params = append(params, pqParam("statement_timeout", strconv.FormatInt(ms, 10)))
+	}
+	if connInfo.ApplicationName != "" {
+		params = append(params, pqParam("application_name", connInfo.ApplicationName))
+	}
+	for _, key := range slices.Sorted(maps.Keys(connInfo.Options)) {
+		params = append(params, pqParam(key, connInfo.Options[key]))
+	}
+
+	return strings.Join(params, " "), nil
+
+}
+
+func (postgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgresDialect) Syntax() Syntax {
+	return Syntax{EscapeStrings: true, DollarQuotes: true, NestedComments: true, QuestionOperators: true}
+}
+
+// CALL returns a row of the INOUT and OUT parameters, OUT ones are given as NULL (Postgres 14+)
+func (d postgresDialect) CallProcedure(ctx context.Context, conn *sql.Conn, call *ProcedureCall) (*sql.Rows, procedureOutputs, error) {
+
+	name, err := quoteQualified(d, call.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var args []any
+	list := make([]string, len(call.Params))
+	for i, p := range call.Params {
+		if p.Direction == ParamOut {
+			list[i] = "NULL"
+			continue
+		}
+		v, err := p.inValue()
+		if err != nil {
+			return nil, nil, err
+		}
+		args = append(args, v)
+		list[i] = d.Placeholder(len(args))
+	}
+
+	rows, err := conn.QueryContext(ctx, "CALL "+name+"("+strings.Join(list, ", ")+")", args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	outputs, err := scanOutputs(rows, call.Params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, func() (map[string]any, any, error) { return outputs, nil, nil }, nil
+
+}
+
+// Catalog queries, pg_catalog is used where information_schema lacks the column order of keys
+var postgresSchemaQueries = map[string]string{
+	"databases": `SELECT datname AS name FROM pg_database WHERE NOT datistemplate`,
+	"schemas": `SELECT nspname AS name FROM pg_namespace
+		WHERE nspname NOT LIKE 'pg\_toast%' AND nspname NOT LIKE 'pg\_temp\_%'`,
+	"tables": `SELECT table_schema AS schema_name, table_name,
+		CASE table_type WHEN 'VIEW' THEN 'view' ELSE 'table' END AS table_type
+		FROM information_schema.tables`,
+	"columns": `SELECT table_schema AS schema_name, table_name, column_name, ordinal_position AS position,
+		data_type, character_maximum_length AS max_length, numeric_precision, numeric_scale,
+		is_nullable, column_default,
+		CASE WHEN is_identity = 'YES' OR column_default LIKE 'nextval(%' THEN 1 ELSE 0 END AS is_identity
+		FROM information_schema.columns`,
+	"keys": `SELECT n.nspname AS schema_name, t.relname AS table_name, c.conname AS constraint_name,
+		CASE c.contype WHEN 'p' THEN 'primary' ELSE 'foreign' END AS key_type,
+		a.attname AS column_name, k.ord AS position,
+		rn.nspname AS ref_schema, rt.relname AS ref_table, ra.attname AS ref_column
+		FROM pg_constraint c
+		JOIN pg_class t ON t.oid = c.conrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		CROSS JOIN LATERAL unnest(c.conkey) WITH ORDINALITY AS k(attnum, ord)
+		JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+		LEFT JOIN pg_class rt ON rt.oid = c.confrelid
+		LEFT JOIN pg_namespace rn ON rn.oid = rt.relnamespace
+		LEFT JOIN pg_attribute ra ON ra.attrelid = c.confrelid AND ra.attnum = c.confkey[k.ord]
+		WHERE c.contype IN ('p', 'f')`,
+	"indexes": `SELECT n.nspname AS schema_name, t.relname AS table_name, i.relname AS index_name,
+		CASE WHEN x.indisunique THEN 1 ELSE 0 END AS is_unique,
+		CASE WHEN x.indisprimary THEN 1 ELSE 0 END AS is_primary,
+		a.attname AS column_name, k.ord AS position
+		FROM pg_index x
+		JOIN pg_class i ON i.oid = x.indexrelid
+		JOIN pg_class t ON t.oid = x.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		CROSS JOIN LATERAL unnest(x.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord)
+		LEFT JOIN pg_attribute a ON a.attrelid = x.indrelid AND a.attnum = k.attnum`,
+	"procedures": `SELECT routine_schema AS schema_name, routine_name, lower(routine_type) AS routine_type
+		FROM information_schema.routines`,
+}
+
+func (postgresDialect) SchemaQuery(object string) string {
+	return postgresSchemaQueries[object]
+}
+
+// EXPLAIN (FORMAT JSON), with ANALYZE the statement is run in a transaction rolled back afterwards
+func (postgresDialect) Explain(ctx context.Context, conn *sql.Conn, query string, analyze bool) (*Plan, error) {
+
+	var raw string
+	var err error
+	if analyze {
+		err = explainInTx(ctx, conn, func(tx *sql.Tx) error {
+			return tx.QueryRowContext(ctx, "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) "+query).Scan(&raw)
+		})
+	} else {
+		err = conn.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query).Scan(&raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parsePostgresPlan(raw)
+
+}
+
+// Values are quoted, so passwords may contain spaces and quotes
+func pqParam(key, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return key + "='" + value + "'"
+}
@@ -0,0 +1,371 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrProcedureNotSupported = errors.New("Stored procedures are not supported")
+	ErrInvalidParam          = errors.New("Invalid procedure parameter")
+)
+
+// Parameter directions, as ADODB adParamInput, adParamOutput and adParamInputOutput
+const (
+	ParamIn    = "in"
+	ParamOut   = "out"
+	ParamInOut = "inout"
+)
+
+// Parameter types. Decimals are passed as strings to keep precision,
+// binary values as base64 strings
+var paramTypes = []string{"", "string", "int", "float", "decimal", "bool", "datetime", "binary"}
+
+type ProcedureParam struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Direction string `json:"direction"` // in if empty
+	Value     any    `json:"value"`     // decoded with json.Number
+}
+
+type ProcedureCall struct {
+	Name     string           `json:"name"`     // may be schema qualified
+	Function bool             `json:"function"` // called in SELECT, the result is the return value
+	Params   []ProcedureParam `json:"params"`
+}
+
+// Procedure call in progress. Result sets are read from Rows,
+// output values are available after that
+type ProcedureRun struct {
+	Rows    *sql.Rows // nil if the call returns no result sets
+	conn    *sql.Conn
+	outputs procedureOutputs
+}
+
+// Output values by parameter name and the return value, called after the rows are closed
+type procedureOutputs func() (map[string]any, any, error)
+
+// Dialects calling procedures with output parameters. The call is made
+// on a single connection, as output values may be kept in session variables
+type procedureDialect interface {
+	CallProcedure(ctx context.Context, conn *sql.Conn, call *ProcedureCall) (*sql.Rows, procedureOutputs, error)
+}
+
+// Calls the stored procedure or function. The run must be closed after use
+func CallProcedure(ctx context.Context, dbConn *DbConn, call *ProcedureCall) (*ProcedureRun, error) {
+
+	pd, ok := dbConn.Dialect.(procedureDialect)
+	if !ok {
+		return nil, fmt.Errorf("%w for %s", ErrProcedureNotSupported, dbConn.Info.DbType)
+	}
+	if err := call.validate(); err != nil {
+		return nil, err
+	}
+
+	conn, err := dbConn.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	run := &ProcedureRun{conn: conn}
+
+	if call.Function {
+		result, err := callFunction(ctx, conn, dbConn.Dialect, call)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		run.outputs = func() (map[string]any, any, error) {
+			return map[string]any{}, result, nil
+		}
+		return run, nil
+	}
+
+	run.Rows, run.outputs, err = pd.CallProcedure(ctx, conn, call)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return run, nil
+
+}
+
+func (r *ProcedureRun) Outputs() (map[string]any, any, error) {
+	if r.Rows != nil {
+		if err := r.Rows.Close(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return r.outputs()
+}
+
+func (r *ProcedureRun) Close() error {
+	if r.Rows != nil {
+		r.Rows.Close()
+	}
+	return r.conn.Close()
+}
+
+func (c *ProcedureCall) validate() error {
+
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("%w: procedure name is required", ErrInvalidParam)
+	}
+	for i := range c.Params {
+		p := &c.Params[i]
+		p.Type = strings.ToLower(p.Type)
+		p.Direction = strings.ToLower(p.Direction)
+		if p.Direction == "" {
+			p.Direction = ParamIn
+		}
+		switch {
+		case p.Direction != ParamIn && p.Direction != ParamOut && p.Direction != ParamInOut:
+			return fmt.Errorf("%w: unknown direction '%s', expected in, out or inout", ErrInvalidParam, p.Direction)
+		case !slices.Contains(paramTypes, p.Type):
+			return fmt.Errorf("%w: unknown type '%s' of %s", ErrInvalidParam, p.Type, p.Name)
+		case p.Direction != ParamIn && c.Function:
+			return fmt.Errorf("%w: functions have input parameters only", ErrInvalidParam)
+		case p.Direction != ParamIn && p.Name == "":
+			return fmt.Errorf("%w: output parameters must have names", ErrInvalidParam)
+		}
+	}
+	return nil
+
+}
+
+// SELECT name(params) for functions of all dialects
+func callFunction(ctx context.Context, conn *sql.Conn, dialect Dialect, call *ProcedureCall) (any, error) {
+
+	name, err := quoteQualified(dialect, call.Name)
+	if err != nil {
+		return nil, err
+	}
+	args, err := inValues(call.Params)
+	if err != nil {
+		return nil, err
+	}
+	placeholders := make([]string, len(args))
+	for i := range args {
+		placeholders[i] = dialect.Placeholder(i + 1)
+	}
+
+	var result any
+	query := "SELECT " + name + "(" + strings.Join(placeholders, ", ") + ")"
+	if err = conn.QueryRowContext(ctx, query, args...).Scan(&result); err != nil {
+		return nil, err
+	}
+	return decodeDefault(result), nil
+
+}
+
+// Schema qualified name with each part quoted: dbo.Totals -> [dbo].[Totals]
+func quoteQualified(dialect Dialect, name string) (string, error) {
+
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return "", fmt.Errorf("%w: invalid name '%s'", ErrInvalidParam, name)
+		}
+		parts[i] = dialect.QuoteIdent(part)
+	}
+	return strings.Join(parts, "."), nil
+
+}
+
+func inValues(params []ProcedureParam) ([]any, error) {
+	args := make([]any, len(params))
+	for i, p := range params {
+		v, err := p.inValue()
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return args, nil
+}
+
+// Parameter value converted from JSON by the type
+func (p *ProcedureParam) inValue() (any, error) {
+
+	if p.Value == nil {
+		return nil, nil
+	}
+	invalid := fmt.Errorf("%w: invalid %s value of %s", ErrInvalidParam, cmp.Or(p.Type, "untyped"), p.Name)
+
+	switch p.Type {
+	case "int":
+		switch v := p.Value.(type) {
+		case json.Number:
+			i, err := v.Int64()
+			if err != nil {
+				return nil, invalid
+			}
+			return i, nil
+		case string:
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, invalid
+			}
+			return i, nil
+		}
+	case "float":
+		switch v := p.Value.(type) {
+		case json.Number:
+			f, err := v.Float64()
+			if err != nil {
+				return nil, invalid
+			}
+			return f, nil
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, invalid
+			}
+			return f, nil
+		}
+	case "decimal":
+		switch v := p.Value.(type) {
+		case json.Number:
+			return v.String(), nil
+		case string:
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				return nil, invalid
+			}
+			return v, nil
+		}
+	case "bool":
+		if v, ok := p.Value.(bool); ok {
+			return v, nil
+		}
+	case "datetime":
+		if v, ok := p.Value.(string); ok {
+			for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+				if t, err := time.Parse(layout, v); err == nil {
+					return t, nil
+				}
+			}
+		}
+	case "binary":
+		if v, ok := p.Value.(string); ok {
+			b, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return nil, invalid
+			}
+			return b, nil
+		}
+	case "string":
+		switch v := p.Value.(type) {
+		case string:
+			return v, nil
+		case json.Number:
+			return v.String(), nil
+		}
+	default:
+		// Untyped values as decoded from JSON
+		if v, ok := p.Value.(json.Number); ok {
+			if i, err := v.Int64(); err == nil {
+				return i, nil
+			}
+			f, err := v.Float64()
+			if err != nil {
+				return nil, invalid
+			}
+			return f, nil
+		}
+		switch p.Value.(type) {
+		case string, bool:
+			return p.Value, nil
+		}
+	}
+	return nil, invalid
+
+}
+
+// Output value converted by the parameter type, drivers may return numbers as text
+func (p *ProcedureParam) outValue(v any) any {
+
+	if valuer, ok := v.(driver.Valuer); ok {
+		v, _ = valuer.Value()
+	}
+	if v == nil {
+		return nil
+	}
+	if b, ok := v.([]byte); ok {
+		if p.Type == "binary" {
+			return b
+		}
+		v = string(b)
+	}
+	s, isText := v.(string)
+	if !isText {
+		return v
+	}
+
+	switch p.Type {
+	case "int":
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case "bool":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+	return s
+
+}
+
+// Output values from the single row result, columns follow the output parameters in order
+func scanOutputs(rows *sql.Rows, params []ProcedureParam) (map[string]any, error) {
+
+	defer rows.Close()
+
+	var outParams []*ProcedureParam
+	for i := range params {
+		if params[i].Direction != ParamIn {
+			outParams = append(outParams, &params[i])
+		}
+	}
+
+	outputs := make(map[string]any, len(outParams))
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return outputs, nil
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(columns))
+	valuePtrs := make([]any, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	if err = rows.Scan(valuePtrs...); err != nil {
+		return nil, err
+	}
+
+	for i, p := range outParams {
+		if i < len(values) {
+			outputs[p.Name] = p.outValue(values[i])
+		}
+	}
+	return outputs, rows.Err()
+
+}
@@ -0,0 +1,321 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrSchemaNotSupported  = errors.New("Schema introspection is not supported")
+	ErrUnknownSchemaObject = errors.New("Unknown schema object")
+)
+
+// Dialects listing schema objects. Queries return the normalized columns
+// of the object, filters and order are added around them
+type schemaDialect interface {
+	SchemaQuery(object string) string // empty if not supported
+}
+
+// Filters of the schema listing, name is a LIKE pattern
+type SchemaFilter struct {
+	Schema string
+	Table  string
+	Name   string
+}
+
+type SchemaName struct {
+	Name string `json:"name"`
+}
+
+type SchemaTable struct {
+	Schema string `json:"schema"`
+	Name   string `json:"name"`
+	Type   string `json:"type"` // table or view
+}
+
+type SchemaColumn struct {
+	Schema    string  `json:"schema"`
+	Table     string  `json:"table"`
+	Name      string  `json:"name"`
+	Position  int64   `json:"position"`
+	Type      string  `json:"type"`
+	MaxLength *int64  `json:"max_length"`
+	Precision *int64  `json:"precision"`
+	Scale     *int64  `json:"scale"`
+	Nullable  bool    `json:"nullable"`
+	Default   *string `json:"default"`
+	Identity  bool    `json:"identity"` // identity, serial or auto increment
+}
+
+type SchemaKey struct {
+	Schema     string   `json:"schema"`
+	Table      string   `json:"table"`
+	Name       string   `json:"name"`
+	Type       string   `json:"type"` // primary or foreign
+	Columns    []string `json:"columns"`
+	RefSchema  string   `json:"ref_schema,omitempty"`
+	RefTable   string   `json:"ref_table,omitempty"`
+	RefColumns []string `json:"ref_columns,omitempty"`
+}
+
+type SchemaIndex struct {
+	Schema  string   `json:"schema"`
+	Table   string   `json:"table"`
+	Name    string   `json:"name"`
+	Unique  bool     `json:"unique"`
+	Primary bool     `json:"primary"`
+	Columns []string `json:"columns"` // null for expressions
+}
+
+type SchemaRoutine struct {
+	Schema string `json:"schema"`
+	Name   string `json:"name"`
+	Type   string `json:"type"` // procedure or function
+}
+
+// Normalized columns used by the filters and order of the object
+type schemaObject struct {
+	schema string // empty if not filtered by schema
+	table  string
+	name   string
+	order  string
+	build  func(rows []map[string]any) any
+}
+
+var schemaObjects = map[string]schemaObject{
+	"databases":  {name: "name", order: "name", build: buildNames},
+	"schemas":    {name: "name", order: "name", build: buildNames},
+	"tables":     {schema: "schema_name", name: "table_name", order: "schema_name, table_name", build: buildTables},
+	"columns":    {schema: "schema_name", table: "table_name", name: "column_name", order: "schema_name, table_name, position", build: buildColumns},
+	"keys":       {schema: "schema_name", table: "table_name", name: "constraint_name", order: "schema_name, table_name, key_type DESC, constraint_name, position", build: buildKeys},
+	"indexes":    {schema: "schema_name", table: "table_name", name: "index_name", order: "schema_name, table_name, index_name, position", build: buildIndexes},
+	"procedures": {schema: "schema_name", name: "routine_name", order: "schema_name, routine_name", build: buildRoutines},
+}
+
+// Lists databases, schemas, tables and views, columns, keys, indexes or procedures
+func ListSchema(ctx context.Context, dbConn *DbConn, object string, filter SchemaFilter) (any, error) {
+
+	obj, found := schemaObjects[object]
+	if !found {
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownSchemaObject, object)
+	}
+	sd, ok := dbConn.Dialect.(schemaDialect)
+	if !ok || sd.SchemaQuery(object) == "" {
+		return nil, fmt.Errorf("%w for %s", ErrSchemaNotSupported, dbConn.Info.DbType)
+	}
+
+	var conditions []string
+	var args []any
+	addFilter := func(column, op, value string) {
+		if column == "" || value == "" {
+			return
+		}
+		args = append(args, value)
+		conditions = append(conditions, column+" "+op+" "+dbConn.Dialect.Placeholder(len(args)))
+	}
+	addFilter(obj.schema, "=", filter.Schema)
+	addFilter(obj.table, "=", filter.Table)
+	addFilter(obj.name, "LIKE", filter.Name)
+
+	query := "SELECT * FROM (" + sd.SchemaQuery(object) + ") s"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + obj.order
+
+	rows, err := dbConn.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []map[string]any
+	values := make([]any, len(columns))
+	valuePtrs := make([]any, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			row[strings.ToLower(column)] = decodeDefault(values[i])
+		}
+		result = append(result, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return obj.build(result), nil
+
+}
+
+func buildNames(rows []map[string]any) any {
+	items := make([]SchemaName, len(rows))
+	for i, row := range rows {
+		items[i] = SchemaName{Name: schemaString(row["name"])}
+	}
+	return items
+}
+
+func buildTables(rows []map[string]any) any {
+	items := make([]SchemaTable, len(rows))
+	for i, row := range rows {
+		items[i] = SchemaTable{
+			Schema: schemaString(row["schema_name"]),
+			Name:   schemaString(row["table_name"]),
+			Type:   schemaString(row["table_type"]),
+		}
+	}
+	return items
+}
+
+func buildColumns(rows []map[string]any) any {
+
+	items := make([]SchemaColumn, len(rows))
+	for i, row := range rows {
+		items[i] = SchemaColumn{
+			Schema:    schemaString(row["schema_name"]),
+			Table:     schemaString(row["table_name"]),
+			Name:      schemaString(row["column_name"]),
+			Type:      schemaString(row["data_type"]),
+			MaxLength: schemaInt(row["max_length"]),
+			Precision: schemaInt(row["numeric_precision"]),
+			Scale:     schemaInt(row["numeric_scale"]),
+			Nullable:  schemaBool(row["is_nullable"]),
+			Identity:  schemaBool(row["is_identity"]),
+		}
+		if position := schemaInt(row["position"]); position != nil {
+			items[i].Position = *position
+		}
+		if v := row["column_default"]; v != nil {
+			def := schemaString(v)
+			items[i].Default = &def
+		}
+	}
+	return items
+
+}
+
+// Rows of key columns in order are grouped by key
+func buildKeys(rows []map[string]any) any {
+
+	items := []SchemaKey{}
+	for _, row := range rows {
+		key := SchemaKey{
+			Schema: schemaString(row["schema_name"]),
+			Table:  schemaString(row["table_name"]),
+			Name:   schemaString(row["constraint_name"]),
+			Type:   schemaString(row["key_type"]),
+		}
+		last := len(items) - 1
+		if last < 0 || items[last].Schema != key.Schema || items[last].Table != key.Table || items[last].Name != key.Name {
+			key.RefSchema = schemaString(row["ref_schema"])
+			key.RefTable = schemaString(row["ref_table"])
+			items = append(items, key)
+			last++
+		}
+		items[last].Columns = append(items[last].Columns, schemaString(row["column_name"]))
+		if key.Type == "foreign" {
+			items[last].RefColumns = append(items[last].RefColumns, schemaString(row["ref_column"]))
+		}
+	}
+	return items
+
+}
+
+func buildIndexes(rows []map[string]any) any {
+
+	items := []SchemaIndex{}
+	for _, row := range rows {
+		index := SchemaIndex{
+			Schema:  schemaString(row["schema_name"]),
+			Table:   schemaString(row["table_name"]),
+			Name:    schemaString(row["index_name"]),
+			Unique:  schemaBool(row["is_unique"]),
+			Primary: schemaBool(row["is_primary"]),
+		}
+		last := len(items) - 1
+		if last < 0 || items[last].Schema != index.Schema || items[last].Table != index.Table || items[last].Name != index.Name {
+			items = append(items, index)
+			last++
+		}
+		if column := row["column_name"]; column != nil {
+			items[last].Columns = append(items[last].Columns, schemaString(column))
+		}
+	}
+	return items
+
+}
+
+func buildRoutines(rows []map[string]any) any {
+	items := make([]SchemaRoutine, len(rows))
+	for i, row := range rows {
+		items[i] = SchemaRoutine{
+			Schema: schemaString(row["schema_name"]),
+			Name:   schemaString(row["routine_name"]),
+			Type:   schemaString(row["routine_type"]),
+		}
+	}
+	return items
+}
+
+func schemaString(v any) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// Numbers may be returned as integers or text
+func schemaInt(v any) *int64 {
+	var i int64
+	switch x := v.(type) {
+	case int64:
+		i = x
+	case int32:
+		i = int64(x)
+	case int16:
+		i = int64(x)
+	case uint8:
+		i = int64(x)
+	case uint64:
+		i = int64(x)
+	case string:
+		parsed, err := strconv.ParseInt(x, 10, 64)
+		if err != nil {
+			return nil
+		}
+		i = parsed
+	default:
+		return nil
+	}
+	return &i
+}
+
+// YES/NO, 1/0 or booleans
+func schemaBool(v any) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case string:
+		return strings.EqualFold(x, "YES") || x == "1" || strings.EqualFold(x, "true")
+	}
+	if i := schemaInt(v); i != nil {
+		return *i != 0
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sql-proxy/src/app"
+)
+
+var ErrPathNotAllowed = errors.New("Database file is outside of the allowed directories")
+
+type sqliteDialect struct{ baseDialect }
+
+func (sqliteDialect) DriverName() string {
+	return "sqlite"
+}
+
+// SQLite connection string. The database file must be in one of the allowed
+// directories, relative paths are resolved against the first one
+func (sqliteDialect) DSN(connInfo *DbConnInfo) (string, error) {
+
+	err := rejectOptions(connInfo, optionTLS, optionConnectTimeout, optionReadTimeout, optionApplicationName)
+	if err != nil {
+		return "", err
+	}
+
+	cfg := app.GetConfig().SQLite
+	if len(cfg.AllowedDirs) == 0 || connInfo.DbName == "" {
+		return "", ErrPathNotAllowed
+	}
+
+	path := connInfo.DbName
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.AllowedDirs[0], path)
+	}
+	path, err = resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	allowed := false
+	for _, dir := range cfg.AllowedDirs {
+		if dir, err = filepath.EvalSymlinks(dir); err == nil && isInside(dir, path) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", ErrPathNotAllowed
+	}
+
+	// The file is never created unless allowed, typos in the name would leave empty databases
+	query := url.Values{}
+	switch {
+	case connInfo.ReadOnly:
+		query.Set("mode", "ro")
+		query.Add("_pragma", "query_only(1)")
+	case cfg.AllowCreate:
+		query.Set("mode", "rwc")
+	default:
+		query.Set("mode", "rw")
+	}
+	query.Add("_pragma", "busy_timeout(5000)")
+	for key, value := range connInfo.Options {
+		// The open mode depends on the config and read_only flag
+		if key == "mode" {
+			return "", notSupported(connInfo, "mode option")
+		}
+		query.Add(key, value)
+	}
+
+	dsn := url.URL{Scheme: "file", Path: filepath.ToSlash(path), RawQuery: query.Encode()}
+	return dsn.String(), nil
+
+}
+
+// Absolute path with symlinks resolved, the file itself may not exist yet
+func resolvePath(path string) (string, error) {
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return resolved, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(path)), nil
+
+}
+
+// Directory in one of the allowed directories, relative paths are resolved
+// against the first one. Empty name is the first directory itself
+func allowedDir(allowedDirs []string, name string) (string, error) {
+
+	if len(allowedDirs) == 0 {
+		return "", ErrPathNotAllowed
+	}
+
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(allowedDirs[0], path)
+	}
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+
+	for _, dir := range allowedDirs {
+		if dir, err = filepath.EvalSymlinks(dir); err == nil && (dir == path || isInside(dir, path)) {
+			return path, nil
+		}
+	}
+	return "", ErrPathNotAllowed
+
+}
+
+func isInside(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
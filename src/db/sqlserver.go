@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"sql-proxy/src/app"
+)
+
+type sqlserverDialect struct{ baseDialect }
+
+func (sqlserverDialect) DriverName() string {
+	return "sqlserver"
+}
+
+// SQL Server connection URL. Certificates are set by the connector,
+// without the tls block the driver default (login encryption only) is kept
+func (sqlserverDialect) DSN(connInfo *DbConnInfo) (string, error) {
+
+	if connInfo.TLS != nil && (connInfo.TLS.Cert != "" || connInfo.TLS.CertFile != "") {
+		return "", notSupported(connInfo, "tls.cert")
+	}
+
+	query := url.Values{}
+	query.Set("database", connInfo.DbName)
+	switch tlsMode(connInfo) {
+	case app.TLSDisable:
+		query.Set("encrypt", "disable")
+	case app.TLSRequire:
+		query.Set("encrypt", "true")
+		query.Set("TrustServerCertificate", "true")
+	case app.TLSVerifyCA, app.TLSVerifyFull:
+		query.Set("encrypt", "true")
+	}
+	if connInfo.ConnectTimeout > 0 {
+		query.Set("dial timeout", strconv.Itoa(seconds(connInfo.ConnectTimeout)))
+	}
+	if connInfo.ReadTimeout > 0 {
+		query.Set("connection timeout", strconv.Itoa(seconds(connInfo.ReadTimeout)))
+	}
+	if connInfo.ApplicationName != "" {
+		query.Set("app name", connInfo.ApplicationName)
+	}
+	for _, key := range slices.Sorted(maps.Keys(connInfo.Options)) {
+		query.Set(key, connInfo.Options[key])
+	}
+
+	// The driver default port is used if not set
+	host := connInfo.Host
+	if connInfo.Port != 0 {
+		host = net.JoinHostPort(connInfo.Host, strconv.Itoa(int(connInfo.Port)))
+	}
+	dsn := url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(connInfo.User, connInfo.Password),
+		Host:     host,
+		RawQuery: query.Encode(),
+	}
+	return dsn.String(), nil
+
+}
+
+func (sqlserverDialect) Placeholder(n int) string {
+	return "@p" + strconv.Itoa(n)
+}
+
+func (sqlserverDialect) QuoteIdent(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+func (sqlserverDialect) Syntax() Syntax {
+	return Syntax{Brackets: true}
+}
+
+// Catalog queries of the current database, key columns are taken from sys views
+var sqlserverSchemaQueries = map[string]string{
+	"databases": `SELECT name FROM sys.databases`,
+	"schemas":   `SELECT name FROM sys.schemas`,
+	"tables": `SELECT TABLE_SCHEMA AS schema_name, TABLE_NAME AS table_name,
+		CASE TABLE_TYPE WHEN 'VIEW' THEN 'view' ELSE 'table' END AS table_type
+		FROM INFORMATION_SCHEMA.TABLES`,
+	"columns": `SELECT c.TABLE_SCHEMA AS schema_name, c.TABLE_NAME AS table_name, c.COLUMN_NAME AS column_name,
+		c.ORDINAL_POSITION AS position, c.DATA_TYPE AS data_type, c.CHARACTER_MAXIMUM_LENGTH AS max_length,
+		c.NUMERIC_PRECISION AS numeric_precision, c.NUMERIC_SCALE AS numeric_scale,
+		c.IS_NULLABLE AS is_nullable, c.COLUMN_DEFAULT AS column_default,
+		COLUMNPROPERTY(OBJECT_ID(QUOTENAME(c.TABLE_SCHEMA) + '.' + QUOTENAME(c.TABLE_NAME)), c.COLUMN_NAME, 'IsIdentity') AS is_identity
+		FROM INFORMATION_SCHEMA.COLUMNS c`,
+	"keys": `SELECT s.name AS schema_name, t.name AS table_name, k.name AS constraint_name, 'primary' AS key_type,
+		c.name AS column_name, ic.key_ordinal AS position,
+		CAST(NULL AS sysname) AS ref_schema, CAST(NULL AS sysname) AS ref_table, CAST(NULL AS sysname) AS ref_column
+		FROM sys.key_constraints k
+		JOIN sys.tables t ON t.object_id = k.parent_object_id
+		JOIN sys.schemas s ON s.schema_id = t.schema_id
+		JOIN sys.index_columns ic ON ic.object_id = k.parent_object_id AND ic.index_id = k.unique_index_id
+		JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+		WHERE k.type = 'PK'
+		UNION ALL
+		SELECT s.name, t.name, f.name, 'foreign', c.name, fc.constraint_column_id, rs.name, rt.name, rc.name
+		FROM sys.foreign_keys f
+		JOIN sys.tables t ON t.object_id = f.parent_object_id
+		JOIN sys.schemas s ON s.schema_id = t.schema_id
+		JOIN sys.foreign_key_columns fc ON fc.constraint_object_id = f.object_id
+		JOIN sys.columns c ON c.object_id = fc.parent_object_id AND c.column_id = fc.parent_column_id
+		JOIN sys.tables rt ON rt.object_id = f.referenced_object_id
+		JOIN sys.schemas rs ON rs.schema_id = rt.schema_id
+		JOIN sys.columns rc ON rc.object_id = fc.referenced_object_id AND rc.column_id = fc.referenced_column_id`,
+	"indexes": `SELECT s.name AS schema_name, t.name AS table_name, i.name AS index_name,
+		CAST(i.is_unique AS int) AS is_unique, CAST(i.is_primary_key AS int) AS is_primary,
+		c.name AS column_name, ic.key_ordinal AS position
+		FROM sys.indexes i
+		JOIN sys.tables t ON t.object_id = i.object_id
+		JOIN sys.schemas s ON s.schema_id = t.schema_id
+		JOIN sys.index_columns ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id AND ic.key_ordinal > 0
+		JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+		WHERE i.type > 0`,
+	"procedures": `SELECT ROUTINE_SCHEMA AS schema_name, ROUTINE_NAME AS routine_name, LOWER(ROUTINE_TYPE) AS routine_type
+		FROM INFORMATION_SCHEMA.ROUTINES`,
+}
+
+func (sqlserverDialect) SchemaQuery(object string) string {
+	return sqlserverSchemaQueries[object]
+}
+
+// Result sets with plans have this column name
+const sqlserverShowplanColumn = "Microsoft SQL Server 2005 XML Showplan"
+
+// SHOWPLAN_XML returns the plans instead of running the batch. With analyze the batch
+// is run in a transaction rolled back afterwards, STATISTICS XML adds a result set
+// with the actual plan after each statement
+func (sqlserverDialect) Explain(ctx context.Context, conn *sql.Conn, query string, analyze bool) (*Plan, error) {
+
+	setting := "SHOWPLAN_XML"
+	if analyze {
+		setting = "STATISTICS XML"
+	}
+	if _, err := conn.ExecContext(ctx, "SET "+setting+" ON"); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "SET "+setting+" OFF"); err != nil {
+			discardConn(conn)
+		}
+	}()
+
+	var docs []string
+	read := func(rows *sql.Rows, err error) error {
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for {
+			columns, err := rows.Columns()
+			if err != nil {
+				return err
+			}
+			isPlan := len(columns) == 1 && columns[0] == sqlserverShowplanColumn
+			for rows.Next() {
+				if !isPlan {
+					continue
+				}
+				var doc string
+				if err = rows.Scan(&doc); err != nil {
+					return err
+				}
+				docs = append(docs, doc)
+			}
+			if !rows.NextResultSet() {
+				return rows.Err()
+			}
+		}
+	}
+
+	var err error
+	if analyze {
+		err = explainInTx(ctx, conn, func(tx *sql.Tx) error {
+			return read(tx.QueryContext(ctx, query))
+		})
+	} else {
+		err = read(conn.QueryContext(ctx, query))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseSqlserverPlan(docs)
+
+}
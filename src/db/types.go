@@ -2,30 +2,70 @@ package db
 
 import (
 	"database/sql"
+	"sql-proxy/src/app"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/sync/singleflight"
 )
 
+// Number of lock shards, a power of two
+const shardCount = 32
+
 // Class model to keep open SQL connections in the pool
-// with concurrent read/write access
+// with concurrent read/write access. Connections are sharded by id
+// to reduce lock contention, and indexed by hash to be reused
 type DbList struct {
-	items map[string]DbConn
+	shards   [shardCount]idShard
+	byHash   [shardCount]hashShard
+	creating singleflight.Group // one pool per parameter set
+}
+
+type idShard struct {
+	mu    sync.RWMutex
+	items map[string]*DbConn
+}
+
+type hashShard struct {
 	mu    sync.RWMutex
+	items map[[32]byte]*DbConn
 }
 
-// Keeps SQL Db connection information
+// Keeps SQL Db connection information.
+// Requests take it with DbList.Acquire and must call Release after use,
+// so the pool removed from the list is closed only when no longer used
 type DbConn struct {
-	Hash      [32]byte  // Hash, as sql.DB does not store credentials
-	DB        *sql.DB   // SQL server connection pool (provided by the driver)
-	Timestamp time.Time // Last use
-	Stmt      []DbStmt  // Prepared SQL statements
+	Id      string
+	Hash    [32]byte          // Hash, as sql.DB does not store credentials
+	DB      *sql.DB           // SQL server connection pool (provided by the driver)
+	Dialect Dialect           // Server type specific behaviour
+	Info    DbConnInfo        // Connection parameters, password removed
+	Profile string            // Profile name, to get pool settings
+	Pool    *app.PoolOverride // Pool settings requested by the client
+	Created time.Time
+
+	stmtMu sync.RWMutex
+	stmt   []*DbStmt // Prepared SQL statements
+
+	lastUse   atomic.Int64 // unix nano
+	refs      atomic.Int32 // requests using the connection
+	evicted   atomic.Bool  // removed from the list, to be closed when unused
+	closeOnce sync.Once
 }
 
-// Keeps SQL prepared statement information
+// Keeps SQL prepared statement information, used the same way as DbConn
 type DbStmt struct {
-	Id        string
-	Stmt      *sql.Stmt
-	Timestamp time.Time // Last use
+	Id      string
+	Stmt    *sql.Stmt
+	Query   string  // SQL text
+	Conn    *DbConn // owner, acquired together with the statement
+	Created time.Time
+
+	lastUse   atomic.Int64 // unix nano
+	refs      atomic.Int32
+	evicted   atomic.Bool
+	closeOnce sync.Once
 }
 
 // Keeps SQL connection string information
@@ -37,4 +77,23 @@ type DbConnInfo struct {
 	Password string `json:"password"`
 	DbName   string `json:"db_name"`
 	SSL      bool   `json:"ssl"`
+	ReadOnly bool   `json:"read_only"` // SQLite: open the database file read-only
+	Profile  string `json:"profile"`
+
+	ConnectionString string `json:"connection_string,omitempty"` // OLE DB or ODBC style, parsed into the fields
+
+	ServiceName string `json:"service_name"` // Oracle: service name, db_name if empty
+	SID         string `json:"sid"`          // Oracle: SID instead of service name
+	Charset     string `json:"charset"`      // Firebird: connection charset, UTF8 if empty; DBF: code page, from the file if empty
+	Role        string `json:"role"`         // Firebird: SQL role
+	DSN         string `json:"dsn"`          // ODBC: data source name
+	Driver      string `json:"driver"`       // ODBC: driver name, if no data source name
+
+	TLS             *app.TLSOptions   `json:"tls,omitempty"`    // overrides ssl
+	ConnectTimeout  app.Duration      `json:"connect_timeout"`  // 0 = driver default
+	ReadTimeout     app.Duration      `json:"read_timeout"`     // 0 = driver default
+	ApplicationName string            `json:"application_name"` // shown in the server session list
+	Options         map[string]string `json:"options"`          // driver specific, added to the connection string
+
+	Pool *app.PoolOverride `json:"pool,omitempty"` // limited by the server pool settings
 }
@@ -0,0 +1,35 @@
+package db
+
+import "database/sql"
+
+// Converts a driver specific value to the one written to JSON
+type ValueDecoder func(v any) any
+
+// Returns value decoders for the result columns of the server type
+func ValueDecoders(dbType string, rows *sql.Rows) ([]ValueDecoder, error) {
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	dialect, ok := GetDialect(dbType)
+	decoders := make([]ValueDecoder, len(columnTypes))
+	for i, columnType := range columnTypes {
+		if ok {
+			decoders[i] = dialect.Decoder(columnType)
+		} else {
+			decoders[i] = decodeDefault
+		}
+	}
+	return decoders, nil
+
+}
+
+// Text and binary data are written as strings
+func decodeDefault(v any) any {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
@@ -2,5 +2,4 @@ package db
 
 var (
 	Handler DbList
-	MaxRows uint32 = 10000
 )
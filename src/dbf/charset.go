@@ -0,0 +1,60 @@
+package dbf
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Code pages by name, as accepted in the connection charset
+var charsets = map[string]*charmap.Charmap{
+	"CP437":  charmap.CodePage437,
+	"CP850":  charmap.CodePage850,
+	"CP852":  charmap.CodePage852,
+	"CP866":  charmap.CodePage866,
+	"CP1250": charmap.Windows1250,
+	"CP1251": charmap.Windows1251,
+	"CP1252": charmap.Windows1252,
+}
+
+// Code pages by the language driver id in the DBF header
+var languageDrivers = map[byte]*charmap.Charmap{
+	0x01: charmap.CodePage437,
+	0x02: charmap.CodePage850,
+	0x03: charmap.Windows1252,
+	0x26: charmap.CodePage866,
+	0x64: charmap.CodePage852,
+	0x65: charmap.CodePage866,
+	0x57: charmap.Windows1251,
+	0xC8: charmap.Windows1250,
+	0xC9: charmap.Windows1251,
+}
+
+// Returns the code page by name: CP866, WIN1251, 1251... Empty name means
+// the code page is taken from the file header
+func ParseCharset(name string) (*charmap.Charmap, error) {
+
+	if name == "" {
+		return nil, nil
+	}
+	key := strings.ToUpper(name)
+	key = strings.TrimPrefix(key, "WINDOWS-")
+	key = strings.TrimPrefix(key, "WIN")
+	key = strings.TrimPrefix(key, "CP")
+	if cm, ok := charsets["CP"+key]; ok {
+		return cm, nil
+	}
+	return nil, fmt.Errorf("unknown DBF charset '%s'", name)
+
+}
+
+// Files with unknown language driver, e.g. 1C 7.7 ones with zero id, use the default
+var defaultCharset = charmap.Windows1251
+
+func languageDriverCharset(id byte) *charmap.Charmap {
+	if cm, ok := languageDrivers[id]; ok {
+		return cm
+	}
+	return defaultCharset
+}
@@ -0,0 +1,336 @@
+// Read-only database/sql driver for DBF (dBase, FoxPro, Clipper) tables.
+// The data source name is a directory of .dbf files with optional charset:
+// /data/1c?charset=CP866. Table names in queries are file names without extension
+package dbf
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+var ErrReadOnly = errors.New("DBF data source is read-only")
+
+// Records are checked for query cancellation with this period
+const cancelCheckPeriod = 1000
+
+var tableName = regexp.MustCompile(`^[\p{L}\p{N}_$-]+$`)
+
+func init() {
+	sql.Register("dbf", &Driver{})
+}
+
+type Driver struct{}
+
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+
+	dir, options, _ := strings.Cut(dsn, "?")
+	values, err := url.ParseQuery(options)
+	if err != nil {
+		return nil, err
+	}
+	charset, err := ParseCharset(values.Get("charset"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{dir: dir, charset: charset}, nil
+
+}
+
+type conn struct {
+	dir     string
+	charset *charmap.Charmap // nil if taken from the files
+}
+
+func (c *conn) Prepare(sqlQuery string) (driver.Stmt, error) {
+	q, err := parseQuery(sqlQuery)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{conn: c, query: q}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, ErrReadOnly
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	info, err := os.Stat(c.dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", c.dir)
+	}
+	return nil
+}
+
+type stmt struct {
+	conn  *conn
+	query *query
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return s.query.params
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, ErrReadOnly
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+
+	q := s.query
+	if !tableName.MatchString(q.table) {
+		return nil, fmt.Errorf("invalid table name %s", q.table)
+	}
+	path := findFile(s.conn.dir, q.table, ".dbf")
+	if path == "" {
+		return nil, fmt.Errorf("table %s not found", q.table)
+	}
+
+	t, err := openTable(path, s.conn.charset)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newRows(ctx, t, q, args)
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	return r, nil
+
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+// Query result. Without ORDER BY records are read as rows are fetched,
+// otherwise all matching records are read and sorted first
+type rows struct {
+	ctx     context.Context
+	t       *table
+	q       *query
+	scope   *scope
+	columns []string
+	fields  []*field
+	sorted  [][]driver.Value // nil if not sorted
+	fetched int
+}
+
+func newRows(ctx context.Context, t *table, q *query, args []driver.NamedValue) (*rows, error) {
+
+	byName := make(map[string]*field, len(t.fields))
+	for _, f := range t.fields {
+		byName[strings.ToUpper(f.Name)] = f
+	}
+
+	r := &rows{ctx: ctx, t: t, q: q, scope: &scope{t: t}}
+	for _, arg := range args {
+		r.scope.params = append(r.scope.params, arg.Value)
+	}
+
+	if len(q.columns) == 0 {
+		for _, f := range t.fields {
+			r.columns = append(r.columns, f.Name)
+			r.fields = append(r.fields, f)
+		}
+	} else {
+		for _, col := range q.columns {
+			f, ok := byName[strings.ToUpper(col.name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown column %s", col.name)
+			}
+			name := f.Name
+			if col.alias != "" {
+				name = col.alias
+			}
+			r.columns = append(r.columns, name)
+			r.fields = append(r.fields, f)
+		}
+	}
+
+	if q.where != nil {
+		if err := resolve(q.where, byName); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(q.orderBy) > 0 {
+		if err := r.sort(byName); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return r.t.Close()
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+
+	if r.q.limit >= 0 && r.fetched >= r.q.limit {
+		return io.EOF
+	}
+
+	if r.sorted != nil {
+		if r.fetched >= len(r.sorted) {
+			return io.EOF
+		}
+		copy(dest, r.sorted[r.fetched][:len(dest)])
+		r.fetched++
+		return nil
+	}
+
+	if err := r.nextMatch(); err != nil {
+		return err
+	}
+	for i, f := range r.fields {
+		v, err := r.t.value(f)
+		if err != nil {
+			return err
+		}
+		dest[i] = v
+	}
+	r.fetched++
+	return nil
+
+}
+
+// Moves to the next record matching the condition
+func (r *rows) nextMatch() error {
+
+	for {
+		if r.t.read%cancelCheckPeriod == 0 {
+			if err := r.ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if err := r.t.next(); err != nil {
+			return err
+		}
+		if r.q.where == nil {
+			return nil
+		}
+		ok, err := evalBool(r.q.where, r.scope)
+		if err != nil {
+			return err
+		}
+		if ok != nil && *ok {
+			return nil
+		}
+	}
+
+}
+
+// Reads all matching records with sort keys appended after the selected values
+func (r *rows) sort(byName map[string]*field) error {
+
+	// Keys may refer to aliases
+	keys := make([]*field, len(r.q.orderBy))
+	for i, key := range r.q.orderBy {
+		f, ok := byName[strings.ToUpper(key.name)]
+		if !ok {
+			for j, col := range r.q.columns {
+				if strings.EqualFold(col.alias, key.name) {
+					f, ok = r.fields[j], true
+					break
+				}
+			}
+		}
+		if !ok {
+			return fmt.Errorf("unknown column %s", key.name)
+		}
+		keys[i] = f
+	}
+
+	fields := slices.Concat(r.fields, keys)
+	r.sorted = [][]driver.Value{}
+	for {
+		err := r.nextMatch()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		row := make([]driver.Value, 0, len(fields))
+		for _, f := range fields {
+			v, err := r.t.value(f)
+			if err != nil {
+				return err
+			}
+			row = append(row, v)
+		}
+		r.sorted = append(r.sorted, row)
+	}
+
+	n := len(r.fields)
+	var sortErr error
+	slices.SortStableFunc(r.sorted, func(a, b []driver.Value) int {
+		for i, key := range r.q.orderBy {
+			c := compareNullsFirst(a[n+i], b[n+i], &sortErr)
+			if key.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	return sortErr
+
+}
+
+func compareNullsFirst(a, b any, sortErr *error) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	c, err := compare(a, b)
+	if err != nil && *sortErr == nil {
+		*sortErr = err
+	}
+	return c
+}
@@ -0,0 +1,396 @@
+package dbf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Current record and query parameters
+type scope struct {
+	t      *table
+	params []any
+}
+
+// Condition or value. Conditions return true, false or nil if unknown (NULL)
+type expr interface {
+	eval(s *scope) (any, error)
+}
+
+type literal struct {
+	v any
+}
+
+type param struct {
+	index int
+}
+
+type columnRef struct {
+	name  string
+	field *field // resolved when the table is opened
+}
+
+type logicalExpr struct {
+	or          bool
+	left, right expr
+}
+
+type notExpr struct {
+	e expr
+}
+
+type compareExpr struct {
+	op          string
+	left, right expr
+}
+
+type likeExpr struct {
+	e, pattern expr
+	not        bool
+	re         *regexp.Regexp // cached for literal patterns
+}
+
+type isNullExpr struct {
+	e   expr
+	not bool
+}
+
+type inExpr struct {
+	e    expr
+	list []expr
+	not  bool
+}
+
+type betweenExpr struct {
+	e, low, high expr
+	not          bool
+}
+
+func (e *literal) eval(*scope) (any, error) {
+	return e.v, nil
+}
+
+func (e *param) eval(s *scope) (any, error) {
+	if e.index >= len(s.params) {
+		return nil, fmt.Errorf("parameter %d is not set", e.index+1)
+	}
+	return s.params[e.index], nil
+}
+
+func (e *columnRef) eval(s *scope) (any, error) {
+	return s.t.value(e.field)
+}
+
+func (e *logicalExpr) eval(s *scope) (any, error) {
+
+	left, err := evalBool(e.left, s)
+	if err != nil {
+		return nil, err
+	}
+	// Short circuit
+	if left != nil && *left == e.or {
+		return e.or, nil
+	}
+	right, err := evalBool(e.right, s)
+	if err != nil {
+		return nil, err
+	}
+	if right != nil && *right == e.or {
+		return e.or, nil
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	return !e.or, nil
+
+}
+
+func (e *notExpr) eval(s *scope) (any, error) {
+	v, err := evalBool(e.e, s)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return !*v, nil
+}
+
+func (e *compareExpr) eval(s *scope) (any, error) {
+
+	left, right, err := evalPair(e.left, e.right, s)
+	if err != nil || left == nil || right == nil {
+		return nil, err
+	}
+	c, err := compare(left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "=":
+		return c == 0, nil
+	case "<>", "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+
+}
+
+func (e *likeExpr) eval(s *scope) (any, error) {
+
+	v, pattern, err := evalPair(e.e, e.pattern, s)
+	if err != nil || v == nil || pattern == nil {
+		return nil, err
+	}
+	text, ok := v.(string)
+	patternText, ok2 := pattern.(string)
+	if !ok || !ok2 {
+		return nil, fmt.Errorf("LIKE is applicable to strings only")
+	}
+
+	re := e.re
+	if re == nil {
+		if re, err = likeRegexp(patternText); err != nil {
+			return nil, err
+		}
+		if _, isLiteral := e.pattern.(*literal); isLiteral {
+			e.re = re
+		}
+	}
+	return re.MatchString(text) != e.not, nil
+
+}
+
+func (e *isNullExpr) eval(s *scope) (any, error) {
+	v, err := e.e.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	return (v == nil) != e.not, nil
+}
+
+func (e *inExpr) eval(s *scope) (any, error) {
+
+	v, err := e.e.eval(s)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	unknown := false
+	for _, item := range e.list {
+		iv, err := item.eval(s)
+		if err != nil {
+			return nil, err
+		}
+		if iv == nil {
+			unknown = true
+			continue
+		}
+		c, err := compare(v, iv)
+		if err != nil {
+			return nil, err
+		}
+		if c == 0 {
+			return !e.not, nil
+		}
+	}
+	if unknown {
+		return nil, nil
+	}
+	return e.not, nil
+
+}
+
+func (e *betweenExpr) eval(s *scope) (any, error) {
+
+	v, err := e.e.eval(s)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	low, high, err := evalPair(e.low, e.high, s)
+	if err != nil || low == nil || high == nil {
+		return nil, err
+	}
+	cl, err := compare(v, low)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := compare(v, high)
+	if err != nil {
+		return nil, err
+	}
+	return (cl >= 0 && ch <= 0) != e.not, nil
+
+}
+
+func evalPair(a, b expr, s *scope) (any, any, error) {
+	av, err := a.eval(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	bv, err := b.eval(s)
+	return av, bv, err
+}
+
+func evalBool(e expr, s *scope) (*bool, error) {
+	v, err := e.eval(s)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("condition expected, found value %v", v)
+	}
+	return &b, nil
+}
+
+// Compares values of the same kind. Strings are compared without the
+// trailing spaces, dates with strings like '2006-01-02' or '20060102',
+// numbers with numeric strings
+func compare(a, b any) (int, error) {
+
+	switch x := a.(type) {
+	case string:
+		switch y := b.(type) {
+		case string:
+			return strings.Compare(strings.TrimRight(x, " "), strings.TrimRight(y, " ")), nil
+		case time.Time, int64, float64:
+			c, err := compare(y, x)
+			return -c, err
+		}
+	case int64, float64:
+		if y, ok := toFloat(b); ok {
+			xf, _ := toFloat(x)
+			switch {
+			case xf < y:
+				return -1, nil
+			case xf > y:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, nil
+			case !x:
+				return -1, nil
+			}
+			return 1, nil
+		}
+	case time.Time:
+		switch y := b.(type) {
+		case time.Time:
+			return x.Compare(y), nil
+		case string:
+			t, err := parseTime(y)
+			if err != nil {
+				return 0, err
+			}
+			return x.Compare(t), nil
+		}
+	case []byte:
+		return compare(string(x), b)
+	}
+
+	if y, ok := b.([]byte); ok {
+		return compare(a, string(y))
+	}
+	return 0, fmt.Errorf("can't compare %T with %T", a, b)
+
+}
+
+func toFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	case string:
+		// Parameters may be passed as strings
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "20060102", "2006-01-02 15:04:05", time.RFC3339} {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date '%s'", s)
+}
+
+// SQL LIKE pattern: % is any string, _ is any character
+func likeRegexp(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^(?s)")
+	for _, r := range strings.TrimRight(pattern, " ") {
+		switch r {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString(" *$")
+	return regexp.Compile(sb.String())
+}
+
+// Binds column references to the table fields
+func resolve(e expr, fields map[string]*field) error {
+
+	switch x := e.(type) {
+	case *columnRef:
+		f, ok := fields[strings.ToUpper(x.name)]
+		if !ok {
+			return fmt.Errorf("unknown column %s", x.name)
+		}
+		x.field = f
+	case *logicalExpr:
+		if err := resolve(x.left, fields); err != nil {
+			return err
+		}
+		return resolve(x.right, fields)
+	case *notExpr:
+		return resolve(x.e, fields)
+	case *compareExpr:
+		if err := resolve(x.left, fields); err != nil {
+			return err
+		}
+		return resolve(x.right, fields)
+	case *likeExpr:
+		if err := resolve(x.e, fields); err != nil {
+			return err
+		}
+		return resolve(x.pattern, fields)
+	case *isNullExpr:
+		return resolve(x.e, fields)
+	case *inExpr:
+		for _, item := range append([]expr{x.e}, x.list...) {
+			if err := resolve(item, fields); err != nil {
+				return err
+			}
+		}
+	case *betweenExpr:
+		for _, item := range []expr{x.e, x.low, x.high} {
+			if err := resolve(item, fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+
+}
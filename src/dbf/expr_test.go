@@ -0,0 +1,103 @@
+package dbf
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		cond   string
+		params []any
+		want   any // true, false or nil for unknown
+		err    string
+	}{
+		// LIKE
+		{cond: "'Ivanov' LIKE 'Iv%'", want: true},
+		{cond: "'Ivanov' LIKE 'iv%'", want: false},
+		{cond: "'Ivanov' LIKE 'Ivan_v'", want: true},
+		{cond: "'Ivanov    ' LIKE 'Ivanov'", want: true},
+		{cond: "'abc' LIKE 'a.c'", want: false},
+		{cond: "'a.c' LIKE 'a.c'", want: true},
+		{cond: "'100%' LIKE '100%'", want: true},
+		{cond: "'abc' NOT LIKE 'a%'", want: false},
+		{cond: "? LIKE ?", params: []any{"line1\nline2", "line1%"}, want: true},
+		{cond: "NULL LIKE 'a%'", want: nil},
+		{cond: "'a' LIKE NULL", want: nil},
+		{cond: "1 LIKE '1'", err: "applicable to strings only"},
+
+		// NULL is unknown, AND and OR follow three-valued logic
+		{cond: "NULL = 1", want: nil},
+		{cond: "NULL <> NULL", want: nil},
+		{cond: "NULL IS NULL", want: true},
+		{cond: "1 IS NOT NULL", want: true},
+		{cond: "? IS NULL", params: []any{nil}, want: true},
+		{cond: "NULL = 1 OR 1 = 1", want: true},
+		{cond: "NULL = 1 OR 1 = 0", want: nil},
+		{cond: "NULL = 1 AND 1 = 0", want: false},
+		{cond: "NULL = 1 AND 1 = 1", want: nil},
+		{cond: "NOT (NULL = 1)", want: nil},
+		{cond: "NOT 1 = 0", want: true},
+		{cond: "1 IN (1, NULL)", want: true},
+		{cond: "1 IN (2, NULL)", want: nil},
+		{cond: "1 NOT IN (2, NULL)", want: nil},
+		{cond: "1 NOT IN (2, 3)", want: true},
+		{cond: "NULL IN (1)", want: nil},
+		{cond: "NULL BETWEEN 1 AND 2", want: nil},
+		{cond: "2 BETWEEN 1 AND NULL", want: nil},
+		{cond: "2 BETWEEN 1 AND 3", want: true},
+		{cond: "2 NOT BETWEEN 1 AND 3", want: false},
+
+		// Mixed types
+		{cond: "1 = 1.0", want: true},
+		{cond: "2 > 1.5", want: true},
+		{cond: "-1 < 0", want: true},
+		{cond: "42 = ?", params: []any{"42"}, want: true},
+		{cond: "? = 42", params: []any{" 42 "}, want: true},
+		{cond: "? < 5", params: []any{"10"}, want: false},
+		{cond: "? = 1", params: []any{"one"}, err: "can't compare"},
+		{cond: "'abc  ' = 'abc'", want: true},
+		{cond: "'a' < 'b'", want: true},
+		{cond: "? = 'abc'", params: []any{[]byte("abc")}, want: true},
+		{cond: "? = '2024-01-02'", params: []any{date}, want: true},
+		{cond: "? > '20231231'", params: []any{date}, want: true},
+		{cond: "'2024-01-03' > ?", params: []any{date}, want: true},
+		{cond: "? = 'tomorrow'", params: []any{date}, err: "invalid date"},
+		{cond: "TRUE = ?", params: []any{true}, want: true},
+		{cond: "FALSE < TRUE", want: true},
+		{cond: "TRUE = 1", err: "can't compare bool with int64"},
+
+		{cond: "'x' = ?", err: "parameter 1 is not set"},
+		{cond: "1 AND 1 = 1", err: "condition expected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cond, func(t *testing.T) {
+			q, err := parseQuery("SELECT * FROM t WHERE " + tt.cond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := evalBool(q.where, &scope{params: tt.params})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case got == nil && tt.want != nil:
+				t.Errorf("got NULL, want %v", tt.want)
+			case got != nil && *got != tt.want:
+				t.Errorf("got %v, want %v", *got, tt.want)
+			}
+		})
+	}
+
+}
@@ -0,0 +1,117 @@
+package dbf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Memo larger than this is considered a file corruption
+const maxMemoSize = 64 << 20
+
+// dBase III memos are terminated by this byte, blocks have a fixed size
+const (
+	memoTerminator  = 0x1A
+	dbaseBlockSize  = 512
+	foxTextBlock    = 1
+	dbase4BlockMark = 0x0008FFFF
+)
+
+// Memo file: FoxPro .fpt or dBase .dbt
+type memoFile struct {
+	file      *os.File
+	blockSize int64
+	fox       bool
+}
+
+func openMemo(path string, version byte) (*memoFile, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 512)
+	if _, err = io.ReadFull(file, header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: invalid memo file header", filepath.Base(path))
+	}
+
+	m := &memoFile{file: file}
+	if strings.EqualFold(filepath.Ext(path), ".fpt") {
+		m.fox = true
+		m.blockSize = int64(binary.BigEndian.Uint16(header[6:8]))
+	} else if version == 0x8B || version == 0x8E {
+		m.blockSize = int64(binary.LittleEndian.Uint16(header[20:22]))
+	}
+	if m.blockSize == 0 {
+		m.blockSize = dbaseBlockSize
+	}
+
+	return m, nil
+
+}
+
+// Reads the memo by block number, tells if it is text
+func (m *memoFile) read(block int64) ([]byte, bool, error) {
+
+	offset := block * m.blockSize
+
+	if m.fox {
+		header := make([]byte, 8)
+		if _, err := m.file.ReadAt(header, offset); err != nil {
+			return nil, false, errors.New("memo block out of file")
+		}
+		blockType := binary.BigEndian.Uint32(header[:4])
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		data, err := m.readAt(offset+8, size)
+		return data, blockType == foxTextBlock, err
+	}
+
+	// dBase IV blocks start with a mark and length, including this header
+	header := make([]byte, 8)
+	if _, err := m.file.ReadAt(header, offset); err == nil &&
+		binary.LittleEndian.Uint32(header[:4]) == dbase4BlockMark {
+		size := int64(binary.LittleEndian.Uint32(header[4:])) - 8
+		data, err := m.readAt(offset+8, size)
+		return data, true, err
+	}
+
+	// dBase III: text up to the terminator
+	var buf bytes.Buffer
+	chunk := make([]byte, dbaseBlockSize)
+	for buf.Len() < maxMemoSize {
+		n, err := m.file.ReadAt(chunk, offset)
+		if i := bytes.IndexByte(chunk[:n], memoTerminator); i >= 0 {
+			buf.Write(chunk[:i])
+			return buf.Bytes(), true, nil
+		}
+		buf.Write(chunk[:n])
+		if err != nil {
+			return buf.Bytes(), true, nil
+		}
+		offset += int64(n)
+	}
+	return nil, false, errors.New("memo is too large")
+
+}
+
+func (m *memoFile) readAt(offset, size int64) ([]byte, error) {
+	if size < 0 || size > maxMemoSize {
+		return nil, errors.New("invalid memo size")
+	}
+	data := make([]byte, size)
+	if _, err := m.file.ReadAt(data, offset); err != nil {
+		return nil, errors.New("memo block out of file")
+	}
+	return data, nil
+}
+
+func (m *memoFile) Close() error {
+	return m.file.Close()
+}
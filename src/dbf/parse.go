@@ -0,0 +1,462 @@
+package dbf
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Supported SQL subset:
+//
+//	SELECT [TOP n] * | column [[AS] alias], ... FROM table
+//	[WHERE condition] [ORDER BY column [ASC|DESC], ...] [LIMIT n]
+//
+// Conditions: AND, OR, NOT, parentheses, = <> != < <= > >=, [NOT] LIKE,
+// IS [NOT] NULL, [NOT] IN (...), [NOT] BETWEEN ... AND ...
+// Values: numbers, 'strings', TRUE, FALSE, NULL and ? parameters
+type query struct {
+	table   string
+	columns []column // empty means all
+	where   expr
+	orderBy []orderKey
+	limit   int // -1 if not limited
+	params  int
+}
+
+type column struct {
+	name  string
+	alias string
+}
+
+type orderKey struct {
+	name string
+	desc bool
+}
+
+type tokenKind int
+
+const (
+	tokEnd tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokParam
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	params int
+}
+
+func parseQuery(sqlQuery string) (*query, error) {
+
+	tokens, err := tokenize(sqlQuery)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+
+	q := &query{limit: -1}
+	if !p.keyword("SELECT") {
+		return nil, errors.New("only SELECT queries are supported by DBF data source")
+	}
+	if p.keyword("TOP") {
+		if q.limit, err = p.integer(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !p.symbol("*") {
+		for {
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			col := column{name: name}
+			if p.keyword("AS") {
+				if col.alias, err = p.ident(); err != nil {
+					return nil, err
+				}
+			} else if p.peek().kind == tokIdent && !p.isKeyword("FROM") {
+				col.alias, _ = p.ident()
+			}
+			q.columns = append(q.columns, col)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+
+	if !p.keyword("FROM") {
+		return nil, p.unexpected("FROM")
+	}
+	if q.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+
+	if p.keyword("WHERE") {
+		if q.where, err = p.orExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.keyword("ORDER") {
+		if !p.keyword("BY") {
+			return nil, p.unexpected("BY")
+		}
+		for {
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			key := orderKey{name: name}
+			if p.keyword("DESC") {
+				key.desc = true
+			} else {
+				p.keyword("ASC")
+			}
+			q.orderBy = append(q.orderBy, key)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+
+	if p.keyword("LIMIT") {
+		if q.limit, err = p.integer(); err != nil {
+			return nil, err
+		}
+	}
+
+	p.symbol(";")
+	if p.peek().kind != tokEnd {
+		return nil, p.unexpected("end of query")
+	}
+
+	q.params = p.params
+	return q, nil
+
+}
+
+func (p *parser) orExpr() (expr, error) {
+	left, err := p.andExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.andExpr()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{or: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) andExpr() (expr, error) {
+	left, err := p.notExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.notExpr()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) notExpr() (expr, error) {
+	if p.keyword("NOT") {
+		e, err := p.notExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{e: e}, nil
+	}
+	return p.predicate()
+}
+
+func (p *parser) predicate() (expr, error) {
+
+	if p.symbol("(") {
+		e, err := p.orExpr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.symbol(")") {
+			return nil, p.unexpected(")")
+		}
+		return e, nil
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.keyword("IS") {
+		not := p.keyword("NOT")
+		if !p.keyword("NULL") {
+			return nil, p.unexpected("NULL")
+		}
+		return &isNullExpr{e: left, not: not}, nil
+	}
+
+	not := p.keyword("NOT")
+	switch {
+	case p.keyword("LIKE"):
+		pattern, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return &likeExpr{e: left, pattern: pattern, not: not}, nil
+	case p.keyword("IN"):
+		if !p.symbol("(") {
+			return nil, p.unexpected("(")
+		}
+		in := &inExpr{e: left, not: not}
+		for {
+			item, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, item)
+			if !p.symbol(",") {
+				break
+			}
+		}
+		if !p.symbol(")") {
+			return nil, p.unexpected(")")
+		}
+		return in, nil
+	case p.keyword("BETWEEN"):
+		low, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword("AND") {
+			return nil, p.unexpected("AND")
+		}
+		high, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return &betweenExpr{e: left, low: low, high: high, not: not}, nil
+	case not:
+		return nil, p.unexpected("LIKE, IN or BETWEEN")
+	}
+
+	for _, op := range []string{"=", "<>", "!=", "<=", ">=", "<", ">"} {
+		if p.symbol(op) {
+			right, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			return &compareExpr{op: op, left: left, right: right}, nil
+		}
+	}
+
+	// Logical field alone
+	return left, nil
+
+}
+
+func (p *parser) operand() (expr, error) {
+
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		if v, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &literal{v: v}, nil
+		}
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", t.text)
+		}
+		return &literal{v: v}, nil
+	case tokString:
+		return &literal{v: t.text}, nil
+	case tokParam:
+		p.params++
+		return &param{index: p.params - 1}, nil
+	case tokSymbol:
+		if t.text == "-" && p.peek().kind == tokNumber {
+			e, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			switch v := e.(*literal).v.(type) {
+			case int64:
+				return &literal{v: -v}, nil
+			case float64:
+				return &literal{v: -v}, nil
+			}
+		}
+	case tokIdent:
+		switch strings.ToUpper(t.text) {
+		case "NULL":
+			return &literal{v: nil}, nil
+		case "TRUE":
+			return &literal{v: true}, nil
+		case "FALSE":
+			return &literal{v: false}, nil
+		}
+		return &columnRef{name: t.text}, nil
+	}
+	p.pos--
+	return nil, p.unexpected("value or column")
+
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) keyword(word string) bool {
+	if p.isKeyword(word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) symbol(s string) bool {
+	t := p.peek()
+	if t.kind == tokSymbol && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.peek()
+	if t.kind != tokIdent {
+		return "", p.unexpected("name")
+	}
+	p.pos++
+	return t.text, nil
+}
+
+func (p *parser) integer() (int, error) {
+	t := p.peek()
+	if t.kind == tokNumber {
+		if v, err := strconv.Atoi(t.text); err == nil && v >= 0 {
+			p.pos++
+			return v, nil
+		}
+	}
+	return 0, p.unexpected("row count")
+}
+
+func (p *parser) unexpected(expected string) error {
+	t := p.peek()
+	if t.kind == tokEnd {
+		return fmt.Errorf("syntax error: %s expected at the end of query", expected)
+	}
+	return fmt.Errorf("syntax error: %s expected, found '%s'", expected, t.text)
+}
+
+func tokenize(s string) ([]token, error) {
+
+	var tokens []token
+	runes := []rune(s)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'':
+			// Quotes inside strings are doubled
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, errors.New("syntax error: unterminated string")
+				}
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						sb.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, token{tokString, sb.String()})
+		case r == '"' || r == '[' || r == '`':
+			closing := map[rune]rune{'"': '"', '[': ']', '`': '`'}[r]
+			start := i + 1
+			i = start
+			for i < len(runes) && runes[i] != closing {
+				i++
+			}
+			if i >= len(runes) {
+				return nil, errors.New("syntax error: unterminated name")
+			}
+			tokens = append(tokens, token{tokIdent, string(runes[start:i])})
+			i++
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, string(runes[start:i])})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, string(runes[start:i])})
+		case r == '?':
+			tokens = append(tokens, token{tokParam, "?"})
+			i++
+		default:
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				if two == "<>" || two == "!=" || two == "<=" || two == ">=" {
+					tokens = append(tokens, token{tokSymbol, two})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("*,()=<>;-", r) {
+				return nil, fmt.Errorf("syntax error: unexpected '%c'", r)
+			}
+			tokens = append(tokens, token{tokSymbol, string(r)})
+			i++
+		}
+	}
+
+	return append(tokens, token{kind: tokEnd}), nil
+
+}
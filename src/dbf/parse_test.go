@@ -0,0 +1,81 @@
+package dbf
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+
+	tests := []struct {
+		sql     string
+		table   string
+		columns []column
+		orderBy []orderKey
+		limit   int
+		params  int
+		where   bool
+		err     string
+	}{
+		{sql: "SELECT * FROM people", table: "people", limit: -1},
+		{sql: "select top 5 name, age from People;", table: "People", limit: 5,
+			columns: []column{{name: "name"}, {name: "age"}}},
+		{sql: "SELECT name AS n, age years, [born] FROM [my table]", table: "my table", limit: -1,
+			columns: []column{{name: "name", alias: "n"}, {name: "age", alias: "years"}, {name: "born"}}},
+		{sql: "SELECT * FROM people WHERE age > ? AND name LIKE ?", table: "people", limit: -1, params: 2, where: true},
+		{sql: "SELECT * FROM people WHERE name = 'O''Brien' OR NOT (age BETWEEN -1 AND 2.5)", table: "people", limit: -1, where: true},
+		{sql: "SELECT * FROM people WHERE code NOT IN ('a', ?, NULL) AND notes IS NOT NULL", table: "people", limit: -1, params: 1, where: true},
+		{sql: "SELECT * FROM people ORDER BY age DESC, name ASC, id LIMIT 10", table: "people", limit: 10,
+			orderBy: []orderKey{{name: "age", desc: true}, {name: "name"}, {name: "id"}}},
+		{sql: "SELECT TOP 3 * FROM people LIMIT 7", table: "people", limit: 7},
+
+		{sql: "UPDATE people SET age = 1", err: "only SELECT queries"},
+		{sql: "SELECT * people", err: "FROM expected, found 'people'"},
+		{sql: "SELECT TOP -1 * FROM people", err: "row count expected"},
+		{sql: "SELECT * FROM people LIMIT", err: "row count expected at the end of query"},
+		{sql: "SELECT * FROM people WHERE name = 'abc", err: "unterminated string"},
+		{sql: "SELECT * FROM [people", err: "unterminated name"},
+		{sql: "SELECT * FROM people ORDER age", err: "BY expected"},
+		{sql: "SELECT * FROM people LIMIT 5 offset", err: "end of query expected"},
+		{sql: "SELECT * FROM people WHERE age NOT = 1", err: "LIKE, IN or BETWEEN expected"},
+		{sql: "SELECT * FROM people WHERE (age = 1", err: ") expected"},
+		{sql: "SELECT * FROM people WHERE age BETWEEN 1 OR 2", err: "AND expected"},
+		{sql: "SELECT * FROM people WHERE age @ 1", err: "unexpected '@'"},
+		{sql: "SELECT * FROM people WHERE age = ", err: "value or column expected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			q, err := parseQuery(tt.sql)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.table != tt.table {
+				t.Errorf("table %q, want %q", q.table, tt.table)
+			}
+			if !slices.Equal(q.columns, tt.columns) {
+				t.Errorf("columns %v, want %v", q.columns, tt.columns)
+			}
+			if !slices.Equal(q.orderBy, tt.orderBy) {
+				t.Errorf("order by %v, want %v", q.orderBy, tt.orderBy)
+			}
+			if q.limit != tt.limit {
+				t.Errorf("limit %d, want %d", q.limit, tt.limit)
+			}
+			if q.params != tt.params {
+				t.Errorf("params %d, want %d", q.params, tt.params)
+			}
+			if (q.where != nil) != tt.where {
+				t.Errorf("where %v, want %v", q.where, tt.where)
+			}
+		})
+	}
+
+}
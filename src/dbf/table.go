@@ -0,0 +1,387 @@
+package dbf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Visual FoxPro field flags
+const (
+	flagNullable = 0x02
+	flagBinary   = 0x04
+)
+
+// Unix epoch as julian day number, used by FoxPro datetime fields
+const julianUnixEpoch = 2440588
+
+// Lengths of fixed size field types. B is a FoxPro double or a dBase memo block number
+var fixedLengths = map[byte][]int{
+	'L': {1},
+	'D': {8},
+	'I': {4},
+	'+': {4},
+	'Y': {8},
+	'T': {8},
+	'B': {8, 10},
+}
+
+// Table field description
+type field struct {
+	Name     string
+	Type     byte
+	Length   int
+	Decimals int
+	offset   int // in the record, after the deletion flag
+	nullBit  int // bit in _NullFlags, -1 if not nullable
+	binary   bool
+}
+
+// DBF file opened for reading
+type table struct {
+	file       *os.File
+	reader     *bufio.Reader
+	version    byte
+	langDriver byte // code page id
+	records    uint32
+	headerLen  int
+	recordLen  int
+	fields     []*field // visible fields, _NullFlags excluded
+	nullFlags  *field
+	charset    *charmap.Charmap // nil if text is not converted
+	memo       *memoFile
+	memoPath   string
+	read       uint32 // records read
+	record     []byte
+}
+
+// Opens the table file. The charset given overrides the file language driver
+func openTable(path string, charset *charmap.Charmap) (*table, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &table{file: file, reader: bufio.NewReaderSize(file, 64<<10)}
+	if err = t.readHeader(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+
+	t.charset = charset
+	if t.charset == nil {
+		t.charset = languageDriverCharset(t.langDriver)
+	}
+
+	for _, f := range t.fields {
+		if isMemo(f) {
+			t.memoPath = findFile(filepath.Dir(path), strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), ".fpt", ".dbt")
+			break
+		}
+	}
+
+	// Records follow the header
+	if _, err = file.Seek(int64(t.headerLen), io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	t.reader.Reset(file)
+	t.record = make([]byte, t.recordLen)
+
+	return t, nil
+
+}
+
+func (t *table) readHeader() error {
+
+	header := make([]byte, 32)
+	if _, err := io.ReadFull(t.reader, header); err != nil {
+		return errors.New("not a DBF file")
+	}
+
+	t.version = header[0]
+	t.records = binary.LittleEndian.Uint32(header[4:8])
+	t.headerLen = int(binary.LittleEndian.Uint16(header[8:10]))
+	t.recordLen = int(binary.LittleEndian.Uint16(header[10:12]))
+	t.langDriver = header[29]
+	if t.headerLen < 33 || t.recordLen < 1 {
+		return errors.New("invalid DBF header")
+	}
+
+	offset := 0
+	nullBit := 0
+	for pos := 32; pos+32 <= t.headerLen; pos += 32 {
+		descriptor := make([]byte, 32)
+		if _, err := io.ReadFull(t.reader, descriptor[:1]); err != nil {
+			return errors.New("truncated DBF header")
+		}
+		if descriptor[0] == 0x0D {
+			break
+		}
+		if _, err := io.ReadFull(t.reader, descriptor[1:]); err != nil {
+			return errors.New("truncated DBF header")
+		}
+
+		name, _, _ := strings.Cut(string(descriptor[:11]), "\x00")
+		f := &field{
+			Name:     strings.TrimSpace(name),
+			Type:     descriptor[11],
+			Length:   int(descriptor[16]),
+			Decimals: int(descriptor[17]),
+			offset:   offset,
+			nullBit:  -1,
+			binary:   descriptor[18]&flagBinary != 0,
+		}
+		// Character fields longer than 255 keep the high byte in decimals (Clipper)
+		if f.Type == 'C' && t.version != 0x30 && t.version != 0x31 && t.version != 0x32 {
+			f.Length += f.Decimals << 8
+			f.Decimals = 0
+		}
+		if lengths, ok := fixedLengths[f.Type]; ok && !slices.Contains(lengths, f.Length) {
+			return fmt.Errorf("field %s: invalid length %d for type %c", f.Name, f.Length, f.Type)
+		}
+		offset += f.Length
+
+		if f.Type == '0' {
+			t.nullFlags = f
+			continue
+		}
+		if descriptor[18]&flagNullable != 0 {
+			f.nullBit = nullBit
+			nullBit++
+		}
+		t.fields = append(t.fields, f)
+	}
+
+	if offset+1 > t.recordLen {
+		return errors.New("fields exceed the record length")
+	}
+	return nil
+
+}
+
+// Reads the next record which is not deleted, io.EOF at the end
+func (t *table) next() error {
+
+	for t.read < t.records {
+		if _, err := io.ReadFull(t.reader, t.record); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return io.EOF
+			}
+			return err
+		}
+		t.read++
+		switch t.record[0] {
+		case '*':
+			continue
+		case 0x1A:
+			return io.EOF
+		}
+		return nil
+	}
+	return io.EOF
+
+}
+
+// Value of the current record field
+func (t *table) value(f *field) (any, error) {
+
+	data := t.record[1+f.offset : 1+f.offset+f.Length]
+
+	if f.nullBit >= 0 && t.nullFlags != nil {
+		flags := t.record[1+t.nullFlags.offset : 1+t.nullFlags.offset+t.nullFlags.Length]
+		if f.nullBit/8 < len(flags) && flags[f.nullBit/8]&(1<<(f.nullBit%8)) != 0 {
+			return nil, nil
+		}
+	}
+
+	switch f.Type {
+	case 'C', 'V':
+		if f.binary {
+			// data is a slice of the record buffer, rows are kept when sorting
+			return bytes.Clone(data), nil
+		}
+		return t.text(data), nil
+	case 'N', 'F':
+		return parseNumber(data, f.Decimals)
+	case 'D':
+		return parseDate(data)
+	case 'L':
+		switch data[0] {
+		case 'T', 't', 'Y', 'y':
+			return true, nil
+		case 'F', 'f', 'N', 'n':
+			return false, nil
+		}
+		return nil, nil
+	case 'I':
+		return int64(int32(binary.LittleEndian.Uint32(data))), nil
+	case '+':
+		// dBase 7 autoincrement, big endian with the sign bit flipped
+		return int64(int32(binary.BigEndian.Uint32(data) ^ 0x80000000)), nil
+	case 'Y':
+		return float64(int64(binary.LittleEndian.Uint64(data))) / 10000, nil
+	case 'B':
+		// Double in Visual FoxPro, binary memo in dBase
+		if f.Length == 8 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+		}
+		return t.memoValue(f, data)
+	case 'T':
+		return parseDateTime(data), nil
+	case 'M', 'G', 'P':
+		return t.memoValue(f, data)
+	}
+
+	return t.text(data), nil
+
+}
+
+func (t *table) memoValue(f *field, data []byte) (any, error) {
+
+	var block int64
+	if len(data) == 4 {
+		block = int64(binary.LittleEndian.Uint32(data))
+	} else {
+		s := strings.TrimSpace(string(data))
+		if s == "" {
+			return nil, nil
+		}
+		var err error
+		if block, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("field %s: invalid memo block '%s'", f.Name, s)
+		}
+	}
+	if block == 0 {
+		return nil, nil
+	}
+
+	if t.memo == nil {
+		if t.memoPath == "" {
+			return nil, errors.New("memo file not found")
+		}
+		memo, err := openMemo(t.memoPath, t.version)
+		if err != nil {
+			return nil, err
+		}
+		t.memo = memo
+	}
+
+	value, isText, err := t.memo.read(block)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", f.Name, err)
+	}
+	if f.Type == 'M' && isText && !f.binary {
+		return t.decode(value), nil
+	}
+	return value, nil
+
+}
+
+// Text without the padding
+func (t *table) text(data []byte) string {
+	return strings.TrimRight(t.decode(data), " \x00")
+}
+
+func (t *table) decode(data []byte) string {
+	if t.charset == nil {
+		return string(data)
+	}
+	s, err := t.charset.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(s)
+}
+
+func (t *table) Close() error {
+	if t.memo != nil {
+		t.memo.Close()
+	}
+	return t.file.Close()
+}
+
+func parseNumber(data []byte, decimals int) (any, error) {
+
+	s := strings.TrimSpace(string(data))
+	if s == "" || strings.Trim(s, "*") == "" {
+		return nil, nil
+	}
+	if decimals == 0 {
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return v, nil
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number '%s'", s)
+	}
+	return v, nil
+
+}
+
+func parseDate(data []byte) (any, error) {
+
+	s := strings.TrimSpace(string(data))
+	if s == "" || strings.Trim(s, "0") == "" {
+		return nil, nil
+	}
+	date, err := time.Parse("20060102", s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date '%s'", s)
+	}
+	return date, nil
+
+}
+
+func parseDateTime(data []byte) any {
+
+	day := int64(int32(binary.LittleEndian.Uint32(data[:4])))
+	ms := int64(int32(binary.LittleEndian.Uint32(data[4:])))
+	if day == 0 && ms == 0 {
+		return nil
+	}
+	return time.UnixMilli((day-julianUnixEpoch)*86400000 + ms).UTC()
+
+}
+
+func isMemo(f *field) bool {
+	switch f.Type {
+	case 'M', 'G', 'P':
+		return true
+	case 'B':
+		return f.Length != 8
+	}
+	return false
+}
+
+// Finds the file by base name and one of the extensions, ignoring case
+func findFile(dir, base string, extensions ...string) string {
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, ext := range extensions {
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.EqualFold(entry.Name(), base+ext) {
+				return filepath.Join(dir, entry.Name())
+			}
+		}
+	}
+	return ""
+
+}
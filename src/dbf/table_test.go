@@ -0,0 +1,166 @@
+package dbf
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func queryAll(dsn, sqlQuery string, args ...any) ([][]any, error) {
+
+	db, err := sql.Open("dbf", dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := [][]any{}
+	for rows.Next() {
+		row := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err = rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+
+}
+
+func TestTable(t *testing.T) {
+
+	tests := []struct {
+		name string
+		dsn  string
+		sql  string
+		args []any
+		want [][]any
+		err  string
+	}{
+		{
+			name: "dBase III with memo, deleted records skipped",
+			sql:  "SELECT * FROM people",
+			want: [][]any{
+				{"Иван", int64(42), 1500.5, date(1982, 3, 15), true, "Первая заметка"},
+				{"Anna", nil, 2200.0, nil, false, nil},
+				{"O'Brien", int64(35), 980.25, date(1989, 1, 1), nil, "Second note"},
+				{"Boris", int64(27), 1500.5, date(1997, 7, 20), true, nil},
+			},
+		},
+		{
+			name: "where with parameter, order by",
+			sql:  "SELECT name FROM people WHERE age > ? ORDER BY age DESC",
+			args: []any{30},
+			want: [][]any{{"Иван"}, {"O'Brien"}},
+		},
+		{
+			name: "top with two sort keys",
+			sql:  "SELECT TOP 2 name FROM PEOPLE ORDER BY salary DESC, name",
+			want: [][]any{{"Anna"}, {"Boris"}},
+		},
+		{
+			name: "nulls sorted first, limit",
+			sql:  "SELECT name n FROM people ORDER BY age LIMIT 2",
+			want: [][]any{{"Anna"}, {"Boris"}},
+		},
+		{
+			name: "order by alias",
+			sql:  "SELECT age AS years FROM people WHERE age IS NOT NULL ORDER BY years",
+			want: [][]any{{int64(27)}, {int64(35)}, {int64(42)}},
+		},
+		{
+			name: "date between strings",
+			sql:  "SELECT name FROM people WHERE born BETWEEN '1985-01-01' AND '19991231'",
+			want: [][]any{{"O'Brien"}, {"Boris"}},
+		},
+		{
+			name: "logical field as condition, like",
+			sql:  "SELECT name FROM people WHERE active OR name LIKE 'O''%'",
+			want: [][]any{{"Иван"}, {"O'Brien"}, {"Boris"}},
+		},
+		{
+			name: "charset overrides the language driver",
+			dsn:  "testdata?charset=CP866",
+			sql:  "SELECT name FROM people WHERE age = 42",
+			want: [][]any{{"╚трэ"}}, // cp1251 bytes of Иван
+		},
+		{
+			name: "Visual FoxPro binary types, fpt memo and null flags",
+			sql:  "SELECT * FROM goods",
+			want: [][]any{
+				{int64(1), 12.5, time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), 0.25, "Memo text", "A1"},
+				{int64(-2), -0.5, nil, 1.5, nil, nil},
+			},
+		},
+		{
+			name: "binary field values kept when sorted",
+			sql:  "SELECT code FROM codes ORDER BY id DESC",
+			want: [][]any{{[]byte{0xff, 0x00}}, {[]byte{0x10, 0x20}}, {[]byte{0x01, 0x02}}},
+		},
+		{name: "invalid field length", sql: "SELECT * FROM badlogical", err: "field FLAG: invalid length 0"},
+		{name: "missing table", sql: "SELECT * FROM missing", err: "table missing not found"},
+		{name: "table outside the directory", sql: "SELECT * FROM [../people]", err: "invalid table name"},
+		{name: "unknown column", sql: "SELECT nope FROM people", err: "unknown column nope"},
+		{name: "unknown column in condition", sql: "SELECT * FROM people WHERE nope = 1", err: "unknown column nope"},
+		{name: "unknown sort key", sql: "SELECT * FROM people ORDER BY nope", err: "unknown column nope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn := tt.dsn
+			if dsn == "" {
+				dsn = "testdata"
+			}
+			got, err := queryAll(dsn, tt.sql, tt.args...)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+}
+
+func TestReadOnly(t *testing.T) {
+
+	db, err := sql.Open("dbf", "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err = db.Exec("UPDATE people SET age = 1"); err == nil {
+		t.Error("UPDATE succeeded")
+	}
+	if _, err = db.Begin(); err != ErrReadOnly {
+		t.Errorf("Begin: %v, want %v", err, ErrReadOnly)
+	}
+
+}
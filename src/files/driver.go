@@ -0,0 +1,115 @@
+// Database/sql driver for CSV, TSV and XLSX files. The data source name is
+// a directory, its files are loaded into an in-memory SQLite database shared by
+// the pool connections and reloaded when changed. Table names are file names
+// without extension, sheets of workbooks are tables named book$Sheet1
+package files
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sql-proxy/src/sqlitedb"
+)
+
+// Directory is checked for changed files not more often than this
+const refreshPeriod = time.Second
+
+var databaseCount atomic.Int64
+
+func init() {
+	sql.Register("files", &Driver{})
+}
+
+type Driver struct{}
+
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c.Connect(context.Background())
+}
+
+// Every connector has its own in-memory database
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+
+	name := fmt.Sprintf("file:/files-%d?vfs=memdb&_pragma=busy_timeout(5000)", databaseCount.Add(1))
+	loader, err := sql.Open("sqlite", name)
+	if err != nil {
+		return nil, err
+	}
+	// The database lives while the loader connection is open
+	loader.SetMaxOpenConns(1)
+	loader.SetMaxIdleConns(1)
+
+	return &connector{
+		driver:  d,
+		dir:     dsn,
+		dsn:     name + "&_pragma=query_only(1)",
+		loader:  loader,
+		sources: map[string]*source{},
+	}, nil
+
+}
+
+type connector struct {
+	driver  *Driver
+	dir     string
+	dsn     string // clients can't change the loaded tables
+	loader  *sql.DB
+	mu      sync.Mutex
+	checked time.Time
+	sources map[string]*source // by file name
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+
+	if err := c.refresh(ctx); err != nil {
+		return nil, err
+	}
+	dc, err := sqlitedb.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{sqliteConn: dc.(sqliteConn), connector: c}, nil
+
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Called when the pool is closed, the database is freed with the last connection
+func (c *connector) Close() error {
+	return c.loader.Close()
+}
+
+// Interfaces implemented by the SQLite connection
+type sqliteConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+// SQLite connection refreshing the tables before reuse from the pool
+type conn struct {
+	sqliteConn
+	connector *connector
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if err := c.connector.refresh(ctx); err != nil {
+		return err
+	}
+	return c.sqliteConn.ResetSession(ctx)
+}
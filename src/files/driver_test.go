@@ -0,0 +1,41 @@
+package files
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDriver(t *testing.T) {
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "goods.csv"), []byte("name;price\nbread;1,5\nmilk;2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("files", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var count int
+	if err = db.QueryRow("SELECT count(*) FROM goods WHERE name <> ''").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("%d rows, want 2", count)
+	}
+
+	if _, err = db.Exec("INSERT INTO goods VALUES ('salt', 1)"); err == nil {
+		t.Error("INSERT not rejected")
+	}
+	outside := filepath.Join(t.TempDir(), "outside.db")
+	if _, err = db.Exec("ATTACH DATABASE 'file:" + outside + "?mode=rwc' AS e"); err == nil {
+		t.Error("ATTACH not rejected")
+	}
+	if _, err = os.Stat(outside); err == nil {
+		t.Error("database file created by ATTACH")
+	}
+
+}
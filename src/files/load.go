@@ -0,0 +1,345 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"sql-proxy/src/app"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Loaded file and its tables
+type source struct {
+	modTime time.Time
+	size    int64
+	tables  []string
+}
+
+// Data of one table, columns are named by the header or F1, F2... as Jet does
+type sheet struct {
+	table   string
+	columns []string
+	rows    [][]string
+}
+
+var (
+	// Values with leading zeros are codes, not numbers
+	integerValue = regexp.MustCompile(`^[+-]?(0|[1-9][0-9]*)$`)
+	numberValue  = regexp.MustCompile(`^[+-]?((0|[1-9][0-9]*)(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+)
+
+// Reloads changed files and drops the tables of removed ones. Files which
+// fail to load keep the previous data and are tried again on the next check
+func (c *connector) refresh(ctx context.Context) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.checked) < refreshPeriod {
+		return nil
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	found := map[string]bool{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isSupported(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		name := entry.Name()
+		found[name] = true
+
+		src := c.sources[name]
+		if src != nil && src.modTime.Equal(info.ModTime()) && src.size == info.Size() {
+			continue
+		}
+		sheets, err := readFile(filepath.Join(c.dir, name))
+		if err != nil {
+			app.Logger.Warnf("File %s not loaded, previous data kept: %v", filepath.Join(c.dir, name), err)
+			continue
+		}
+		tables, err := c.replace(ctx, src, sheets)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		c.sources[name] = &source{modTime: info.ModTime(), size: info.Size(), tables: tables}
+	}
+
+	for name, src := range c.sources {
+		if !found[name] {
+			if _, err := c.replace(ctx, src, nil); err != nil {
+				return err
+			}
+			delete(c.sources, name)
+		}
+	}
+
+	c.checked = time.Now()
+	return nil
+
+}
+
+// Replaces the tables of the previous file version in one transaction
+func (c *connector) replace(ctx context.Context, old *source, sheets []sheet) ([]string, error) {
+
+	tx, err := c.loader.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if old != nil {
+		for _, table := range old.tables {
+			if _, err = tx.ExecContext(ctx, "DROP TABLE IF EXISTS "+quoteIdent(table)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var tables []string
+	for _, s := range sheets {
+		if len(s.columns) == 0 {
+			continue
+		}
+		if err = createTable(ctx, tx, s); err != nil {
+			return nil, err
+		}
+		tables = append(tables, s.table)
+	}
+
+	return tables, tx.Commit()
+
+}
+
+func createTable(ctx context.Context, tx *sql.Tx, s sheet) error {
+
+	types := inferTypes(s)
+	columns := make([]string, len(s.columns))
+	params := make([]string, len(s.columns))
+	for i, name := range s.columns {
+		columns[i] = quoteIdent(name) + " " + types[i]
+		params[i] = "?"
+	}
+
+	// A table with the same name may be left by another file, e.g. sales.csv and sales.tsv
+	create := fmt.Sprintf("DROP TABLE IF EXISTS %s; CREATE TABLE %s (%s)",
+		quoteIdent(s.table), quoteIdent(s.table), strings.Join(columns, ", "))
+	if _, err := tx.ExecContext(ctx, create); err != nil {
+		return err
+	}
+
+	insert, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s VALUES (%s)",
+		quoteIdent(s.table), strings.Join(params, ", ")))
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	values := make([]any, len(s.columns))
+	for _, row := range s.rows {
+		for i := range values {
+			values[i] = nil
+			if i < len(row) && row[i] != "" {
+				values[i] = convert(row[i], types[i])
+			}
+		}
+		if _, err = insert.ExecContext(ctx, values...); err != nil {
+			return err
+		}
+	}
+	return nil
+
+}
+
+// Column type is the narrowest one fitting all its values: INTEGER, REAL or TEXT
+func inferTypes(s sheet) []string {
+
+	types := make([]string, len(s.columns))
+	for i := range types {
+		types[i] = "INTEGER"
+	}
+	for _, row := range s.rows {
+		for i, v := range row {
+			if i >= len(types) || v == "" {
+				continue
+			}
+			switch {
+			case types[i] == "TEXT":
+			case !numberValue.MatchString(v):
+				types[i] = "TEXT"
+			case types[i] == "INTEGER" && !integerValue.MatchString(v):
+				types[i] = "REAL"
+			}
+		}
+	}
+	return types
+
+}
+
+func convert(v, columnType string) any {
+	switch columnType {
+	case "INTEGER":
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i
+		}
+		// Out of int64 range
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	case "REAL":
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	return v
+}
+
+func isSupported(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".tsv", ".tab", ".xlsx":
+		return true
+	}
+	return false
+}
+
+func readFile(path string) ([]sheet, error) {
+
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if strings.EqualFold(filepath.Ext(path), ".xlsx") {
+		return readWorkbook(path, base)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := readDelimited(data, strings.EqualFold(filepath.Ext(path), ".csv"))
+	if err != nil {
+		return nil, err
+	}
+	return []sheet{newSheet(base, rows)}, nil
+
+}
+
+// Every sheet is a table named book$Sheet
+func readWorkbook(path, base string) ([]sheet, error) {
+
+	book, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer book.Close()
+
+	var sheets []sheet
+	for _, name := range book.GetSheetList() {
+		rows, err := book.GetRows(name)
+		if err != nil {
+			return nil, err
+		}
+		sheets = append(sheets, newSheet(base+"$"+name, rows))
+	}
+	return sheets, nil
+
+}
+
+// CSV files are separated by commas or semicolons (Excel in many locales), others by tabs.
+// Text not valid as UTF-8 is read as Windows-1251
+func readDelimited(data []byte, isCSV bool) ([][]string, error) {
+
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	if !utf8.Valid(data) {
+		decoded, err := charmap.Windows1251.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, err
+		}
+		data = decoded
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = '\t'
+	if isCSV {
+		firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+		reader.Comma = ','
+		if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+			reader.Comma = ';'
+		}
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var rows [][]string
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+
+}
+
+// The first row is the header if all its cells are distinct non-empty text
+// and there is data below it
+func newSheet(table string, rows [][]string) sheet {
+
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+
+	s := sheet{table: table, rows: rows}
+	if len(rows) > 1 && isHeader(rows[0], width) {
+		s.rows = rows[1:]
+		for _, name := range rows[0] {
+			s.columns = append(s.columns, strings.TrimSpace(name))
+		}
+		return s
+	}
+	for i := range width {
+		s.columns = append(s.columns, fmt.Sprintf("F%d", i+1))
+	}
+	return s
+
+}
+
+// Names are case insensitive in SQLite, so they must differ not only in case
+func isHeader(row []string, width int) bool {
+	if len(row) != width {
+		return false
+	}
+	seen := map[string]bool{}
+	for _, v := range row {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" || seen[v] || numberValue.MatchString(v) {
+			return false
+		}
+		seen[v] = true
+	}
+	return true
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,97 @@
+package files
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInferTypes(t *testing.T) {
+
+	tests := []struct {
+		name string
+		rows [][]string
+		want []string
+	}{
+		{"integers", [][]string{{"1", "-2"}, {"+3", "0"}}, []string{"INTEGER", "INTEGER"}},
+		{"reals", [][]string{{"1", "1.5"}, {"2.", "-.5"}, {"1e3", "2E-2"}}, []string{"REAL", "REAL"}},
+		{"text", [][]string{{"1", "a"}, {"x", "2"}}, []string{"TEXT", "TEXT"}},
+		{"leading zeros", [][]string{{"007"}, {"1"}}, []string{"TEXT"}},
+		{"decimal comma", [][]string{{"1,5"}}, []string{"TEXT"}},
+		{"empty values", [][]string{{"", "1"}, {"2", ""}}, []string{"INTEGER", "INTEGER"}},
+		{"empty column", [][]string{{"", "a"}}, []string{"INTEGER", "TEXT"}},
+		{"text stays text", [][]string{{"a"}, {"1.5"}, {"2"}}, []string{"TEXT"}},
+		{"short and long rows", [][]string{{"1"}, {"2", "3.5", "x"}}, []string{"INTEGER", "REAL"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := sheet{columns: make([]string, len(tt.want)), rows: tt.rows}
+			if got := inferTypes(s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+}
+
+func TestNewSheet(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		rows    [][]string
+		columns []string
+		count   int
+	}{
+		{"header", [][]string{{" Name ", "Price"}, {"a", "1"}}, []string{"Name", "Price"}, 1},
+		{"header only", [][]string{{"Name", "Price"}}, []string{"F1", "F2"}, 1},
+		{"numbers", [][]string{{"1", "2"}, {"3", "4"}}, []string{"F1", "F2"}, 2},
+		{"number in header", [][]string{{"Name", "2024"}, {"a", "1"}}, []string{"F1", "F2"}, 2},
+		{"empty cell", [][]string{{"Name", ""}, {"a", "1"}}, []string{"F1", "F2"}, 2},
+		{"duplicate names", [][]string{{"Name", "NAME"}, {"a", "b"}}, []string{"F1", "F2"}, 2},
+		{"short header", [][]string{{"Name"}, {"a", "b"}}, []string{"F1", "F2"}, 2},
+		{"empty", nil, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSheet("t", tt.rows)
+			if !reflect.DeepEqual(s.columns, tt.columns) || len(s.rows) != tt.count {
+				t.Errorf("columns %v, %d rows, want %v, %d rows", s.columns, len(s.rows), tt.columns, tt.count)
+			}
+		})
+	}
+
+}
+
+func TestReadDelimited(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		data  string
+		isCSV bool
+		want  [][]string
+	}{
+		{"comma", "a,b\n1,2\n", true, [][]string{{"a", "b"}, {"1", "2"}}},
+		{"semicolon", "a;b;c\n1,5;2;x\n", true, [][]string{{"a", "b", "c"}, {"1,5", "2", "x"}}},
+		{"more commas than semicolons", "a,b,c;d\n", true, [][]string{{"a", "b", "c;d"}}},
+		{"semicolon by the first line only", "a;b\n1,2,3\n", true, [][]string{{"a", "b"}, {"1,2,3"}}},
+		{"quoted", "\"a;b\",c\n", true, [][]string{{"a;b", "c"}}},
+		{"tab", "a;b\tc\n", false, [][]string{{"a;b", "c"}}},
+		{"byte order mark", "\xEF\xBB\xBFa,b\n", true, [][]string{{"a", "b"}}},
+		{"Windows-1251", "\xC8\xE2\xE0\xED;1\r\n", true, [][]string{{"Иван", "1"}}},
+		{"ragged rows", "a,b\n1\n", true, [][]string{{"a", "b"}, {"1"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readDelimited([]byte(tt.data), tt.isCSV)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sql-proxy/src/app"
+	"sql-proxy/src/cache"
+	"sql-proxy/src/db"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Pool state for administrators. Passwords are never included
+type PoolInfo struct {
+	Id             string          `json:"id"`
+	DbType         string          `json:"db_type"`
+	Host           string          `json:"host"`
+	Port           uint16          `json:"port"`
+	DbName         string          `json:"db_name"`
+	User           string          `json:"user"`
+	Profile        string          `json:"profile,omitempty"`
+	Created        time.Time       `json:"created"`
+	LastUsed       time.Time       `json:"last_used"`
+	ActiveRequests int             `json:"active_requests"`
+	Draining       bool            `json:"draining"`
+	Stats          PoolStats       `json:"stats"`
+	Statements     []StatementInfo `json:"statements"`
+}
+
+// sql.DBStats in JSON
+type PoolStats struct {
+	MaxOpenConnections int     `json:"max_open_connections"`
+	OpenConnections    int     `json:"open_connections"`
+	InUse              int     `json:"in_use"`
+	Idle               int     `json:"idle"`
+	WaitCount          int64   `json:"wait_count"`
+	WaitDurationSec    float64 `json:"wait_duration_sec"`
+	MaxIdleClosed      int64   `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64   `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64   `json:"max_lifetime_closed"`
+}
+
+type StatementInfo struct {
+	Id       string    `json:"id"`
+	Created  time.Time `json:"created"`
+	LastUsed time.Time `json:"last_used"`
+	AgeSec   float64   `json:"age_sec"`
+	InUse    bool      `json:"in_use"`
+}
+
+// Hashes compared when the user is unknown, by bcrypt cost
+var dummyHashes sync.Map
+
+// Hash with the highest cost of the configured ones, as the cost sets the check time.
+// Unknown users are then not told from existing ones by the response time
+func dummyHash(users map[string]string) []byte {
+
+	cost := 0
+	for _, hash := range users {
+		if c, err := bcrypt.Cost([]byte(hash)); err == nil {
+			cost = max(cost, c)
+		}
+	}
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+
+	if hash, ok := dummyHashes.Load(cost); ok {
+		return hash.([]byte)
+	}
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy"), cost)
+	dummyHashes.Store(cost, hash)
+	return hash
+
+}
+
+// Checks HTTP basic credentials against bcrypt hashes of admin users
+func AdminAuth(next http.Handler) http.Handler {
+
+	// Made in advance, not to slow down the first check
+	dummyHash(app.GetConfig().Admin.Users)
+	app.OnConfigReload(func(oldCfg, newCfg *app.Config) {
+		dummyHash(newCfg.Admin.Users)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if ok {
+			users := app.GetConfig().Admin.Users
+			hash, found := users[user]
+			hashBytes := []byte(hash)
+			if !found {
+				hashBytes = dummyHash(users)
+			}
+			err := bcrypt.CompareHashAndPassword(hashBytes, []byte(password))
+			if found && err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		app.Logger.Warnf("Admin authentication failed: user=%s, remote_addr=%s", user, r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", `Basic realm="sql-proxy admin", charset="UTF-8"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+func AdminListPools(w http.ResponseWriter, r *http.Request) {
+
+	pools := make([]PoolInfo, 0)
+	for _, dbConn := range db.Handler.List() {
+		pools = append(pools, newPoolInfo(dbConn))
+	}
+	slices.SortFunc(pools, func(a, b PoolInfo) int { return a.Created.Compare(b.Created) })
+
+	jsonResponce(w, pools)
+
+}
+
+func AdminClosePool(w http.ResponseWriter, r *http.Request) {
+
+	connId := mux.Vars(r)["id"]
+	if _, ok := db.Handler.Get(connId); !ok {
+		errorResponce(w, "Connection not found", http.StatusNotFound)
+		return
+	}
+
+	app.Logger.Infof("Admin: SQL connection %s closed", connId)
+	db.Handler.Delete(connId)
+
+}
+
+func AdminCloseStatement(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	dbConn, ok := db.Handler.Get(vars["id"])
+	if !ok {
+		errorResponce(w, "Connection not found", http.StatusNotFound)
+		return
+	}
+
+	found := slices.ContainsFunc(dbConn.Statements(), func(s *db.DbStmt) bool { return s.Id == vars["stmt"] })
+	if !found {
+		errorResponce(w, "Prepared statement not found", http.StatusNotFound)
+		return
+	}
+
+	app.Logger.Infof("Admin: prepared statement %s of connection %s closed", vars["stmt"], vars["id"])
+	db.Handler.ClosePreparedStatement(vars["id"], vars["stmt"])
+
+}
+
+// Server types built in, for the console connection form
+func AdminListDbTypes(w http.ResponseWriter, r *http.Request) {
+
+	jsonResponce(w, db.DbTypes())
+
+}
+
+func AdminListDraining(w http.ResponseWriter, r *http.Request) {
+
+	jsonResponce(w, db.DrainingBackends())
+
+}
+
+// Backend is given by db_type, host and port query parameters
+func AdminSetDraining(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	port, err := strconv.ParseUint(query.Get("port"), 10, 16)
+	if err != nil || query.Get("db_type") == "" || query.Get("host") == "" {
+		errorResponce(w, "db_type, host and port parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	drain := r.Method != http.MethodDelete
+	db.SetDraining(query.Get("db_type"), query.Get("host"), uint16(port), drain)
+	app.Logger.Infof("Admin: backend %s draining=%t",
+		db.BackendKey(query.Get("db_type"), query.Get("host"), uint16(port)), drain)
+
+}
+
+func newPoolInfo(dbConn *db.DbConn) PoolInfo {
+
+	info := dbConn.Info
+	stats := dbConn.DB.Stats()
+
+	pool := PoolInfo{
+		Id:             dbConn.Id,
+		DbType:         info.DbType,
+		Host:           info.Host,
+		Port:           info.Port,
+		DbName:         info.DbName,
+		User:           info.User,
+		Profile:        dbConn.Profile,
+		Created:        dbConn.Created,
+		LastUsed:       dbConn.Timestamp(),
+		ActiveRequests: dbConn.ActiveRequests(),
+		Draining:       db.IsDraining(info.DbType, info.Host, info.Port),
+		Stats:          newPoolStats(stats),
+		Statements:     make([]StatementInfo, 0),
+	}
+
+	for _, stmt := range dbConn.Statements() {
+		pool.Statements = append(pool.Statements, StatementInfo{
+			Id:       stmt.Id,
+			Created:  stmt.Created,
+			LastUsed: stmt.Timestamp(),
+			AgeSec:   time.Since(stmt.Created).Seconds(),
+			InUse:    stmt.InUse(),
+		})
+	}
+
+	return pool
+
+}
+
+func newPoolStats(stats sql.DBStats) PoolStats {
+	return PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationSec:    stats.WaitDuration.Seconds(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+}
+
+func jsonResponce(w http.ResponseWriter, v any) {
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		app.Logger.Error(err.Error())
+	}
+
+}
+
+func AdminListQueries(w http.ResponseWriter, r *http.Request) {
+
+	jsonResponce(w, inFlight.list())
+
+}
+
+func AdminCancelQuery(w http.ResponseWriter, r *http.Request) {
+
+	id := mux.Vars(r)["id"]
+	if ok := inFlight.cancel(id); !ok {
+		errorResponce(w, "Query not found", http.StatusNotFound)
+		return
+	}
+
+	app.Logger.Infof("Admin: query %s cancelled", id)
+
+}
+
+// Server-sent events with the list of SQL calls being executed,
+// sent on every change and at least once a second
+func AdminQueryStream(w http.ResponseWriter, r *http.Request) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		errorResponce(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	changes := inFlight.subscribe()
+	defer inFlight.unsubscribe(changes)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		data, err := json.Marshal(inFlight.list())
+		if err != nil {
+			app.Logger.Error(err.Error())
+			return
+		}
+		if _, err = fmt.Fprintf(w, "event: queries\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changes:
+		case <-ticker.C:
+		}
+	}
+
+}
+
+// Serves the web console if enabled
+func AdminConsole(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.GetConfig().Admin.Console {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Removes cached SELECT results by profile and key prefix query parameters,
+// all results if none given
+func AdminInvalidateCache(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	profile, byProfile := query["profile"]
+	prefix := query.Get("prefix")
+
+	removed := 0
+	if byProfile {
+		removed = cache.Results.Invalidate(profile[0], prefix, false)
+	} else {
+		removed = cache.Results.Invalidate("", prefix, true)
+	}
+
+	app.Logger.Infof("Admin: %d cached results removed, profile=%s, prefix=%s", removed, query.Get("profile"), prefix)
+	jsonResponce(w, map[string]int{"removed": removed})
+
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestDummyHashCost(t *testing.T) {
+
+	low, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	high, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost+1)
+
+	tests := []struct {
+		name  string
+		users map[string]string
+		cost  int
+	}{
+		{"no users", nil, bcrypt.DefaultCost},
+		{"configured cost", map[string]string{"admin": string(low)}, bcrypt.MinCost},
+		{"highest cost", map[string]string{"admin": string(low), "ops": string(high)}, bcrypt.MinCost + 1},
+		{"invalid hash", map[string]string{"admin": "secret", "ops": string(low)}, bcrypt.MinCost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost, err := bcrypt.Cost(dummyHash(tt.users))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cost != tt.cost {
+				t.Errorf("cost %d, want %d", cost, tt.cost)
+			}
+		})
+	}
+
+}
+
+func TestAdminAuthUnknownUser(t *testing.T) {
+
+	handler := AdminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest("GET", "/admin/v1/pools", nil)
+	r.SetBasicAuth("nobody", "dummy")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+}
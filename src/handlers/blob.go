@@ -1,14 +1,14 @@
 package handlers
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"sql-proxy/src/app"
 	"sql-proxy/src/db"
+	"unicode/utf8"
 )
 
-const maxBlobSize int64 = 32 << 20 // 32 MB, change here if required
-
 func ReadBlob(w http.ResponseWriter, r *http.Request) {
 
 	if ok := checkApiVersion(w, r); !ok {
@@ -20,20 +20,29 @@ func ReadBlob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dbConn, ok := db.Handler.GetById(connId, true)
+	dbConn, ok := db.Handler.Acquire(connId)
 	if !ok {
 		errorResponce(w, "Invalid connection id", http.StatusForbidden)
 		return
 	}
+	defer dbConn.Release()
+
+	ctx, done := trackQuery(w, r, "blob_read", connId, "", sqlQuery)
+	defer done()
+
+	if dbConn.Dialect.Capabilities().TextBlobs {
+		readTextBlob(ctx, w, dbConn, sqlQuery)
+		return
+	}
 
 	var data []byte
-	err := dbConn.QueryRow(sqlQuery).Scan(&data)
+	err := dbConn.DB.QueryRowContext(ctx, sqlQuery).Scan(&data)
 	if err != nil {
 		errorResponce(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if int64(len(data)) > maxBlobSize {
+	if int64(len(data)) > app.GetConfig().Limits.MaxBlobSize {
 		errorResponce(w, "Data too large", http.StatusRequestEntityTooLarge)
 		return
 	}
@@ -43,26 +52,84 @@ func ReadBlob(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// Text BLOBs (e.g. Firebird sub_type 1) are decoded by the driver
+// from the connection charset, binary ones are returned as is
+func readTextBlob(ctx context.Context, w http.ResponseWriter, dbConn *db.DbConn, sqlQuery string) {
+
+	var value any
+	err := dbConn.DB.QueryRowContext(ctx, sqlQuery).Scan(&value)
+	if err != nil {
+		errorResponce(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var data []byte
+	contentType := "application/octet-stream"
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+		contentType = "text/plain; charset=utf-8"
+	case nil:
+	default:
+		errorResponce(w, "Not a BLOB value", http.StatusBadRequest)
+		return
+	}
+
+	if int64(len(data)) > app.GetConfig().Limits.MaxBlobSize {
+		errorResponce(w, "Data too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(data)
+
+}
+
 func WriteBlob(w http.ResponseWriter, r *http.Request) {
 
 	if ok := checkApiVersion(w, r); !ok {
 		return
 	}
 
-	//maxSize := int64(32 << 20) // 32 MB, change here if required
-	connId, sqlQuery, data, ok := parseQueryHttpHeadersAndMultipartBody(r, maxBlobSize)
+	connId, sqlQuery, data, ok := parseQueryHttpHeadersAndMultipartBody(r, app.GetConfig().Limits.MaxBlobSize)
 	if !ok {
 		errorResponce(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
-	dbConn, ok := db.Handler.GetById(connId, true)
+	// Text is passed to the driver as string to be encoded to the connection
+	// charset, e.g. for Firebird BLOB SUB_TYPE TEXT with WIN1251 charset
+	var param any = data
+	switch r.FormValue("blob_type") {
+	case "", "binary":
+	case "text":
+		if !utf8.Valid(data) {
+			errorResponce(w, "Text BLOB must be UTF-8 encoded", http.StatusBadRequest)
+			return
+		}
+		param = string(data)
+	default:
+		errorResponce(w, "Unknown blob_type", http.StatusBadRequest)
+		return
+	}
+
+	dbConn, ok := db.Handler.Acquire(connId)
 	if !ok {
 		errorResponce(w, "Invalid connection id", http.StatusForbidden)
 		return
 	}
+	defer dbConn.Release()
+
+	if ok := checkWritable(w, dbConn); !ok {
+		return
+	}
+
+	ctx, done := trackQuery(w, r, "blob_write", connId, "", sqlQuery)
+	defer done()
 
-	_, err := dbConn.Exec(sqlQuery, data)
+	_, err := dbConn.DB.ExecContext(ctx, sqlQuery, param)
 	if err != nil {
 		errorResponce(w, err.Error(), http.StatusBadRequest)
 	}
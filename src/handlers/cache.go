@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sql-proxy/src/app"
+	"sql-proxy/src/cache"
+	"sql-proxy/src/db"
+	"strings"
+)
+
+// Runs SELECT and writes the result, using the results cache if enabled
+// for the connection profile. The Cache-Bypass: true header skips the cache
+// lookup, the fresh result is cached anyway
+func selectResponce(w http.ResponseWriter, r *http.Request, dbConn *db.DbConn, sqlQuery string, params []any,
+	query func() (*sql.Rows, error)) {
+
+	cfg := app.GetConfig().CacheFor(dbConn.Profile)
+	if !cfg.Enabled {
+		rows, err := query()
+		if err != nil {
+			errorResponce(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		tableResponce(w, rows, dbConn.Info.DbType)
+		return
+	}
+
+	key := cache.Key(dbConn.Hash, sqlQuery, params)
+
+	if r.Header.Get("Cache-Bypass") != "true" {
+		if entry, ok := cache.Results.Get(dbConn.Profile, key); ok {
+			cachedResponce(w, r, entry, "hit")
+			return
+		}
+	}
+
+	rows, err := query()
+	if err != nil {
+		errorResponce(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	envelope, err := newTableEnvelope(rows, dbConn.Info.DbType)
+	if err == nil {
+		err = rows.Err()
+	}
+	if err != nil {
+		errorResponce(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		errorResponce(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	entry := cache.Results.Put(dbConn.Profile, key, append(data, '\n'), cfg)
+	cachedResponce(w, r, entry, "miss")
+
+}
+
+// Writes cached result, or 304 if the client has it already
+func cachedResponce(w http.ResponseWriter, r *http.Request, entry *cache.Entry, status string) {
+
+	w.Header().Set("ETag", entry.ETag)
+	w.Header().Set("Cache-Status", status)
+	w.Header().Set("Cache-Key", entry.Key)
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == entry.ETag || tag == "*" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(entry.Data)
+
+}
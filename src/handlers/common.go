@@ -14,9 +14,15 @@ type ResponseEnvelope struct {
 	Info           string           `json:"info"`
 	RowsCount      uint32           `json:"rows_count"`
 	ExceedsMaxRows bool             `json:"exceeds_max_rows"`
+	Columns        []ColumnInfo     `json:"columns"` // in the order of the query
 	Rows           []map[string]any `json:"rows"`
 }
 
+type ColumnInfo struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // database type name, empty if unknown to the driver
+}
+
 func checkApiVersion(w http.ResponseWriter, r *http.Request) bool {
 
 	apiVersion := r.Header.Get("API-Version")
@@ -31,6 +37,17 @@ func checkApiVersion(w http.ResponseWriter, r *http.Request) bool {
 
 }
 
+// Changes are rejected for read-only server types
+func checkWritable(w http.ResponseWriter, dbConn *db.DbConn) bool {
+
+	if dbConn.Dialect.Capabilities().ReadOnly {
+		errorResponce(w, "Data source is read-only", http.StatusForbidden)
+		return false
+	}
+	return true
+
+}
+
 func errorResponce(w http.ResponseWriter, message string, httpStatus int) {
 
 	app.Logger.Error(message)
@@ -38,29 +55,55 @@ func errorResponce(w http.ResponseWriter, message string, httpStatus int) {
 
 }
 
-func tableResponce(w http.ResponseWriter, rows *sql.Rows) {
+func tableResponce(w http.ResponseWriter, rows *sql.Rows, dbType string) {
 
-	columns, err := rows.Columns()
+	envelope, err := newTableEnvelope(rows, dbType)
 	if err != nil {
 		errorResponce(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	tableData, rowsCount, exceedsMaxRows := convertRows(rows, &columns)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(envelope)
+
+}
+
+func newTableEnvelope(rows *sql.Rows, dbType string) (*ResponseEnvelope, error) {
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	decoders, err := db.ValueDecoders(dbType, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	columnInfo := make([]ColumnInfo, len(columnTypes))
+	for i, columnType := range columnTypes {
+		columnInfo[i] = ColumnInfo{Name: columnType.Name(), Type: columnType.DatabaseTypeName()}
+	}
+
+	tableData, rowsCount, exceedsMaxRows := convertRows(rows, &columns, decoders)
 
 	var envelope ResponseEnvelope
 	envelope.ApiVersion = app.ApiVersion
 	envelope.RowsCount = rowsCount
 	envelope.ExceedsMaxRows = exceedsMaxRows
+	envelope.Columns = columnInfo
 	envelope.Rows = *tableData
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(envelope)
+	return &envelope, nil
 
 }
 
 // Converts SQL query result to JSON array
-func convertRows(rows *sql.Rows, columns *[]string) (*[]map[string]any, uint32, bool) {
+func convertRows(rows *sql.Rows, columns *[]string, decoders []db.ValueDecoder) (*[]map[string]any, uint32, bool) {
 
 	var rowsCount uint32 = 0
 	colsCount := len(*columns)
@@ -68,6 +111,7 @@ func convertRows(rows *sql.Rows, columns *[]string) (*[]map[string]any, uint32,
 	values := make([]any, colsCount)
 	valuePtrs := make([]any, colsCount)
 	exceedsMaxRows := false
+	maxRows := app.GetConfig().Limits.MaxRows
 
 	for rows.Next() {
 		for i := range *columns {
@@ -76,17 +120,9 @@ func convertRows(rows *sql.Rows, columns *[]string) (*[]map[string]any, uint32,
 		rows.Scan(valuePtrs...)
 		entry := make(map[string]any)
 		for i, col := range *columns {
-			var v any
-			val := values[i]
-			b, ok := val.([]byte)
-			if ok {
-				v = string(b)
-			} else {
-				v = val
-			}
-			entry[col] = v
+			entry[col] = decoders[i](values[i])
 		}
-		if rowsCount > db.MaxRows {
+		if rowsCount >= maxRows {
 			exceedsMaxRows = true
 			break
 		}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"sql-proxy/src/app"
 	"sql-proxy/src/db"
 )
 
@@ -19,7 +21,33 @@ func CreateConnection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if connGuid, ok := db.Handler.GetByParams(&dbConnInfo); !ok {
+	if err := dbConnInfo.ParseConnectionString(); err != nil {
+		errorResponce(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := dbConnInfo.Validate(app.GetConfig().Backend.AllowedOptions); errors.Is(err, db.ErrOptionNotAllowed) {
+		errorResponce(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
+		errorResponce(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if ok := dbConnInfo.ApplyProfile(app.GetConfig().Profiles); !ok {
+		errorResponce(w, "Unknown profile", http.StatusBadRequest)
+		return
+	}
+
+	if connGuid, err := db.Handler.GetByParams(&dbConnInfo); errors.Is(err, db.ErrBackendDraining) {
+		errorResponce(w, err.Error(), http.StatusServiceUnavailable)
+	} else if errors.Is(err, db.ErrOptionNotSupported) || errors.Is(err, db.ErrInvalidTLS) {
+		errorResponce(w, err.Error(), http.StatusBadRequest)
+	} else if errors.Is(err, db.ErrUnsupportedDbType) {
+		errorResponce(w, err.Error(), http.StatusNotImplemented)
+	} else if errors.Is(err, db.ErrPathNotAllowed) || errors.Is(err, db.ErrOdbcNotAllowed) {
+		errorResponce(w, err.Error(), http.StatusForbidden)
+	} else if err != nil {
 		errorResponce(w, "Failed to get SQL connection", http.StatusInternalServerError)
 	} else if _, err := w.Write([]byte(connGuid)); err != nil {
 		errorResponce(w, err.Error(), http.StatusInternalServerError)
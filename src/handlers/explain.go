@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"sql-proxy/src/app"
+	"sql-proxy/src/db"
+)
+
+type ExplainEnvelope struct {
+	ApiVersion string `json:"api_version"`
+	*db.Plan
+}
+
+// Returns the plan of the query in the body as a tree of the same shape for all
+// servers. With "Explain-Analyze: true" the query is executed and rolled back
+func ExplainQuery(w http.ResponseWriter, r *http.Request) {
+
+	if ok := checkApiVersion(w, r); !ok {
+		return
+	}
+
+	connId, sqlQuery, ok := parseQueryHttpHeadersAndBody(w, r)
+	if !ok {
+		return
+	}
+	analyze := r.Header.Get("Explain-Analyze") == "true"
+
+	dbConn, ok := db.Handler.Acquire(connId)
+	if !ok {
+		errorResponce(w, "Invalid connection id", http.StatusForbidden)
+		return
+	}
+	defer dbConn.Release()
+
+	// Data modifying statements are run by analyze, even if rolled back
+	if analyze && !checkWritable(w, dbConn) {
+		return
+	}
+
+	ctx, done := trackQuery(w, r, "explain", connId, "", sqlQuery)
+	defer done()
+
+	plan, err := db.Explain(ctx, dbConn, sqlQuery, analyze)
+	if errors.Is(err, db.ErrExplainNotSupported) {
+		errorResponce(w, err.Error(), http.StatusNotImplemented)
+		return
+	} else if err != nil {
+		errorResponce(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ExplainEnvelope{ApiVersion: app.ApiVersion, Plan: plan})
+
+}
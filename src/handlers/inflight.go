@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// SQL text longer than this is truncated in the monitor
+const maxMonitorSqlLength = 1000
+
+// SQL call being executed now
+type InFlightQuery struct {
+	Id           string    `json:"id"`
+	Kind         string    `json:"kind"`
+	ConnectionId string    `json:"connection_id"`
+	StatementId  string    `json:"statement_id,omitempty"`
+	ClientAddr   string    `json:"client_addr"`
+	Started      time.Time `json:"started"`
+	DurationSec  float64   `json:"duration_sec"`
+	Sql          string    `json:"sql"`
+	Cancelled    bool      `json:"cancelled"`
+
+	cancel context.CancelFunc
+}
+
+// Registry of SQL calls being executed, with change notifications
+// for the admin event stream
+type inFlightRegistry struct {
+	mu          sync.Mutex
+	items       map[string]*InFlightQuery
+	subscribers map[chan struct{}]bool
+}
+
+var inFlight = inFlightRegistry{
+	items:       make(map[string]*InFlightQuery),
+	subscribers: make(map[chan struct{}]bool),
+}
+
+var (
+	metricInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sqlproxy_queries_in_flight",
+		Help: "SQL calls being executed now",
+	})
+	metricCancelled = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sqlproxy_queries_cancelled_total",
+		Help: "SQL calls cancelled by administrators",
+	})
+)
+
+// Registers SQL call of the request, its id is returned in the Request-Id header. The returned context must be passed
+// to the database call, and the function must be called when it is finished
+func trackQuery(w http.ResponseWriter, r *http.Request, kind, connId, stmtId, sqlQuery string) (context.Context, func()) {
+
+	ctx, cancel := context.WithCancel(r.Context())
+
+	if len(sqlQuery) > maxMonitorSqlLength {
+		sqlQuery = strings.ToValidUTF8(sqlQuery[:maxMonitorSqlLength], "") + "..."
+	}
+
+	query := &InFlightQuery{
+		Id:           uuid.New().String(),
+		Kind:         kind,
+		ConnectionId: connId,
+		StatementId:  stmtId,
+		ClientAddr:   r.RemoteAddr,
+		Started:      time.Now(),
+		Sql:          sqlQuery,
+		cancel:       cancel,
+	}
+
+	// Lets the client find the query in the monitor
+	w.Header().Set("Request-Id", query.Id)
+
+	inFlight.mu.Lock()
+	inFlight.items[query.Id] = query
+	inFlight.mu.Unlock()
+	inFlight.notify()
+	metricInFlight.Inc()
+
+	return ctx, func() {
+		inFlight.mu.Lock()
+		delete(inFlight.items, query.Id)
+		inFlight.mu.Unlock()
+		inFlight.notify()
+		metricInFlight.Dec()
+		cancel()
+	}
+
+}
+
+// Returns SQL calls being executed, the oldest first
+func (o *inFlightRegistry) list() []InFlightQuery {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	list := make([]InFlightQuery, 0, len(o.items))
+	for _, query := range o.items {
+		item := *query
+		item.DurationSec = time.Since(item.Started).Seconds()
+		list = append(list, item)
+	}
+	slices.SortFunc(list, func(a, b InFlightQuery) int { return a.Started.Compare(b.Started) })
+	return list
+}
+
+// Cancels the SQL call context, the driver cancels the query at the server
+func (o *inFlightRegistry) cancel(id string) bool {
+	o.mu.Lock()
+	query, ok := o.items[id]
+	if ok {
+		query.Cancelled = true
+	}
+	o.mu.Unlock()
+
+	if ok {
+		query.cancel()
+		metricCancelled.Inc()
+		o.notify()
+	}
+	return ok
+}
+
+func (o *inFlightRegistry) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	o.mu.Lock()
+	o.subscribers[ch] = true
+	o.mu.Unlock()
+	return ch
+}
+
+func (o *inFlightRegistry) unsubscribe(ch chan struct{}) {
+	o.mu.Lock()
+	delete(o.subscribers, ch)
+	o.mu.Unlock()
+}
+
+func (o *inFlightRegistry) notify() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for ch := range o.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// already notified
+		}
+	}
+}
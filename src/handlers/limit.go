@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sql-proxy/src/app"
+	"sql-proxy/src/db"
+	"sql-proxy/src/limit"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	clientLimiters  = limit.NewRateLimiters()
+	connectLimiters = limit.NewRateLimiters()
+	connSlots       = limit.NewSemaphores()
+	backendSlots    = limit.NewSemaphores()
+
+	metricRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqlproxy_rate_limited_total",
+		Help: "Requests rejected by the client rate limits",
+	}, []string{"limit"})
+	metricConcurrencyRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqlproxy_concurrency_rejected_total",
+		Help: "SQL calls rejected by the concurrency limits",
+	}, []string{"scope", "reason"})
+	metricQueueWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sqlproxy_concurrency_wait_seconds",
+		Help:    "Time SQL calls waited for a free slot",
+		Buckets: []float64{.001, .01, .05, .1, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"scope"})
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "sqlproxy_concurrency_waiting",
+		Help:        "SQL calls waiting for a free slot",
+		ConstLabels: prometheus.Labels{"scope": "connection"},
+	}, func() float64 { return float64(connSlots.Waiting()) })
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "sqlproxy_concurrency_waiting",
+		Help:        "SQL calls waiting for a free slot",
+		ConstLabels: prometheus.Labels{"scope": "backend"},
+	}, func() float64 { return float64(backendSlots.Waiting()) })
+)
+
+// Applies rate limits per client and concurrency limits per connection and backend.
+// Rate limited requests get 429, requests not given a slot in time get 503
+func RateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		cfg := app.GetConfig().RateLimit
+		if !cfg.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client := clientId(r, cfg.ClientHeader)
+
+		if cfg.RequestsPerSecond > 0 {
+			if ok, delay := clientLimiters.Allow(client, cfg.RequestsPerSecond, cfg.Burst); !ok {
+				metricRateLimited.WithLabelValues("client").Inc()
+				retryResponce(w, "Too many requests", http.StatusTooManyRequests, delay)
+				return
+			}
+		}
+
+		// Opening connections is limited separately, as every new one may create a pool
+		if r.URL.Path == "/api/v1/connection" {
+			if r.Method == http.MethodPost && cfg.ConnectPerSecond > 0 {
+				if ok, delay := connectLimiters.Allow(client, cfg.ConnectPerSecond, cfg.ConnectBurst); !ok {
+					metricRateLimited.WithLabelValues("connect").Inc()
+					retryResponce(w, "Too many connection requests", http.StatusTooManyRequests, delay)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		connId := r.Header.Get("Connection-Id")
+		if connId == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Both waits share the same timeout
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.QueueTimeout)
+		defer cancel()
+
+		release, ok := acquireSlot(w, ctx, connSlots, "connection", connId, cfg.MaxConcurrentPerConnection, cfg)
+		if !ok {
+			return
+		}
+		defer release()
+
+		if dbConn, found := db.Handler.Get(connId); found {
+			info := dbConn.Info
+			key := db.BackendKey(info.DbType, info.Host, info.Port)
+			release, ok := acquireSlot(w, ctx, backendSlots, "backend", key, cfg.MaxConcurrentPerBackend, cfg)
+			if !ok {
+				return
+			}
+			defer release()
+		}
+
+		next.ServeHTTP(w, r)
+
+	})
+}
+
+func acquireSlot(w http.ResponseWriter, ctx context.Context, slots *limit.Semaphores, scope, key string,
+	maxConcurrent int, cfg app.RateLimitConfig) (func(), bool) {
+
+	if maxConcurrent == 0 {
+		return func() {}, true
+	}
+
+	start := time.Now()
+	release, err := slots.Acquire(ctx, key, maxConcurrent, cfg.MaxQueueLength, cfg.QueueTimeout)
+	metricQueueWait.WithLabelValues(scope).Observe(time.Since(start).Seconds())
+
+	switch {
+	case err == nil:
+		return release, true
+	case errors.Is(err, limit.ErrQueueFull):
+		metricConcurrencyRejected.WithLabelValues(scope, "queue_full").Inc()
+		retryResponce(w, err.Error(), http.StatusServiceUnavailable, cfg.QueueTimeout)
+	case errors.Is(err, limit.ErrQueueTimeout), errors.Is(err, context.DeadlineExceeded):
+		metricConcurrencyRejected.WithLabelValues(scope, "timeout").Inc()
+		retryResponce(w, limit.ErrQueueTimeout.Error(), http.StatusServiceUnavailable, cfg.QueueTimeout)
+	default:
+		// Client has gone away, nobody to respond to
+	}
+	return nil, false
+
+}
+
+// Client identity: the configured header, or remote IP if it is not set or empty.
+// The header is trusted as is, it must be set by a proxy in front of the service
+func clientId(r *http.Request, header string) string {
+
+	if header != "" {
+		if id := r.Header.Get(header); id != "" {
+			return id
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+
+}
+
+func retryResponce(w http.ResponseWriter, message string, httpStatus int, retryAfter time.Duration) {
+
+	seconds := max(1, int(math.Ceil(retryAfter.Seconds())))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	errorResponce(w, message, httpStatus)
+
+}
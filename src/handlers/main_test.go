@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"sql-proxy/src/app"
+	"sql-proxy/src/db"
+)
+
+func TestMain(m *testing.M) {
+	logger := app.NewConsoleLogger()
+	logger.Logger.SetOutput(io.Discard)
+	app.InitLogger(logger)
+	db.Handler.Init()
+	os.Exit(m.Run())
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -19,20 +20,36 @@ func PrepareStatement(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn, ok := db.Handler.GetById(connId, true)
+	conn, ok := db.Handler.Acquire(connId)
 	if !ok {
 		errorResponce(w, "Invalid connection id", http.StatusForbidden)
 		return
 	}
+	defer conn.Release()
 
-	stmt, err := conn.Prepare(sqlQuery)
+	// ADODB style ? parameters, opt-in as ? may be an operator or part of names
+	if r.Header.Get("Translate-Placeholders") == "true" {
+		translated, err := db.TranslatePlaceholders(sqlQuery, conn.Dialect)
+		if err != nil {
+			errorResponce(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sqlQuery = translated
+	}
+
+	ctx, done := trackQuery(w, r, "prepare", connId, "", sqlQuery)
+	defer done()
+
+	// Statement must outlive the request, so the context is used for preparation only
+	stmt, err := conn.DB.PrepareContext(ctx, sqlQuery)
 	if err != nil {
 		errorResponce(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	stmtId, ok := db.Handler.PutPreparedStatement(connId, stmt)
+	stmtId, ok := db.Handler.PutPreparedStatement(connId, stmt, sqlQuery)
 	if !ok {
+		stmt.Close()
 		errorResponce(w, "Error saving statement into pool", http.StatusInternalServerError)
 		return
 	}
@@ -54,19 +71,20 @@ func PreparedSelect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dbStmt, ok := db.Handler.GetPreparedStatement(connId, stmtId)
+	dbStmt, ok := db.Handler.AcquirePreparedStatement(connId, stmtId)
 	if !ok {
 		errorResponce(w, "Prepared statement not found", http.StatusForbidden)
 		return
 	}
-	rows, err := dbStmt.Query(params...)
-	if err != nil {
-		errorResponce(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
+	defer dbStmt.Release()
+
+	ctx, done := trackQuery(w, r, "prepared_query", connId, stmtId, dbStmt.Query)
+	defer done()
+	ctx = db.SelectContext(ctx, dbStmt.Conn.Dialect)
 
-	tableResponce(w, rows)
+	selectResponce(w, r, dbStmt.Conn, dbStmt.Query, params, func() (*sql.Rows, error) {
+		return dbStmt.Stmt.QueryContext(ctx, params...)
+	})
 
 }
 
@@ -81,11 +99,21 @@ func PreparedExecute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dbStmt, ok := db.Handler.GetPreparedStatement(connId, stmtId)
+	dbStmt, ok := db.Handler.AcquirePreparedStatement(connId, stmtId)
 	if !ok {
 		errorResponce(w, "Prepared statement not found", http.StatusForbidden)
+		return
+	}
+	defer dbStmt.Release()
+
+	if ok := checkWritable(w, dbStmt.Conn); !ok {
+		return
 	}
-	_, err := dbStmt.Exec(params...)
+
+	ctx, done := trackQuery(w, r, "prepared_exec", connId, stmtId, dbStmt.Query)
+	defer done()
+
+	_, err := dbStmt.Stmt.ExecContext(ctx, params...)
 	if err != nil {
 		errorResponce(w, err.Error(), http.StatusInternalServerError)
 	}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"sql-proxy/src/app"
+	"sql-proxy/src/db"
+)
+
+type ProcedureEnvelope struct {
+	ApiVersion  string              `json:"api_version"`
+	ReturnValue any                 `json:"return_value"` // SQL Server return status, or function result
+	Output      map[string]any      `json:"output"`       // OUT and INOUT parameters by name
+	ResultSets  []*ResponseEnvelope `json:"result_sets"`
+}
+
+// Calls the stored procedure with output parameters, as ADODB Command does
+func CallProcedure(w http.ResponseWriter, r *http.Request) {
+
+	if ok := checkApiVersion(w, r); !ok {
+		return
+	}
+
+	connId := r.Header.Get("Connection-Id")
+	if connId == "" {
+		errorResponce(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	var call db.ProcedureCall
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&call); err != nil {
+		errorResponce(w, "Error decoding JSON", http.StatusBadRequest)
+		return
+	}
+
+	dbConn, ok := db.Handler.Acquire(connId)
+	if !ok {
+		errorResponce(w, "Invalid connection id", http.StatusForbidden)
+		return
+	}
+	defer dbConn.Release()
+
+	if ok := checkWritable(w, dbConn); !ok {
+		return
+	}
+
+	app.Logger.Debugf("Procedure call received: name=%s, connection_id=%s", call.Name, connId)
+
+	ctx, done := trackQuery(w, r, "procedure", connId, "", call.Name)
+	defer done()
+
+	run, err := db.CallProcedure(ctx, dbConn, &call)
+	if errors.Is(err, db.ErrProcedureNotSupported) {
+		errorResponce(w, err.Error(), http.StatusNotImplemented)
+		return
+	} else if err != nil {
+		errorResponce(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer run.Close()
+
+	envelope := ProcedureEnvelope{
+		ApiVersion: app.ApiVersion,
+		ResultSets: []*ResponseEnvelope{},
+	}
+
+	if rows := run.Rows; rows != nil {
+		for {
+			// Statements without results give sets without columns
+			if columns, err := rows.Columns(); err == nil && len(columns) > 0 {
+				resultSet, err := newTableEnvelope(rows, dbConn.Info.DbType)
+				if err != nil {
+					errorResponce(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				envelope.ResultSets = append(envelope.ResultSets, resultSet)
+			}
+			if !rows.NextResultSet() {
+				break
+			}
+		}
+		if err = rows.Err(); err != nil {
+			errorResponce(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if envelope.Output, envelope.ReturnValue, err = run.Outputs(); err != nil {
+		errorResponce(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(envelope)
+
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"database/sql"
 	"io"
 	"net/http"
 
@@ -19,20 +20,20 @@ func SelectQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dbConn, ok := db.Handler.GetById(connId, true)
+	dbConn, ok := db.Handler.Acquire(connId)
 	if !ok {
 		errorResponce(w, "Invalid connection id", http.StatusForbidden)
 		return
 	}
+	defer dbConn.Release()
 
-	rows, err := dbConn.Query(sqlQuery)
-	if err != nil {
-		errorResponce(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
+	ctx, done := trackQuery(w, r, "query", connId, "", sqlQuery)
+	defer done()
+	ctx = db.SelectContext(ctx, dbConn.Dialect)
 
-	tableResponce(w, rows)
+	selectResponce(w, r, dbConn, sqlQuery, nil, func() (*sql.Rows, error) {
+		return dbConn.DB.QueryContext(ctx, sqlQuery)
+	})
 
 }
 
@@ -47,13 +48,21 @@ func ExecuteQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dbConn, ok := db.Handler.GetById(connId, true)
+	dbConn, ok := db.Handler.Acquire(connId)
 	if !ok {
 		errorResponce(w, "Invalid connection id", http.StatusForbidden)
 		return
 	}
+	defer dbConn.Release()
+
+	if ok := checkWritable(w, dbConn); !ok {
+		return
+	}
+
+	ctx, done := trackQuery(w, r, "exec", connId, "", sqlQuery)
+	defer done()
 
-	_, err := dbConn.Exec(sqlQuery)
+	_, err := dbConn.DB.ExecContext(ctx, sqlQuery)
 	if err != nil {
 		errorResponce(w, err.Error(), http.StatusBadRequest)
 	}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"sql-proxy/src/app"
+	"sql-proxy/src/db"
+	"sql-proxy/src/replay"
+
+	"github.com/google/uuid"
+)
+
+var (
+	recorder *replay.Recorder // record mode
+	player   *replay.Player   // replay mode
+
+	// Prepared statements of replay mode, in record mode the SQL is taken from the pools
+	replayStatements = replayStmts{items: map[string]*replayStmt{}}
+)
+
+type ReplayInfo struct {
+	Mode       string        `json:"mode"` // empty if off
+	File       string        `json:"file,omitempty"`
+	Match      string        `json:"match,omitempty"`
+	Recorded   int64         `json:"recorded"`   // record mode: entries written since start
+	Recordings int           `json:"recordings"` // replay mode: distinct requests loaded
+	Hits       int64         `json:"hits"`
+	Misses     []replay.Miss `json:"misses"`
+}
+
+// Opens the recording file for the configured mode, called on startup
+func InitReplay(cfg app.ReplayConfig) error {
+
+	var err error
+	switch cfg.Mode {
+	case app.ReplayRecord:
+		recorder, err = replay.OpenRecorder(cfg.File)
+	case app.ReplayPlay:
+		player, err = replay.LoadPlayer(cfg.File, cfg.Match)
+		if err == nil {
+			app.Logger.Warnf("Replay mode: responses are served from %s, no databases are used", cfg.File)
+		}
+	}
+	return err
+
+}
+
+func CloseReplay() {
+	if recorder != nil {
+		recorder.Close()
+	}
+}
+
+// Records query, prepared and blob requests with their responses, or serves
+// the recorded responses in replay mode. Requests are keyed by SQL and parameters
+func Replay(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		switch {
+		case player != nil:
+			replayResponce(w, r)
+		case recorder != nil:
+			recordResponce(next, w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+
+	})
+}
+
+func recordResponce(next http.Handler, w http.ResponseWriter, r *http.Request) {
+
+	kind := replayKind(r)
+	if kind == "" || kind == "connect" || kind == "disconnect" {
+		next.ServeHTTP(w, r)
+		return
+	}
+	if kind == "unprepare" {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	body, ok := readReplayBody(w, r, kind)
+	if !ok {
+		return
+	}
+	sqlQuery, params, ok := readReplayRequest(r, kind, body)
+	if !ok {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(rec, r)
+
+	// Not modified cache results, limits and unknown connection ids depend on the client state
+	switch rec.status {
+	case http.StatusNotModified, http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return
+	}
+	entry := replay.NewEntry(kind, sqlQuery, params, rec.status, rec.Header().Get("Content-Type"), rec.body.Bytes())
+	if err := recorder.Write(entry); err != nil {
+		app.Logger.Errorf("Recording failed: %v", err)
+	}
+
+}
+
+func replayResponce(w http.ResponseWriter, r *http.Request) {
+
+	if ok := checkApiVersion(w, r); !ok {
+		return
+	}
+
+	kind := replayKind(r)
+	switch kind {
+	case "":
+		errorResponce(w, "Not available in replay mode", http.StatusNotImplemented)
+		return
+	case "connect":
+		w.Write([]byte(uuid.New().String()))
+		return
+	case "disconnect":
+		return
+	case "unprepare":
+		replayStatements.remove(r.Header.Get("Statement-Id"))
+		return
+	}
+
+	body, ok := readReplayBody(w, r, kind)
+	if !ok {
+		return
+	}
+	sqlQuery, params, ok := readReplayRequest(r, kind, body)
+	if !ok {
+		if kind == "prepared_query" || kind == "prepared_exec" {
+			errorResponce(w, "Prepared statement not found", http.StatusForbidden)
+		} else {
+			errorResponce(w, "Bad request", http.StatusBadRequest)
+		}
+		return
+	}
+
+	entry, found := player.Find(kind, sqlQuery, params)
+
+	// Statements are prepared unless preparation was recorded failing
+	if kind == "prepare" && (!found || entry.Status == http.StatusOK) {
+		stmtId := uuid.New().String()
+		replayStatements.add(stmtId, sqlQuery)
+		w.Write([]byte(stmtId))
+		return
+	}
+
+	if !found {
+		app.Logger.Warnf("Replay miss: kind=%s, sql=%s, params=%s", kind, sqlQuery, string(params))
+		errorResponce(w, "No recorded response for the request", http.StatusNotFound)
+		return
+	}
+
+	data, err := entry.Data()
+	if err != nil {
+		errorResponce(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if entry.ContentType != "" {
+		w.Header().Set("Content-Type", entry.ContentType)
+	}
+	w.WriteHeader(entry.Status)
+	w.Write(data)
+
+}
+
+// Request kind by the route, empty for requests which are not recorded
+func replayKind(r *http.Request) string {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
+	switch r.Method + " " + path {
+	case "POST /connection":
+		return "connect"
+	case "DELETE /connection":
+		return "disconnect"
+	case "POST /query":
+		return "query"
+	case "PUT /query":
+		return "exec"
+	case "POST /prepared":
+		return "prepare"
+	case "DELETE /prepared":
+		return "unprepare"
+	case "POST /prepared/query":
+		return "prepared_query"
+	case "PUT /prepared/query":
+		return "prepared_exec"
+	case "POST /blob":
+		return "blob_read"
+	case "PUT /blob":
+		return "blob_write"
+	}
+	return ""
+}
+
+// Room for the form fields of blob uploads besides the data
+const maxBlobFormFields = 1 << 20
+
+// Reads the body to memory and restores it for the handler. Bodies are limited
+// by the blob size, replies with an error if the body is too large or can't be read
+func readReplayBody(w http.ResponseWriter, r *http.Request, kind string) ([]byte, bool) {
+
+	limit := app.GetConfig().Limits.MaxBlobSize
+	if kind == "blob_write" {
+		limit += maxBlobFormFields
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	r.Body.Close()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			errorResponce(w, "Data too large", http.StatusRequestEntityTooLarge)
+		} else {
+			errorResponce(w, "Bad request", http.StatusBadRequest)
+		}
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+
+}
+
+// SQL and parameters of the request
+func readReplayRequest(r *http.Request, kind string, body []byte) (string, json.RawMessage, bool) {
+
+	switch kind {
+	case "prepared_query", "prepared_exec":
+		sqlQuery, ok := replayStatementQuery(r)
+		if !ok {
+			return "", nil, false
+		}
+		return sqlQuery, replay.CanonicalParams(body), true
+
+	case "blob_write":
+		// Parsed from a copy, the handler parses the body again
+		form := r.Clone(r.Context())
+		form.Body = io.NopCloser(bytes.NewReader(body))
+		if err := form.ParseMultipartForm(app.GetConfig().Limits.MaxBlobSize); err != nil {
+			return "", nil, false
+		}
+		defer form.MultipartForm.RemoveAll()
+		file, _, err := form.FormFile("binary_data")
+		if err != nil {
+			return "", nil, false
+		}
+		defer file.Close()
+		h := sha256.New()
+		if _, err = io.Copy(h, file); err != nil {
+			return "", nil, false
+		}
+		params, _ := json.Marshal([]string{form.FormValue("blob_type"), hex.EncodeToString(h.Sum(nil))})
+		return form.FormValue("sql_query"), params, true
+	}
+
+	return string(body), nil, true
+
+}
+
+// SQL of the prepared statement of the request
+func replayStatementQuery(r *http.Request) (string, bool) {
+
+	stmtId := r.Header.Get("Statement-Id")
+	if player != nil {
+		return replayStatements.find(stmtId)
+	}
+
+	dbStmt, ok := db.Handler.AcquirePreparedStatement(r.Header.Get("Connection-Id"), stmtId)
+	if !ok {
+		return "", false
+	}
+	defer dbStmt.Release()
+	return dbStmt.Query, true
+
+}
+
+// Replay mode statements by id. Clients may never unprepare them, so like
+// pool statements they are dropped when not used for pool.stmt_idle_timeout
+type replayStmts struct {
+	mu        sync.Mutex
+	items     map[string]*replayStmt
+	lastPrune time.Time
+}
+
+type replayStmt struct {
+	query   string
+	lastUse time.Time
+}
+
+func (s *replayStmts) add(stmtId, query string) {
+
+	cfg := app.GetConfig()
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Checked once per maintenance interval, not on every prepare
+	if timeout := cfg.Pool.StmtIdleTimeout; timeout > 0 && now.Sub(s.lastPrune) > cfg.Maintenance.Interval {
+		s.lastPrune = now
+		maps.DeleteFunc(s.items, func(_ string, stmt *replayStmt) bool {
+			return now.Sub(stmt.lastUse) > timeout
+		})
+	}
+	s.items[stmtId] = &replayStmt{query: query, lastUse: now}
+
+}
+
+func (s *replayStmts) find(stmtId string) (string, bool) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stmt, ok := s.items[stmtId]
+	if !ok {
+		return "", false
+	}
+	stmt.lastUse = time.Now()
+	return stmt.query, true
+
+}
+
+func (s *replayStmts) remove(stmtId string) {
+	s.mu.Lock()
+	delete(s.items, stmtId)
+	s.mu.Unlock()
+}
+
+// Passes the response to the client keeping a copy of the body
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func AdminReplayInfo(w http.ResponseWriter, r *http.Request) {
+
+	cfg := app.GetConfig().Replay
+	info := ReplayInfo{Mode: cfg.Mode, Misses: []replay.Miss{}}
+	if cfg.Mode != "" {
+		info.File = cfg.File
+	}
+	if recorder != nil {
+		info.Recorded = recorder.Recorded()
+	}
+	if player != nil {
+		info.Match = player.Match()
+		info.Recordings = player.Recordings()
+		info.Hits, info.Misses = player.Stats()
+	}
+	jsonResponce(w, info)
+
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sql-proxy/src/app"
+	"sql-proxy/src/replay"
+)
+
+// Starts recording to a temporary file and returns its name
+func setupRecorder(t *testing.T) string {
+
+	path := filepath.Join(t.TempDir(), "replay.jsonl")
+	var err error
+	recorder, err = replay.OpenRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		recorder.Close()
+		recorder = nil
+	})
+	return path
+
+}
+
+func TestRecordBodyLimit(t *testing.T) {
+
+	t.Setenv("CONFIG_FILE", "")
+	if _, err := app.LoadConfig("", func(cfg *app.Config) {
+		cfg.Limits.MaxBlobSize = 1024
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		app.LoadConfig("", nil)
+	})
+	setupRecorder(t)
+
+	blob := func(size int) (io.Reader, string) {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("sql_query", "UPDATE t SET b = ?")
+		part, _ := form.CreateFormFile("binary_data", "data")
+		part.Write(bytes.Repeat([]byte{1}, size))
+		form.Close()
+		return &body, form.FormDataContentType()
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   func() (io.Reader, string)
+		status int
+	}{
+		{"query", "POST", "/api/v1/query",
+			func() (io.Reader, string) { return strings.NewReader("SELECT 1"), "text/plain" }, http.StatusOK},
+		{"query too large", "POST", "/api/v1/query",
+			func() (io.Reader, string) { return strings.NewReader(strings.Repeat("x", 1025)), "text/plain" }, http.StatusRequestEntityTooLarge},
+		{"blob of the max size", "PUT", "/api/v1/blob",
+			func() (io.Reader, string) { return blob(1024) }, http.StatusOK},
+		{"blob too large", "PUT", "/api/v1/blob",
+			func() (io.Reader, string) { return blob(2 << 20) }, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := -1
+			handler := Replay(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				received = len(data)
+			}))
+			body, contentType := tt.body()
+			r := httptest.NewRequest(tt.method, tt.path, body)
+			r.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("got %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status == http.StatusOK && int64(received) != r.ContentLength {
+				t.Errorf("handler got %d bytes, want %d", received, r.ContentLength)
+			}
+			if tt.status != http.StatusOK && received >= 0 {
+				t.Errorf("handler called for a rejected body")
+			}
+		})
+	}
+
+}
+
+func TestReplayStatementsIdle(t *testing.T) {
+
+	t.Setenv("CONFIG_FILE", "")
+	if _, err := app.LoadConfig("", func(cfg *app.Config) {
+		cfg.Pool.StmtIdleTimeout = time.Minute
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		app.LoadConfig("", nil)
+	})
+
+	stmts := replayStmts{items: map[string]*replayStmt{}}
+	stmts.add("lost", "SELECT 1")
+	stmts.add("used", "SELECT 2")
+	stmts.items["lost"].lastUse = time.Now().Add(-time.Hour)
+	stmts.items["used"].lastUse = time.Now().Add(-time.Hour)
+	if _, ok := stmts.find("used"); !ok {
+		t.Fatal("statement not found")
+	}
+
+	// Pruned on the next prepare after the maintenance interval
+	stmts.add("new", "SELECT 3")
+	if len(stmts.items) != 3 {
+		t.Fatalf("pruned before the maintenance interval: %d statements", len(stmts.items))
+	}
+	stmts.lastPrune = time.Time{}
+	stmts.add("new", "SELECT 3")
+	if _, ok := stmts.find("lost"); ok {
+		t.Error("idle statement kept")
+	}
+	if query, ok := stmts.find("used"); !ok || query != "SELECT 2" {
+		t.Errorf("used statement: %q %v", query, ok)
+	}
+
+	stmts.remove("used")
+	if _, ok := stmts.find("used"); ok {
+		t.Error("removed statement found")
+	}
+
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"sql-proxy/src/app"
+	"sql-proxy/src/db"
+
+	"github.com/gorilla/mux"
+)
+
+type SchemaEnvelope struct {
+	ApiVersion string `json:"api_version"`
+	Object     string `json:"object"`
+	Items      any    `json:"items"`
+}
+
+// Lists schema objects of the connection, as ADODB OpenSchema does.
+// Filtered by the schema, table and name (LIKE pattern) query parameters
+func ListSchema(w http.ResponseWriter, r *http.Request) {
+
+	if ok := checkApiVersion(w, r); !ok {
+		return
+	}
+
+	connId := r.Header.Get("Connection-Id")
+	if connId == "" {
+		errorResponce(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	object := mux.Vars(r)["object"]
+	query := r.URL.Query()
+	filter := db.SchemaFilter{
+		Schema: query.Get("schema"),
+		Table:  query.Get("table"),
+		Name:   query.Get("name"),
+	}
+
+	dbConn, ok := db.Handler.Acquire(connId)
+	if !ok {
+		errorResponce(w, "Invalid connection id", http.StatusForbidden)
+		return
+	}
+	defer dbConn.Release()
+
+	ctx, done := trackQuery(w, r, "schema", connId, "", object)
+	defer done()
+
+	items, err := db.ListSchema(ctx, dbConn, object, filter)
+	if errors.Is(err, db.ErrUnknownSchemaObject) {
+		errorResponce(w, err.Error(), http.StatusNotFound)
+		return
+	} else if errors.Is(err, db.ErrSchemaNotSupported) {
+		errorResponce(w, err.Error(), http.StatusNotImplemented)
+		return
+	} else if err != nil {
+		errorResponce(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SchemaEnvelope{
+		ApiVersion: app.ApiVersion,
+		Object:     object,
+		Items:      items,
+	})
+
+}
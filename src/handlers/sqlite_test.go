@@ -0,0 +1,412 @@
+//go:build sqlite
+// +build sqlite
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"sql-proxy/src/app"
+	"sql-proxy/src/replay"
+)
+
+// Loads settings with the SQLite file directory allowed and returns the file name
+func setupSqlite(t *testing.T, flags func(*app.Config)) string {
+
+	t.Setenv("CONFIG_FILE", "")
+	dir := t.TempDir()
+	_, err := app.LoadConfig("", func(cfg *app.Config) {
+		cfg.SQLite.AllowedDirs = []string{dir}
+		cfg.SQLite.AllowCreate = true
+		if flags != nil {
+			flags(cfg)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		app.LoadConfig("", nil)
+	})
+	return filepath.Join(dir, "test.db")
+
+}
+
+// Calls the handler and returns the response
+func serve(t *testing.T, handler http.HandlerFunc, method, body string, headers map[string]string) *httptest.ResponseRecorder {
+
+	t.Helper()
+	r := httptest.NewRequest(method, "/api/v1", bytes.NewBufferString(body))
+	r.Header.Set("API-Version", app.ApiVersion)
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+
+}
+
+func connect(t *testing.T, path string, readOnly bool) string {
+
+	t.Helper()
+	info, _ := json.Marshal(map[string]any{"db_type": "sqlite", "db_name": path, "read_only": readOnly})
+	w := serve(t, CreateConnection, "POST", string(info), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("connection: %d %s", w.Code, w.Body)
+	}
+	connId := w.Body.String()
+	t.Cleanup(func() {
+		serve(t, CloseConnection, "DELETE", "", map[string]string{"Connection-Id": connId})
+	})
+	return connId
+
+}
+
+func exec(t *testing.T, handler http.HandlerFunc, connId, sqlQuery string) {
+
+	t.Helper()
+	w := serve(t, handler, "PUT", sqlQuery, map[string]string{"Connection-Id": connId})
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: %d %s", sqlQuery, w.Code, w.Body)
+	}
+
+}
+
+func selectRows(t *testing.T, connId, sqlQuery string) ResponseEnvelope {
+
+	t.Helper()
+	w := serve(t, SelectQuery, "POST", sqlQuery, map[string]string{"Connection-Id": connId})
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: %d %s", sqlQuery, w.Code, w.Body)
+	}
+	var envelope ResponseEnvelope
+	if err := json.NewDecoder(w.Body).Decode(&envelope); err != nil {
+		t.Fatal(err)
+	}
+	return envelope
+
+}
+
+func TestSqliteConnection(t *testing.T) {
+
+	path := setupSqlite(t, nil)
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"invalid JSON", "{", http.StatusBadRequest},
+		{"unknown profile", `{"db_type": "sqlite", "profile": "none"}`, http.StatusBadRequest},
+		{"outside of allowed directories", `{"db_type": "sqlite", "db_name": "/etc/test.db"}`, http.StatusForbidden},
+		{"unsupported option", `{"db_type": "sqlite", "db_name": "test.db", "connect_timeout": "5s"}`, http.StatusBadRequest},
+		{"unknown server type", `{"db_type": "unknown", "host": "srv"}`, http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(t, CreateConnection, "POST", tt.body, nil); w.Code != tt.code {
+				t.Errorf("got %d %s, want %d", w.Code, w.Body, tt.code)
+			}
+		})
+	}
+
+	// The same parameters share the pool
+	connId := connect(t, path, false)
+	if other := connect(t, path, false); other != connId {
+		t.Errorf("second connection %s, want %s", other, connId)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error(err)
+	}
+
+	if w := serve(t, CreateConnection, "POST", "{}", map[string]string{"API-Version": "0"}); w.Code != http.StatusNotImplemented {
+		t.Errorf("API version: got %d, want %d", w.Code, http.StatusNotImplemented)
+	}
+
+}
+
+func TestSqliteQuery(t *testing.T) {
+
+	path := setupSqlite(t, func(cfg *app.Config) {
+		cfg.Limits.MaxRows = 2
+	})
+	connId := connect(t, path, false)
+
+	exec(t, ExecuteQuery, connId, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, price REAL)")
+	exec(t, ExecuteQuery, connId, "INSERT INTO items (name, price) VALUES ('one', 1.5), ('two', NULL), ('three', 3)")
+
+	envelope := selectRows(t, connId, "SELECT id, name, price FROM items ORDER BY id")
+	if envelope.RowsCount != 2 || !envelope.ExceedsMaxRows || len(envelope.Rows) != 2 {
+		t.Errorf("max_rows: rows_count %d, exceeds_max_rows %v, %d rows", envelope.RowsCount, envelope.ExceedsMaxRows, len(envelope.Rows))
+	}
+	if len(envelope.Columns) != 3 || envelope.Columns[1].Name != "name" || envelope.Columns[1].Type != "TEXT" {
+		t.Errorf("columns %+v", envelope.Columns)
+	}
+	if row := envelope.Rows[0]; row["name"] != "one" || row["price"] != 1.5 {
+		t.Errorf("row %v", row)
+	}
+	if row := envelope.Rows[1]; row["price"] != nil {
+		t.Errorf("NULL price: %v", row["price"])
+	}
+
+	envelope = selectRows(t, connId, "SELECT count(*) AS n FROM items")
+	if envelope.RowsCount != 1 || envelope.ExceedsMaxRows || envelope.Rows[0]["n"] != float64(3) {
+		t.Errorf("count: %+v", envelope)
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		body    string
+		connId  string
+		code    int
+	}{
+		{"select syntax error", SelectQuery, "POST", "SELEC 1", connId, http.StatusInternalServerError},
+		{"execute syntax error", ExecuteQuery, "PUT", "INSER INTO items", connId, http.StatusBadRequest},
+		{"empty query", SelectQuery, "POST", "", connId, http.StatusBadRequest},
+		{"no connection id", SelectQuery, "POST", "SELECT 1", "", http.StatusBadRequest},
+		{"invalid connection id", SelectQuery, "POST", "SELECT 1", "unknown", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, tt.handler, tt.method, tt.body, map[string]string{"Connection-Id": tt.connId})
+			if w.Code != tt.code {
+				t.Errorf("got %d %s, want %d", w.Code, w.Body, tt.code)
+			}
+		})
+	}
+
+}
+
+func TestSqlitePrepared(t *testing.T) {
+
+	path := setupSqlite(t, nil)
+	connId := connect(t, path, false)
+	exec(t, ExecuteQuery, connId, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)")
+
+	prepare := func(sqlQuery string) string {
+		w := serve(t, PrepareStatement, "POST", sqlQuery, map[string]string{"Connection-Id": connId})
+		if w.Code != http.StatusOK {
+			t.Fatalf("prepare %s: %d %s", sqlQuery, w.Code, w.Body)
+		}
+		return w.Body.String()
+	}
+
+	insert := prepare("INSERT INTO items (id, name) VALUES (?, ?)")
+	for _, params := range []string{`[1, "one"]`, `[2, "two"]`} {
+		w := serve(t, PreparedExecute, "PUT", params, map[string]string{"Connection-Id": connId, "Statement-Id": insert})
+		if w.Code != http.StatusOK {
+			t.Fatalf("execute %s: %d %s", params, w.Code, w.Body)
+		}
+	}
+
+	query := prepare("SELECT name FROM items WHERE id = ?")
+	w := serve(t, PreparedSelect, "POST", "[2]", map[string]string{"Connection-Id": connId, "Statement-Id": query})
+	var envelope ResponseEnvelope
+	if err := json.NewDecoder(w.Body).Decode(&envelope); err != nil {
+		t.Fatalf("%d: %v", w.Code, err)
+	}
+	if envelope.RowsCount != 1 || envelope.Rows[0]["name"] != "two" {
+		t.Errorf("prepared select: %+v", envelope)
+	}
+
+	if w := serve(t, PrepareStatement, "POST", "SELECT FROM", map[string]string{"Connection-Id": connId}); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid statement: got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := serve(t, PreparedSelect, "POST", "[1", map[string]string{"Connection-Id": connId, "Statement-Id": query}); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid parameters: got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	headers := map[string]string{"Connection-Id": connId, "Statement-Id": query}
+	if w := serve(t, ClosePreparedStatement, "DELETE", "", headers); w.Code != http.StatusOK {
+		t.Errorf("close: got %d %s", w.Code, w.Body)
+	}
+	if w := serve(t, PreparedSelect, "POST", "[1]", headers); w.Code != http.StatusForbidden {
+		t.Errorf("closed statement: got %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+}
+
+func TestSqliteBlob(t *testing.T) {
+
+	path := setupSqlite(t, func(cfg *app.Config) {
+		cfg.Limits.MaxBlobSize = 16
+	})
+	connId := connect(t, path, false)
+	exec(t, ExecuteQuery, connId, "CREATE TABLE files (id INTEGER PRIMARY KEY, data BLOB)")
+
+	writeBlob := func(sqlQuery string, data []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("sql_query", sqlQuery)
+		part, _ := form.CreateFormFile("binary_data", "data.bin")
+		part.Write(data)
+		form.Close()
+
+		r := httptest.NewRequest("PUT", "/api/v1/blob", &body)
+		r.Header.Set("API-Version", app.ApiVersion)
+		r.Header.Set("Connection-Id", connId)
+		r.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		WriteBlob(w, r)
+		return w
+	}
+
+	data := []byte{0, 1, 2, 0xff, 'a'}
+	if w := writeBlob("INSERT INTO files (id, data) VALUES (1, ?)", data); w.Code != http.StatusOK {
+		t.Fatalf("write: %d %s", w.Code, w.Body)
+	}
+	exec(t, ExecuteQuery, connId, "INSERT INTO files (id, data) VALUES (2, zeroblob(32))")
+
+	w := serve(t, ReadBlob, "POST", "SELECT data FROM files WHERE id = 1", map[string]string{"Connection-Id": connId})
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/octet-stream" {
+		t.Fatalf("read: %d %s %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	if got, _ := io.ReadAll(w.Body); !bytes.Equal(got, data) {
+		t.Errorf("read %v, want %v", got, data)
+	}
+
+	if w := serve(t, ReadBlob, "POST", "SELECT data FROM files WHERE id = 2", map[string]string{"Connection-Id": connId}); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large BLOB: got %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	if w := serve(t, ReadBlob, "POST", "SELECT data FROM files WHERE id = 3", map[string]string{"Connection-Id": connId}); w.Code != http.StatusBadRequest {
+		t.Errorf("no rows: got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+}
+
+func TestSqliteReadOnly(t *testing.T) {
+
+	path := setupSqlite(t, nil)
+	exec(t, ExecuteQuery, connect(t, path, false), "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)")
+
+	connId := connect(t, path, true)
+	if envelope := selectRows(t, connId, "SELECT count(*) AS n FROM items"); envelope.Rows[0]["n"] != float64(0) {
+		t.Errorf("count: %+v", envelope)
+	}
+
+	headers := map[string]string{"Connection-Id": connId}
+	for _, sqlQuery := range []string{
+		"INSERT INTO items (name) VALUES ('one')",
+		"UPDATE items SET name = 'two'",
+		"CREATE TABLE other (id INTEGER)",
+	} {
+		if w := serve(t, ExecuteQuery, "PUT", sqlQuery, headers); w.Code == http.StatusOK {
+			t.Errorf("%s: not rejected", sqlQuery)
+		}
+	}
+
+	w := serve(t, PrepareStatement, "POST", "INSERT INTO items (name) VALUES (?)", headers)
+	if w.Code == http.StatusOK {
+		stmtHeaders := map[string]string{"Connection-Id": connId, "Statement-Id": w.Body.String()}
+		if w := serve(t, PreparedExecute, "PUT", `["one"]`, stmtHeaders); w.Code == http.StatusOK {
+			t.Error("prepared insert: not rejected")
+		}
+	}
+
+	if envelope := selectRows(t, connId, "SELECT count(*) AS n FROM items"); envelope.Rows[0]["n"] != float64(0) {
+		t.Errorf("rows written: %+v", envelope)
+	}
+
+}
+
+func TestSqliteAttach(t *testing.T) {
+
+	path := setupSqlite(t, nil)
+	exec(t, ExecuteQuery, connect(t, path, false), "CREATE TABLE items (id INTEGER PRIMARY KEY)")
+
+	// Existing file to read and a new one to create outside of the allowed directory
+	outside := t.TempDir()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(outside, "secret.db"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(outside, "created.db")
+
+	for _, readOnly := range []bool{false, true} {
+		headers := map[string]string{"Connection-Id": connect(t, path, readOnly)}
+		for _, sqlQuery := range []string{
+			"ATTACH DATABASE '" + filepath.Join(outside, "secret.db") + "' AS s",
+			"ATTACH DATABASE 'file:" + created + "?mode=rwc' AS e; CREATE TABLE e.t (x)",
+		} {
+			if w := serve(t, ExecuteQuery, "PUT", sqlQuery, headers); w.Code == http.StatusOK {
+				t.Errorf("read_only %v: %s: not rejected", readOnly, sqlQuery)
+			}
+		}
+		if w := serve(t, SelectQuery, "POST", "SELECT count(*) FROM items", headers); w.Code != http.StatusOK {
+			t.Errorf("read_only %v: %d %s", readOnly, w.Code, w.Body)
+		}
+	}
+	if _, err = os.Stat(created); err == nil {
+		t.Error("database file created outside of the allowed directory")
+	}
+
+}
+
+func TestSqliteRecordPrepared(t *testing.T) {
+
+	connId := connect(t, setupSqlite(t, nil), false)
+	path := setupRecorder(t)
+
+	// Through the replay middleware, which needs the route paths
+	record := func(handler http.HandlerFunc, method, route, body string, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api/v1"+route, strings.NewReader(body))
+		r.Header.Set("API-Version", app.ApiVersion)
+		for key, value := range headers {
+			r.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		Replay(handler).ServeHTTP(w, r)
+		return w
+	}
+
+	w := record(PrepareStatement, "POST", "/prepared", "SELECT ? + 1", map[string]string{"Connection-Id": connId})
+	if w.Code != http.StatusOK {
+		t.Fatalf("prepare: %d %s", w.Code, w.Body)
+	}
+	headers := map[string]string{"Connection-Id": connId, "Statement-Id": w.Body.String()}
+	if w := record(PreparedSelect, "POST", "/prepared/query", "[1]", headers); w.Code != http.StatusOK {
+		t.Fatalf("prepared query: %d %s", w.Code, w.Body)
+	}
+	if w := record(ClosePreparedStatement, "DELETE", "/prepared", "", headers); w.Code != http.StatusOK {
+		t.Fatalf("unprepare: %d %s", w.Code, w.Body)
+	}
+	if w := record(PreparedSelect, "POST", "/prepared/query", "[1]", headers); w.Code != http.StatusForbidden {
+		t.Fatalf("closed statement: %d %s", w.Code, w.Body)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for line := range strings.Lines(string(data)) {
+		var entry replay.Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.SQL != "SELECT ? + 1" {
+			t.Errorf("%s recorded with sql %q", entry.Kind, entry.SQL)
+		}
+		kinds = append(kinds, entry.Kind)
+	}
+	if !slices.Equal(kinds, []string{"prepare", "prepared_query"}) {
+		t.Errorf("recorded %v", kinds)
+	}
+
+}
@@ -0,0 +1,136 @@
+package limit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Semaphores by key, unused ones are removed
+type Semaphores struct {
+	mu    sync.Mutex
+	items map[string]*semaphoreEntry
+}
+
+type semaphoreEntry struct {
+	sem   Semaphore
+	users int // requests holding or waiting for the semaphore
+}
+
+func NewSemaphores() *Semaphores {
+	return &Semaphores{items: make(map[string]*semaphoreEntry)}
+}
+
+// Takes a slot of the keyed semaphore, see Semaphore.Acquire.
+// The returned function releases it
+func (o *Semaphores) Acquire(ctx context.Context, key string, limit, maxQueue int, timeout time.Duration) (func(), error) {
+	o.mu.Lock()
+	entry, ok := o.items[key]
+	if !ok {
+		entry = &semaphoreEntry{}
+		o.items[key] = entry
+	}
+	entry.users++
+	o.mu.Unlock()
+
+	err := entry.sem.Acquire(ctx, limit, maxQueue, timeout)
+	if err != nil {
+		o.leave(key, entry)
+		return nil, err
+	}
+
+	return func() {
+		entry.sem.Release()
+		o.leave(key, entry)
+	}, nil
+}
+
+// Number of requests waiting for all semaphores
+func (o *Semaphores) Waiting() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	total := 0
+	for _, entry := range o.items {
+		_, waiting := entry.sem.Usage()
+		total += waiting
+	}
+	return total
+}
+
+func (o *Semaphores) leave(key string, entry *semaphoreEntry) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry.users--
+	if entry.users == 0 {
+		delete(o.items, key)
+	}
+}
+
+// Buckets not used for this period are removed
+const rateIdleTimeout = 10 * time.Minute
+
+// Token bucket rate limiters by key
+type RateLimiters struct {
+	mu          sync.Mutex
+	items       map[string]*rateEntry
+	lastCleanup time.Time
+}
+
+type rateEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func NewRateLimiters() *RateLimiters {
+	return &RateLimiters{items: make(map[string]*rateEntry)}
+}
+
+// Takes a token from the keyed bucket. If there is none, returns false
+// and the time after which a token will be available
+func (o *RateLimiters) Allow(key string, perSecond float64, burst int) (bool, time.Duration) {
+	now := time.Now()
+
+	o.mu.Lock()
+	if now.Sub(o.lastCleanup) > rateIdleTimeout {
+		o.cleanup(now)
+	}
+	entry, ok := o.items[key]
+	if !ok {
+		entry = &rateEntry{limiter: rate.NewLimiter(rate.Limit(perSecond), burst)}
+		o.items[key] = entry
+	}
+	entry.lastSeen = now
+	o.mu.Unlock()
+
+	// Settings may be changed by config reload
+	if entry.limiter.Limit() != rate.Limit(perSecond) {
+		entry.limiter.SetLimitAt(now, rate.Limit(perSecond))
+	}
+	if entry.limiter.Burst() != burst {
+		entry.limiter.SetBurstAt(now, burst)
+	}
+
+	reservation := entry.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, time.Second
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Must be called with the lock held
+func (o *RateLimiters) cleanup(now time.Time) {
+	for key, entry := range o.items {
+		if now.Sub(entry.lastSeen) > rateIdleTimeout {
+			delete(o.items, key)
+		}
+	}
+	o.lastCleanup = now
+}
@@ -0,0 +1,95 @@
+package limit
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull    = errors.New("Too many requests waiting")
+	ErrQueueTimeout = errors.New("Timed out waiting for a free slot")
+)
+
+// Counting semaphore with a FIFO wait queue
+type Semaphore struct {
+	mu      sync.Mutex
+	limit   int // 0 = unlimited
+	active  int
+	waiters list.List // of chan struct{}
+}
+
+// Takes a slot, waiting in the queue up to the timeout if all slots are busy.
+// maxQueue limits the number of waiting requests, 0 = unlimited
+func (s *Semaphore) Acquire(ctx context.Context, limit, maxQueue int, timeout time.Duration) error {
+	s.mu.Lock()
+	s.limit = limit
+
+	if s.limit == 0 || (s.active < s.limit && s.waiters.Len() == 0) {
+		s.active++
+		s.mu.Unlock()
+		return nil
+	}
+
+	if maxQueue > 0 && s.waiters.Len() >= maxQueue {
+		s.mu.Unlock()
+		return ErrQueueFull
+	}
+
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(ready)
+	s.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-ready:
+		return nil
+	case <-timer.C:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-ready:
+		// The slot was given while timing out, pass it on
+		s.active--
+		s.wakeUp()
+	default:
+		s.waiters.Remove(elem)
+	}
+	return err
+}
+
+// Frees the slot, the first waiting request takes it
+func (s *Semaphore) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.active--
+	s.wakeUp()
+}
+
+// Number of requests holding and waiting for slots
+func (s *Semaphore) Usage() (active, waiting int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.active, s.waiters.Len()
+}
+
+func (s *Semaphore) wakeUp() {
+	for s.waiters.Len() > 0 && (s.limit == 0 || s.active < s.limit) {
+		ready := s.waiters.Remove(s.waiters.Front()).(chan struct{})
+		s.active++
+		close(ready)
+	}
+}
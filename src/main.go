@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"flag"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,6 +14,7 @@ import (
 	"time"
 
 	"sql-proxy/src/app"
+	"sql-proxy/src/console"
 	"sql-proxy/src/db"
 	"sql-proxy/src/handlers"
 
@@ -34,39 +38,61 @@ func (p *program) Start(s service.Service) error {
 
 func (p *program) run() {
 
-	// Application params taken from OS environment
-	app.DebugLog = app.GetEnvBool("DEBUG_LOG", false)
-	bindAddress := app.GetEnvString("BIND_ADDR", "localhost")
+	cfg := app.GetConfig()
+	bindAddress := cfg.Server.BindAddr
 	if bindAddress == "*" {
 		bindAddress = ""
 	}
-	bindPort := app.GetEnvInt("BIND_PORT", 8080)
-	db.MaxRows = uint32(app.GetEnvInt("MAX_ROWS", 10000))
-	tlsCert := app.GetEnvString("TLS_CERT", "")
-	tlsKey := app.GetEnvString("TLS_KEY", "")
+	bindPort := cfg.Server.BindPort
+	tlsCert := cfg.Server.TLSCert
+	tlsKey := cfg.Server.TLSKey
 
 	// Init connections handler map
 	db.Handler.Init()
 
+	// Recording or replay of requests
+	if err := handlers.InitReplay(cfg.Replay); err != nil {
+		app.Logger.Errorf("Fatal error occurred, service stopped: replay: %v", err)
+		return
+	}
+	defer handlers.CloseReplay()
+
 	// Scheduled maintenance task
 	go db.Handler.RunMaintenance()
 
+	// Config reload on file change or SIGHUP
+	go app.WatchConfig(p.exit)
+	go p.handleReloadSignal()
+
 	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/connection", handlers.CreateConnection).Methods("POST")
-	router.HandleFunc("/api/v1/connection", handlers.CloseConnection).Methods("DELETE")
-	router.HandleFunc("/api/v1/query", handlers.SelectQuery).Methods("POST")
-	router.HandleFunc("/api/v1/query", handlers.ExecuteQuery).Methods("PUT")
-	router.HandleFunc("/api/v1/prepared", handlers.PrepareStatement).Methods("POST")
-	router.HandleFunc("/api/v1/prepared/query", handlers.PreparedSelect).Methods("POST")
-	router.HandleFunc("/api/v1/prepared/query", handlers.PreparedExecute).Methods("PUT")
-	router.HandleFunc("/api/v1/prepared", handlers.ClosePreparedStatement).Methods("DELETE")
-	router.HandleFunc("/api/v1/blob", handlers.ReadBlob).Methods("POST")
-	router.HandleFunc("/api/v1/blob", handlers.WriteBlob).Methods("PUT")
+	registerApiRoutes(router)
 	router.HandleFunc("/healthz", handlers.Healthz).Methods("GET")
 	router.HandleFunc("/readyz", handlers.Readyz).Methods("GET")
 	router.HandleFunc("/livez", handlers.Livez).Methods("GET")
 	router.Handle("/metrics", promhttp.Handler())
 
+	// Admin API, on the main or a separate listener
+	var adminSrv *http.Server
+	if cfg.Admin.Enabled {
+		adminRouter := newAdminRouter()
+		adminHandler := handlers.AdminAuth(adminRouter)
+		if cfg.Admin.BindPort == 0 {
+			router.PathPrefix("/admin/").Handler(adminHandler)
+		} else {
+			// The web console query runner uses the API on the same listener
+			registerApiRoutes(adminRouter)
+			adminAddress := cfg.Admin.BindAddr
+			if adminAddress == "*" {
+				adminAddress = ""
+			}
+			adminSrv = &http.Server{
+				Addr:    fmt.Sprintf("%s:%d", adminAddress, cfg.Admin.BindPort),
+				Handler: adminHandler,
+			}
+		}
+		app.Logger.Infof("Admin API enabled: bind_port=%d, bind_address=%s", cfg.Admin.BindPort, cfg.Admin.BindAddr)
+	}
+
 	app.Logger.Info("(c) 2025 Almaz Sharipov, MIT license, https://github.com/alm494/sql_proxy  ")
 	app.Logger.Infof("build_version=%s, build_time=%s", app.BuildVersion, app.BuildTime)
 	app.Logger.Infof("Server started with the following parameters: "+
@@ -79,17 +105,32 @@ func (p *program) run() {
 		Handler: router,
 	}
 
-	go func() {
-		var err error
-		if len(tlsCert) > 0 && len(tlsKey) > 0 {
-			err = srv.ListenAndServeTLS(tlsCert, tlsKey)
-		} else {
-			err = srv.ListenAndServe()
-		}
-		if err != nil && err != http.ErrServerClosed {
+	// TLS certificates are reloaded with config
+	if len(tlsCert) > 0 && len(tlsKey) > 0 {
+		certs, err := app.NewCertReloader(tlsCert, tlsKey)
+		if err != nil {
 			app.Logger.Errorf("Fatal error occurred, service stopped: %v", err)
+			return
 		}
-	}()
+		srv.TLSConfig = &tls.Config{GetCertificate: certs.GetCertificate}
+		if adminSrv != nil {
+			adminSrv.TLSConfig = srv.TLSConfig
+		}
+		app.OnConfigReload(func(oldCfg, newCfg *app.Config) {
+			if newCfg.Server.TLSCert == "" || newCfg.Server.TLSKey == "" {
+				app.Logger.Warn("Config reload: TLS can't be disabled without restart, certificate kept")
+				return
+			}
+			if err := certs.Load(newCfg.Server.TLSCert, newCfg.Server.TLSKey); err != nil {
+				app.Logger.Errorf("Config reload: TLS certificate not reloaded: %v", err)
+			}
+		})
+	}
+
+	go serve(srv)
+	if adminSrv != nil {
+		go serve(adminSrv)
+	}
 
 	// Wait for exit signal
 	<-p.exit
@@ -97,6 +138,9 @@ func (p *program) run() {
 	// Shutdown server gracefully
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	if adminSrv != nil {
+		adminSrv.Shutdown(ctx)
+	}
 	if err := srv.Shutdown(ctx); err != nil {
 		app.Logger.Errorf("Server shutdown failed: %v", err)
 	} else {
@@ -104,6 +148,74 @@ func (p *program) run() {
 	}
 }
 
+func serve(srv *http.Server) {
+	var err error
+	if srv.TLSConfig != nil {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		app.Logger.Errorf("Fatal error occurred, service stopped: %v", err)
+	}
+}
+
+func registerApiRoutes(router *mux.Router) {
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(handlers.RateLimit)
+	api.Use(handlers.Replay)
+	api.HandleFunc("/connection", handlers.CreateConnection).Methods("POST")
+	api.HandleFunc("/connection", handlers.CloseConnection).Methods("DELETE")
+	api.HandleFunc("/query", handlers.SelectQuery).Methods("POST")
+	api.HandleFunc("/query", handlers.ExecuteQuery).Methods("PUT")
+	api.HandleFunc("/prepared", handlers.PrepareStatement).Methods("POST")
+	api.HandleFunc("/prepared/query", handlers.PreparedSelect).Methods("POST")
+	api.HandleFunc("/prepared/query", handlers.PreparedExecute).Methods("PUT")
+	api.HandleFunc("/prepared", handlers.ClosePreparedStatement).Methods("DELETE")
+	api.HandleFunc("/blob", handlers.ReadBlob).Methods("POST")
+	api.HandleFunc("/blob", handlers.WriteBlob).Methods("PUT")
+	api.HandleFunc("/procedure", handlers.CallProcedure).Methods("POST")
+	api.HandleFunc("/schema/{object}", handlers.ListSchema).Methods("GET")
+	api.HandleFunc("/explain", handlers.ExplainQuery).Methods("POST")
+}
+
+func newAdminRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.Handle("/admin/v1/metrics", promhttp.Handler()).Methods("GET")
+	router.HandleFunc("/admin/v1/pools", handlers.AdminListPools).Methods("GET")
+	router.HandleFunc("/admin/v1/pools/{id}", handlers.AdminClosePool).Methods("DELETE")
+	router.HandleFunc("/admin/v1/pools/{id}/statements/{stmt}", handlers.AdminCloseStatement).Methods("DELETE")
+	router.HandleFunc("/admin/v1/db_types", handlers.AdminListDbTypes).Methods("GET")
+	router.HandleFunc("/admin/v1/draining", handlers.AdminListDraining).Methods("GET")
+	router.HandleFunc("/admin/v1/draining", handlers.AdminSetDraining).Methods("POST", "DELETE")
+	router.HandleFunc("/admin/v1/queries", handlers.AdminListQueries).Methods("GET")
+	router.HandleFunc("/admin/v1/queries/stream", handlers.AdminQueryStream).Methods("GET")
+	router.HandleFunc("/admin/v1/queries/{id}", handlers.AdminCancelQuery).Methods("DELETE")
+	router.HandleFunc("/admin/v1/cache", handlers.AdminInvalidateCache).Methods("DELETE")
+	router.HandleFunc("/admin/v1/replay", handlers.AdminReplayInfo).Methods("GET")
+	router.Handle("/admin/console", http.RedirectHandler("/admin/console/", http.StatusMovedPermanently))
+	router.PathPrefix("/admin/console/").Handler(handlers.AdminConsole(console.Handler("/admin/console/")))
+	return router
+}
+
+func (p *program) handleReloadSignal() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
+
+	for {
+		select {
+		case <-p.exit:
+			return
+		case <-sigChan:
+			app.Logger.Info("SIGHUP received, reloading config...")
+			if err := app.ReloadConfig(); err != nil {
+				app.Logger.Errorf("Config reload failed, previous settings kept: %v", err)
+			}
+		}
+	}
+}
+
 func (p *program) Stop(s service.Service) error {
 	app.Logger.Info("Stopping sql-proxy service...")
 	close(p.exit)
@@ -111,11 +223,44 @@ func (p *program) Stop(s service.Service) error {
 }
 
 func main() {
+	// Command line flags override config file and environment variables
+	configFile := flag.String("config", "", "path to YAML config file (or CONFIG_FILE env)")
+	bindAddr := flag.String("bind-addr", "", "address to bind, * for any")
+	bindPort := flag.Int("bind-port", 0, "port to bind")
+	maxRows := flag.Uint("max-rows", 0, "max rows returned by select")
+	logLevel := flag.String("log-level", "", "debug, info, warn or error")
+	flag.Parse()
+
+	setFlags := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+	applyFlags := func(cfg *app.Config) {
+		if setFlags["bind-addr"] {
+			cfg.Server.BindAddr = *bindAddr
+		}
+		if setFlags["bind-port"] {
+			cfg.Server.BindPort = *bindPort
+		}
+		if setFlags["max-rows"] {
+			if *maxRows <= math.MaxUint32 {
+				cfg.Limits.MaxRows = uint32(*maxRows)
+			} else {
+				// Rejected by validation instead of wrapping around
+				cfg.Limits.MaxRows = 0
+			}
+		}
+		if setFlags["log-level"] {
+			cfg.Log.Level = *logLevel
+		}
+	}
+
+	// Flags given before the service command are passed to the installed service
+	serviceArgs := os.Args[1 : len(os.Args)-flag.NArg()]
+
 	svcConfig := &service.Config{
 		Name:        "sql-proxy",
 		DisplayName: "SQL Proxy Service",
 		Description: "A lightweight REST service designed to replace ADODB calls in legacy software systems that support web requests",
-		Arguments:   []string{},
+		Arguments:   serviceArgs,
 	}
 
 	prg := &program{}
@@ -129,9 +274,9 @@ func main() {
 		log.Fatal(err)
 	}
 
-	if len(os.Args) > 1 {
+	if flag.NArg() > 0 {
 		// Handle service commands: install, start, stop, uninstall
-		err := service.Control(s, os.Args[1])
+		err := service.Control(s, flag.Arg(0))
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -141,6 +286,10 @@ func main() {
 	// Run as a regular app
 	if !service.Interactive() {
 		app.InitLogger(app.NewServiceLogger(svcLogger))
+		if _, err = app.LoadConfig(*configFile, applyFlags); err != nil {
+			svcLogger.Errorf("Invalid configuration: %v", err)
+			os.Exit(1)
+		}
 		err = s.Run()
 		if err != nil {
 			svcLogger.Error(err)
@@ -148,6 +297,9 @@ func main() {
 	} else {
 		// Run in console mode
 		app.InitLogger(app.NewConsoleLogger())
+		if _, err = app.LoadConfig(*configFile, applyFlags); err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
 		fmt.Println("Running in console mode...")
 		prg.Start(nil)
 
@@ -0,0 +1,11 @@
+package replay
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var metricRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sqlproxy_replay_requests_total",
+	Help: "Requests recorded, and replayed requests found or missed in the recording",
+}, []string{"result"})
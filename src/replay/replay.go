@@ -0,0 +1,245 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"sql-proxy/src/app"
+	"sql-proxy/src/db"
+)
+
+// Recorded request and its response, a line of the recording file
+type Entry struct {
+	Kind        string          `json:"kind"` // query, exec, prepare, prepared_query, prepared_exec, blob_read, blob_write
+	SQL         string          `json:"sql"`
+	Params      json.RawMessage `json:"params,omitempty"` // JSON array, blob_write: blob_type and SHA-256 of the data
+	Status      int             `json:"status"`
+	ContentType string          `json:"content_type,omitempty"`
+	Body        string          `json:"body"`
+	Binary      bool            `json:"binary,omitempty"` // body is base64 encoded
+	Recorded    time.Time       `json:"recorded"`
+}
+
+// Request not found in the recording
+type Miss struct {
+	Kind   string          `json:"kind"`
+	SQL    string          `json:"sql"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Count  int64           `json:"count"`
+	Last   time.Time       `json:"last"`
+}
+
+func NewEntry(kind, sqlQuery string, params json.RawMessage, status int, contentType string, body []byte) *Entry {
+	e := &Entry{
+		Kind:        kind,
+		SQL:         sqlQuery,
+		Params:      params,
+		Status:      status,
+		ContentType: contentType,
+		Recorded:    time.Now(),
+	}
+	if utf8.Valid(body) {
+		e.Body = string(body)
+	} else {
+		e.Body = base64.StdEncoding.EncodeToString(body)
+		e.Binary = true
+	}
+	return e
+}
+
+// Response body as recorded
+func (e *Entry) Data() ([]byte, error) {
+	if e.Binary {
+		return base64.StdEncoding.DecodeString(e.Body)
+	}
+	return []byte(e.Body), nil
+}
+
+// Parameters in the same form however they were written: JSON array
+// without spacing, nil if there are none
+func CanonicalParams(data []byte) json.RawMessage {
+	var params []any
+	if err := json.Unmarshal(data, &params); err != nil {
+		return json.RawMessage(strings.TrimSpace(string(data)))
+	}
+	if len(params) == 0 {
+		return nil
+	}
+	canonical, err := json.Marshal(params)
+	if err != nil {
+		return data
+	}
+	return canonical
+}
+
+// Lookup key of the request for the matching level
+func Key(match, kind, sqlQuery string, params json.RawMessage) string {
+	switch match {
+	case app.MatchNormalized:
+		sqlQuery = db.NormalizeQuery(sqlQuery)
+	case app.MatchSQL:
+		sqlQuery = db.NormalizeQuery(sqlQuery)
+		params = nil
+	}
+	return kind + "\x00" + sqlQuery + "\x00" + string(CanonicalParams(params))
+}
+
+// Appends entries to the recording file
+type Recorder struct {
+	mu       sync.Mutex
+	file     *os.File
+	recorded int64
+}
+
+func OpenRecorder(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{file: file}, nil
+}
+
+func (r *Recorder) Write(e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err = r.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	r.recorded++
+	metricRequests.WithLabelValues("recorded").Inc()
+	return nil
+}
+
+func (r *Recorder) Recorded() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.recorded
+}
+
+func (r *Recorder) Close() error {
+	return r.file.Close()
+}
+
+// Misses kept for the report, the least frequent are dropped first
+const maxMisses = 1000
+
+// Serves recorded responses. Later recordings of the same request win
+type Player struct {
+	match   string
+	entries map[string]*Entry
+
+	mu     sync.Mutex
+	hits   int64
+	misses map[string]*Miss
+}
+
+func LoadPlayer(path, match string) (*Player, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	p := &Player{match: match, entries: make(map[string]*Entry), misses: make(map[string]*Miss)}
+	// Lines are not limited in length, a recorded query result may be large
+	reader := bufio.NewReader(file)
+	line := 0
+	for {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s:%d: %w", path, line+1, err)
+		}
+		if len(data) == 0 && err != nil {
+			return p, nil
+		}
+		line++
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		var e Entry
+		if err = json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		p.entries[Key(match, e.Kind, e.SQL, e.Params)] = &e
+	}
+
+}
+
+// Recorded response of the request, misses are counted for the report
+func (p *Player) Find(kind, sqlQuery string, params json.RawMessage) (*Entry, bool) {
+
+	key := Key(p.match, kind, sqlQuery, params)
+	e, found := p.entries[key]
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if found {
+		p.hits++
+		metricRequests.WithLabelValues("hit").Inc()
+		return e, true
+	}
+	miss, ok := p.misses[key]
+	if !ok {
+		if len(p.misses) >= maxMisses {
+			p.dropMiss()
+		}
+		miss = &Miss{Kind: kind, SQL: sqlQuery, Params: CanonicalParams(params)}
+		p.misses[key] = miss
+	}
+	miss.Count++
+	miss.Last = time.Now()
+	metricRequests.WithLabelValues("miss").Inc()
+	return nil, false
+
+}
+
+// Drops the least frequent miss, the earliest one of equally frequent
+func (p *Player) dropMiss() {
+	var dropKey string
+	var dropped *Miss
+	for key, miss := range p.misses {
+		if dropped == nil || miss.Count < dropped.Count || miss.Count == dropped.Count && miss.Last.Before(dropped.Last) {
+			dropKey, dropped = key, miss
+		}
+	}
+	delete(p.misses, dropKey)
+}
+
+func (p *Player) Match() string {
+	return p.match
+}
+
+func (p *Player) Recordings() int {
+	return len(p.entries)
+}
+
+// Hits and misses, the most frequent misses first
+func (p *Player) Stats() (int64, []Miss) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	misses := make([]Miss, 0, len(p.misses))
+	for _, miss := range p.misses {
+		misses = append(misses, *miss)
+	}
+	slices.SortFunc(misses, func(a, b Miss) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.SQL, b.SQL))
+	})
+	return p.hits, misses
+}
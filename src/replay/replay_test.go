@@ -0,0 +1,78 @@
+package replay
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sql-proxy/src/app"
+)
+
+func TestLoadPlayer(t *testing.T) {
+
+	// Longer than bufio.Scanner lines, the last one is not terminated
+	large := NewEntry("query", "SELECT * FROM big", nil, 200, "application/json", []byte(strings.Repeat("x", 1<<20)))
+	small := NewEntry("exec", "DELETE FROM t WHERE id = ?", json.RawMessage("[1]"), 200, "", nil)
+	var lines []string
+	for _, e := range []*Entry{large, small} {
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(data))
+	}
+	path := filepath.Join(t.TempDir(), "recording.jsonl")
+	if err := os.WriteFile(path, []byte(lines[0]+"\n\n"+lines[1]), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadPlayer(path, app.MatchNormalized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Recordings() != 2 {
+		t.Errorf("%d recordings, want 2", p.Recordings())
+	}
+	if e, ok := p.Find("query", "select *  from BIG", nil); !ok || len(e.Body) != 1<<20 {
+		t.Error("large entry not found")
+	}
+	if _, ok := p.Find("exec", "DELETE FROM t WHERE id = ?", json.RawMessage("[ 1 ]")); !ok {
+		t.Error("last entry not found")
+	}
+
+	if err = os.WriteFile(path, []byte(lines[1]+"\n{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadPlayer(path, app.MatchNormalized); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("error %v, want line 2", err)
+	}
+
+}
+
+func TestPlayerMisses(t *testing.T) {
+
+	p := &Player{match: app.MatchExact, entries: map[string]*Entry{}, misses: map[string]*Miss{}}
+	for range 3 {
+		p.Find("query", "SELECT frequent", nil)
+	}
+	for i := range maxMisses + 10 {
+		p.Find("query", "SELECT "+strconv.Itoa(i), nil)
+	}
+
+	hits, misses := p.Stats()
+	if hits != 0 || len(misses) != maxMisses {
+		t.Fatalf("%d hits, %d misses, want 0, %d", hits, len(misses), maxMisses)
+	}
+	if misses[0].SQL != "SELECT frequent" || misses[0].Count != 3 {
+		t.Errorf("first miss %+v", misses[0])
+	}
+	for _, miss := range misses {
+		if miss.SQL == "SELECT 0" {
+			t.Error("earliest miss is kept")
+		}
+	}
+
+}
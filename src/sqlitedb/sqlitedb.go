@@ -0,0 +1,76 @@
+// SQLite connections for the sqlite and files data sources. ATTACH is disabled,
+// so clients can't open database files outside of the allowed directories
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Opens the connection with no databases allowed to be attached.
+// VACUUM attaches the target database too, so it fails as well
+func Open(dsn string) (driver.Conn, error) {
+
+	c, err := (&sqlite.Driver{}).Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err = limitAttached(c); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("disabling ATTACH: %w", err)
+	}
+	return c, nil
+
+}
+
+// Connector of the pools, every connection is opened by Open
+func NewConnector(dsn string) driver.Connector {
+	return &connector{dsn: dsn}
+}
+
+type connector struct {
+	dsn string
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return Open(c.dsn)
+}
+
+func (c *connector) Driver() driver.Driver {
+	return &sqlite.Driver{}
+}
+
+// sqlite.Limit takes *sql.Conn only, so the connection is passed through
+// a temporary pool. The pool is closed while the connection is in use,
+// which leaves the connection open
+func limitAttached(c driver.Conn) error {
+
+	db := sql.OpenDB(single{c})
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	_, err = sqlite.Limit(conn, sqlite3.SQLITE_LIMIT_ATTACHED, 0)
+	return err
+
+}
+
+// Connector handing out the given connection
+type single struct {
+	conn driver.Conn
+}
+
+func (s single) Connect(context.Context) (driver.Conn, error) {
+	return s.conn, nil
+}
+
+func (s single) Driver() driver.Driver {
+	return &sqlite.Driver{}
+}
@@ -0,0 +1,308 @@
+// Go client of the SQL proxy API and database/sql driver "sqlproxy"
+package sqlproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API version sent with every request
+const ApiVersion = "1.2"
+
+// Error returned by the proxy with a non-2xx status
+type Error struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // 429 and 503 responses, 0 if not given
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("sqlproxy: %d %s", e.StatusCode, e.Message)
+}
+
+// Connection parameters, as the /connection request
+type ConnectionInfo struct {
+	DbType   string `json:"db_type,omitempty"`
+	Host     string `json:"host,omitempty"`
+	Port     uint16 `json:"port,omitempty"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	DbName   string `json:"db_name,omitempty"`
+	SSL      bool   `json:"ssl,omitempty"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+
+	ConnectionString string `json:"connection_string,omitempty"`
+
+	ServiceName string `json:"service_name,omitempty"`
+	SID         string `json:"sid,omitempty"`
+	Charset     string `json:"charset,omitempty"`
+	Role        string `json:"role,omitempty"`
+	DSN         string `json:"dsn,omitempty"`
+	Driver      string `json:"driver,omitempty"`
+
+	TLS             *TLSOptions       `json:"tls,omitempty"`
+	ConnectTimeout  string            `json:"connect_timeout,omitempty"` // "30s"
+	ReadTimeout     string            `json:"read_timeout,omitempty"`
+	ApplicationName string            `json:"application_name,omitempty"`
+	Options         map[string]string `json:"options,omitempty"`
+}
+
+type TLSOptions struct {
+	Mode       string `json:"mode"` // disable, require, verify-ca, verify-full
+	CA         string `json:"ca,omitempty"`
+	Cert       string `json:"cert,omitempty"`
+	Key        string `json:"key,omitempty"`
+	ServerName string `json:"server_name,omitempty"`
+}
+
+// Result of SELECT queries
+type Result struct {
+	ApiVersion     string           `json:"api_version"`
+	ConnectionId   string           `json:"connection_id"`
+	Info           string           `json:"info"`
+	RowsCount      uint32           `json:"rows_count"`
+	ExceedsMaxRows bool             `json:"exceeds_max_rows"`
+	Columns        []Column         `json:"columns"`
+	Rows           []map[string]any `json:"rows"` // numbers are json.Number
+}
+
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // database type name
+}
+
+// Client of the proxy at the base URL, like http://localhost:8080
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client // http.DefaultClient if nil
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Proxy connection, one pool on the server shared by clients with the same parameters
+type Connection struct {
+	Id string
+
+	// Rewrite ? parameters of prepared statements for the server (Translate-Placeholders)
+	TranslatePlaceholders bool
+
+	client *Client
+}
+
+type Statement struct {
+	Id  string
+	SQL string
+
+	conn *Connection
+}
+
+// Opens the connection, or gets the id of the existing one
+func (c *Client) Connect(ctx context.Context, info *ConnectionInfo) (*Connection, error) {
+
+	body, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	id, err := c.call(ctx, http.MethodPost, "/connection", nil, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return &Connection{Id: string(id), client: c}, nil
+
+}
+
+// Connection by the id obtained earlier
+func (c *Client) Connection(id string) *Connection {
+	return &Connection{Id: id, client: c}
+}
+
+// Closes the server pool, for all clients using it
+func (c *Connection) Close(ctx context.Context) error {
+	_, err := c.client.call(ctx, http.MethodDelete, "/connection", c.headers(), "", nil)
+	return err
+}
+
+func (c *Connection) Query(ctx context.Context, query string) (*Result, error) {
+	data, err := c.client.call(ctx, http.MethodPost, "/query", c.headers(), "text/plain", strings.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	return decodeResult(data)
+}
+
+func (c *Connection) Exec(ctx context.Context, query string) error {
+	_, err := c.client.call(ctx, http.MethodPut, "/query", c.headers(), "text/plain", strings.NewReader(query))
+	return err
+}
+
+func (c *Connection) Prepare(ctx context.Context, query string) (*Statement, error) {
+
+	headers := c.headers()
+	if c.TranslatePlaceholders {
+		headers.Set("Translate-Placeholders", "true")
+	}
+	id, err := c.client.call(ctx, http.MethodPost, "/prepared", headers, "text/plain", strings.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	return &Statement{Id: string(id), SQL: query, conn: c}, nil
+
+}
+
+// Reads the value of the single row, single column query
+func (c *Connection) ReadBlob(ctx context.Context, query string) ([]byte, error) {
+	return c.client.call(ctx, http.MethodPost, "/blob", c.headers(), "text/plain", strings.NewReader(query))
+}
+
+// Runs the query with the data as its single parameter. Text data is
+// passed as a string, to be encoded to the connection charset
+func (c *Connection) WriteBlob(ctx context.Context, query string, data []byte, text bool) error {
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("sql_query", query)
+	if text {
+		form.WriteField("blob_type", "text")
+	}
+	part, err := form.CreateFormFile("binary_data", "blob")
+	if err != nil {
+		return err
+	}
+	part.Write(data)
+	if err = form.Close(); err != nil {
+		return err
+	}
+
+	_, err = c.client.call(ctx, http.MethodPut, "/blob", c.headers(), form.FormDataContentType(), &body)
+	return err
+
+}
+
+func (s *Statement) Query(ctx context.Context, params ...any) (*Result, error) {
+	body, err := encodeParams(params)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.conn.client.call(ctx, http.MethodPost, "/prepared/query", s.headers(), "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	return decodeResult(data)
+}
+
+func (s *Statement) Exec(ctx context.Context, params ...any) error {
+	body, err := encodeParams(params)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.client.call(ctx, http.MethodPut, "/prepared/query", s.headers(), "application/json", body)
+	return err
+}
+
+func (s *Statement) Close(ctx context.Context) error {
+	_, err := s.conn.client.call(ctx, http.MethodDelete, "/prepared", s.headers(), "", nil)
+	return err
+}
+
+func (c *Connection) headers() http.Header {
+	headers := http.Header{}
+	headers.Set("Connection-Id", c.Id)
+	return headers
+}
+
+func (s *Statement) headers() http.Header {
+	headers := s.conn.headers()
+	headers.Set("Statement-Id", s.Id)
+	return headers
+}
+
+// Parameters as a JSON array. Binary values are not supported by the API,
+// they would be sent as base64 text
+func encodeParams(params []any) (io.Reader, error) {
+	for i, p := range params {
+		if _, ok := p.([]byte); ok {
+			return nil, fmt.Errorf("sqlproxy: parameter %d: binary parameters are not supported, use WriteBlob", i+1)
+		}
+	}
+	if params == nil {
+		params = []any{}
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(body), nil
+}
+
+func decodeResult(data []byte) (*Result, error) {
+	var result Result
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("sqlproxy: invalid response: %w", err)
+	}
+	return &result, nil
+}
+
+// Sends the request, returns the body of 2xx responses or *Error
+func (c *Client) call(ctx context.Context, method, path string, headers http.Header, contentType string, body io.Reader) ([]byte, error) {
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+"/api/v1"+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("API-Version", ApiVersion)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return data, nil
+	}
+
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return nil, apiErr
+
+}
+
+// The connection or statement is no longer known to the proxy, e.g. closed
+// as idle, and must be opened again
+func IsGone(err error) bool {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		return false
+	}
+	return apiErr.Message == "Invalid connection id" || apiErr.Message == "Prepared statement not found"
+}
@@ -0,0 +1,53 @@
+package sqlproxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientError(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	err := NewClient(srv.URL+"/").Connection("c").Exec(context.Background(), "DELETE FROM t")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error %v, want *Error", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Message != "Too many requests" || apiErr.RetryAfter != 3*time.Second {
+		t.Errorf("error %+v", apiErr)
+	}
+	if IsGone(err) {
+		t.Error("rate limited connection reported as gone")
+	}
+
+}
+
+func TestIsGone(t *testing.T) {
+
+	tests := []struct {
+		err  error
+		gone bool
+	}{
+		{&Error{StatusCode: http.StatusForbidden, Message: "Invalid connection id"}, true},
+		{&Error{StatusCode: http.StatusForbidden, Message: "Prepared statement not found"}, true},
+		{&Error{StatusCode: http.StatusForbidden, Message: "Read only connection"}, false},
+		{&Error{StatusCode: http.StatusNotFound, Message: "Invalid connection id"}, false},
+		{io.EOF, false},
+	}
+	for _, tt := range tests {
+		if got := IsGone(tt.err); got != tt.gone {
+			t.Errorf("IsGone(%v) = %v, want %v", tt.err, got, tt.gone)
+		}
+	}
+
+}
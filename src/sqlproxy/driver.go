@@ -0,0 +1,385 @@
+package sqlproxy
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTransactions = errors.New("sqlproxy: transactions are not supported by the proxy")
+	ErrNamedParams  = errors.New("sqlproxy: named parameters are not supported")
+
+	errNoResult = errors.New("sqlproxy: rows affected and insert id are not returned by the proxy")
+)
+
+func init() {
+	sql.Register("sqlproxy", &Driver{})
+}
+
+// The data source name is the proxy URL with the connection parameters:
+// http://localhost:8080?profile=erp or http://proxy:8080?db_type=postgres&host=db&user=u&password=p&db_name=erp.
+// Parameters are the fields of the /connection request, tls_mode sets tls.mode,
+// option.name sets the driver option, translate_placeholders=true rewrites ? parameters
+type Driver struct{}
+
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c.Connect(context.Background())
+}
+
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("sqlproxy: invalid URL %s, http or https expected", u.Redacted())
+	}
+
+	c := &connector{}
+	for key, values := range u.Query() {
+		if err = c.set(key, values[len(values)-1]); err != nil {
+			return nil, err
+		}
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	c.client = NewClient(u.String())
+	return c, nil
+
+}
+
+// Connector for sql.OpenDB with the configured client
+func NewConnector(client *Client, info ConnectionInfo, translatePlaceholders bool) driver.Connector {
+	return &connector{client: client, info: info, translate: translatePlaceholders}
+}
+
+type connector struct {
+	client    *Client
+	info      ConnectionInfo
+	translate bool
+}
+
+// Every connection of the database/sql pool gets the same proxy connection,
+// pooled by the proxy itself
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	proxyConn, err := c.client.Connect(ctx, &c.info)
+	if err != nil {
+		return nil, err
+	}
+	proxyConn.TranslatePlaceholders = c.translate
+	return &conn{proxy: proxyConn}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return &Driver{}
+}
+
+func (c *connector) set(key, value string) error {
+
+	info := &c.info
+	var err error
+	switch key {
+	case "db_type":
+		info.DbType = value
+	case "host":
+		info.Host = value
+	case "port":
+		var port uint64
+		port, err = strconv.ParseUint(value, 10, 16)
+		info.Port = uint16(port)
+	case "user":
+		info.User = value
+	case "password":
+		info.Password = value
+	case "db_name":
+		info.DbName = value
+	case "ssl":
+		info.SSL, err = strconv.ParseBool(value)
+	case "read_only":
+		info.ReadOnly, err = strconv.ParseBool(value)
+	case "profile":
+		info.Profile = value
+	case "connection_string":
+		info.ConnectionString = value
+	case "service_name":
+		info.ServiceName = value
+	case "sid":
+		info.SID = value
+	case "charset":
+		info.Charset = value
+	case "role":
+		info.Role = value
+	case "dsn":
+		info.DSN = value
+	case "driver":
+		info.Driver = value
+	case "tls_mode":
+		info.TLS = &TLSOptions{Mode: value}
+	case "connect_timeout":
+		info.ConnectTimeout = value
+	case "read_timeout":
+		info.ReadTimeout = value
+	case "application_name":
+		info.ApplicationName = value
+	case "translate_placeholders":
+		c.translate, err = strconv.ParseBool(value)
+	default:
+		name, found := strings.CutPrefix(key, "option.")
+		if !found {
+			return fmt.Errorf("sqlproxy: unknown parameter %s", key)
+		}
+		if info.Options == nil {
+			info.Options = map[string]string{}
+		}
+		info.Options[name] = value
+	}
+	if err != nil {
+		return fmt.Errorf("sqlproxy: invalid %s", key)
+	}
+	return nil
+
+}
+
+type conn struct {
+	proxy *Connection
+	bad   bool // the proxy no longer knows the connection
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	s, err := c.proxy.Prepare(ctx, query)
+	if err != nil {
+		return nil, c.check(err)
+	}
+	return &stmt{conn: c, proxy: s}, nil
+}
+
+// The proxy connection is shared, it is closed by the proxy when idle
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, ErrTransactions
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return nil, ErrTransactions
+}
+
+func (c *conn) IsValid() bool {
+	return !c.bad
+}
+
+// Queries without parameters are sent directly, others are prepared by database/sql
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	result, err := c.proxy.Query(ctx, query)
+	if err != nil {
+		return nil, c.check(err)
+	}
+	return newRows(result), nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	if err := c.proxy.Exec(ctx, query); err != nil {
+		return nil, c.check(err)
+	}
+	return result{}, nil
+}
+
+// Connections and statements closed by the proxy make database/sql retry on a new connection
+func (c *conn) check(err error) error {
+	if IsGone(err) {
+		c.bad = true
+		return driver.ErrBadConn
+	}
+	return err
+}
+
+type stmt struct {
+	conn  *conn
+	proxy *Statement
+}
+
+func (s *stmt) Close() error {
+	if err := s.proxy.Close(context.Background()); err != nil && !IsGone(err) {
+		return err
+	}
+	return nil
+}
+
+// Parameters are not checked, the server reports the mismatch
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	params, err := paramValues(args)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.proxy.Exec(ctx, params...); err != nil {
+		return nil, s.conn.check(err)
+	}
+	return result{}, nil
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	params, err := paramValues(args)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.proxy.Query(ctx, params...)
+	if err != nil {
+		return nil, s.conn.check(err)
+	}
+	return newRows(res), nil
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+func paramValues(args []driver.NamedValue) ([]any, error) {
+	params := make([]any, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, ErrNamedParams
+		}
+		params[i] = arg.Value
+	}
+	return params, nil
+}
+
+// The proxy does not return the number of affected rows
+type result struct{}
+
+func (result) LastInsertId() (int64, error) {
+	return 0, errNoResult
+}
+
+func (result) RowsAffected() (int64, error) {
+	return 0, errNoResult
+}
+
+// Rows of the result read at once by the proxy
+type rows struct {
+	result  *Result
+	columns []string
+	types   []string
+	next    int
+}
+
+func newRows(res *Result) *rows {
+
+	r := &rows{result: res}
+	for _, column := range res.Columns {
+		r.columns = append(r.columns, column.Name)
+		r.types = append(r.types, column.Type)
+	}
+
+	// Proxies without the column list, the order of the query is unknown
+	if len(res.Columns) == 0 && len(res.Rows) > 0 {
+		for name := range res.Rows[0] {
+			r.columns = append(r.columns, name)
+		}
+		slices.Sort(r.columns)
+		r.types = make([]string, len(r.columns))
+	}
+	return r
+
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.types[index]
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.Rows) {
+		return io.EOF
+	}
+	row := r.result.Rows[r.next]
+	for i, name := range r.columns {
+		dest[i] = driverValue(row[name], r.types[i])
+	}
+	r.next++
+	return nil
+}
+
+// JSON value converted back to the Go type: integers to int64, other numbers
+// to float64, date and time columns to time.Time
+func driverValue(v any, typeName string) driver.Value {
+
+	switch x := v.(type) {
+	case nil, bool:
+		return x
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i
+		}
+		if f, err := x.Float64(); err == nil {
+			return f
+		}
+		return x.String()
+	case string:
+		typeName = strings.ToUpper(typeName)
+		if strings.Contains(typeName, "DATE") || strings.Contains(typeName, "TIME") {
+			if t, err := time.Parse(time.RFC3339Nano, x); err == nil {
+				return t
+			}
+		}
+		return x
+	}
+
+	// JSON objects and arrays are returned as text
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+
+}
@@ -0,0 +1,305 @@
+package sqlproxy
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpenConnector(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		dsn       string
+		url       string
+		info      ConnectionInfo
+		translate bool
+		err       string
+	}{
+		{
+			name: "profile",
+			dsn:  "http://localhost:8080/?profile=erp#x",
+			url:  "http://localhost:8080",
+			info: ConnectionInfo{Profile: "erp"},
+		},
+		{
+			name: "connection fields",
+			dsn: "https://proxy:8443?db_type=postgres&host=db&port=5432&user=u&password=p%26w&db_name=erp" +
+				"&ssl=true&read_only=1&tls_mode=verify-full&connect_timeout=5s&option.search_path=app&translate_placeholders=true",
+			url: "https://proxy:8443",
+			info: ConnectionInfo{DbType: "postgres", Host: "db", Port: 5432, User: "u", Password: "p&w", DbName: "erp",
+				SSL: true, ReadOnly: true, TLS: &TLSOptions{Mode: "verify-full"}, ConnectTimeout: "5s",
+				Options: map[string]string{"search_path": "app"}},
+			translate: true,
+		},
+		{
+			name: "last value wins",
+			dsn:  "http://localhost?host=a&host=b",
+			url:  "http://localhost",
+			info: ConnectionInfo{Host: "b"},
+		},
+		{name: "not http", dsn: "postgres://db/erp", err: "http or https expected"},
+		{name: "unknown parameter", dsn: "http://localhost?hots=db", err: "unknown parameter hots"},
+		{name: "port out of range", dsn: "http://localhost?port=65536", err: "invalid port"},
+		{name: "invalid bool", dsn: "http://localhost?ssl=maybe", err: "invalid ssl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := (&Driver{}).OpenConnector(tt.dsn)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := c.(*connector)
+			if got.client.BaseURL != tt.url {
+				t.Errorf("url %q, want %q", got.client.BaseURL, tt.url)
+			}
+			if !reflect.DeepEqual(got.info, tt.info) {
+				t.Errorf("info %+v, want %+v", got.info, tt.info)
+			}
+			if got.translate != tt.translate {
+				t.Errorf("translate %v, want %v", got.translate, tt.translate)
+			}
+		})
+	}
+
+}
+
+func TestNewRows(t *testing.T) {
+
+	rows := []map[string]any{{"a": json.Number("1"), "b": "x", "c": nil}}
+
+	// Query order from the column list, not the map order of the row
+	r := newRows(&Result{Columns: []Column{{"c", "INT"}, {"a", "INT"}, {"b", "TEXT"}}, Rows: rows})
+	if want := []string{"c", "a", "b"}; !reflect.DeepEqual(r.Columns(), want) {
+		t.Errorf("columns %v, want %v", r.Columns(), want)
+	}
+	if r.ColumnTypeDatabaseTypeName(2) != "TEXT" {
+		t.Errorf("type %q, want TEXT", r.ColumnTypeDatabaseTypeName(2))
+	}
+	dest := make([]driver.Value, 3)
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if want := []driver.Value{nil, int64(1), "x"}; !reflect.DeepEqual(dest, want) {
+		t.Errorf("row %v, want %v", dest, want)
+	}
+	if err := r.Next(dest); err != io.EOF {
+		t.Errorf("error %v, want io.EOF", err)
+	}
+
+	// Without the column list the names are sorted
+	r = newRows(&Result{Rows: rows})
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(r.Columns(), want) {
+		t.Errorf("columns %v, want %v", r.Columns(), want)
+	}
+	if r.ColumnTypeDatabaseTypeName(0) != "" {
+		t.Errorf("type %q, want none", r.ColumnTypeDatabaseTypeName(0))
+	}
+
+}
+
+func TestDriverValue(t *testing.T) {
+
+	stamp := time.Date(2024, 5, 6, 7, 8, 9, 500, time.FixedZone("", 3*3600))
+	tests := []struct {
+		name     string
+		value    any
+		typeName string
+		want     driver.Value
+	}{
+		{"null", nil, "INT", nil},
+		{"bool", true, "BOOL", true},
+		{"integer", json.Number("-42"), "INT", int64(-42)},
+		{"float", json.Number("1.5"), "NUMERIC", 1.5},
+		{"exponent", json.Number("1e3"), "FLOAT", 1000.0},
+		{"beyond int64", json.Number("1e400"), "NUMERIC", "1e400"},
+		{"text", "2024-05-06", "TEXT", "2024-05-06"},
+		{"timestamp", stamp.Format(time.RFC3339Nano), "timestamptz", stamp},
+		{"datetime", "2024-05-06T07:08:09Z", "DATETIME", time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)},
+		{"date not RFC 3339", "06.05.2024", "DATE", "06.05.2024"},
+		{"array", []any{json.Number("1"), "a"}, "ARRAY", `[1,"a"]`},
+		{"object", map[string]any{"k": json.Number("2")}, "JSON", `{"k":2}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := driverValue(tt.value, tt.typeName)
+			if want, ok := tt.want.(time.Time); ok {
+				if got, ok := got.(time.Time); !ok || !got.Equal(want) {
+					t.Errorf("got %#v, want %v", got, want)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+}
+
+// Proxy whose connections and statements can be dropped, as the real one drops idle ones
+type fakeProxy struct {
+	mu      sync.Mutex
+	nextId  int
+	conns   map[string]bool
+	stmts   map[string]string
+	connect []ConnectionInfo
+}
+
+func newFakeProxy(t *testing.T) (*fakeProxy, string) {
+
+	p := &fakeProxy{conns: map[string]bool{}, stmts: map[string]string{}}
+	srv := httptest.NewServer(p)
+	t.Cleanup(srv.Close)
+	return p, srv.URL
+
+}
+
+func (p *fakeProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if r.Header.Get("API-Version") != ApiVersion {
+		http.Error(w, "API version", http.StatusNotImplemented)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	newId := func() string {
+		p.nextId++
+		return string(rune('a' + p.nextId - 1))
+	}
+
+	route := r.Method + " " + strings.TrimPrefix(r.URL.Path, "/api/v1")
+	if route == "POST /connection" {
+		var info ConnectionInfo
+		json.Unmarshal(body, &info)
+		p.connect = append(p.connect, info)
+		id := newId()
+		p.conns[id] = true
+		w.Write([]byte(id))
+		return
+	}
+	if !p.conns[r.Header.Get("Connection-Id")] {
+		http.Error(w, "Invalid connection id", http.StatusForbidden)
+		return
+	}
+
+	query := string(body)
+	switch route {
+	case "POST /prepared":
+		id := newId()
+		p.stmts[id] = query
+		w.Write([]byte(id))
+		return
+	case "POST /prepared/query":
+		var ok bool
+		if query, ok = p.stmts[r.Header.Get("Statement-Id")]; !ok {
+			http.Error(w, "Prepared statement not found", http.StatusForbidden)
+			return
+		}
+	case "POST /query":
+	default:
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	// Columns are listed in the query order, the row is a JSON object
+	json.NewEncoder(w).Encode(map[string]any{
+		"columns": []Column{{"name", "TEXT"}, {"id", "INT"}, {"created", "TIMESTAMP"}},
+		"rows":    []map[string]any{{"id": 1, "name": query, "created": "2024-05-06T07:08:09Z"}},
+	})
+
+}
+
+// Connections and statements the proxy no longer knows
+func (p *fakeProxy) drop() {
+	p.mu.Lock()
+	clear(p.conns)
+	clear(p.stmts)
+	p.mu.Unlock()
+}
+
+func TestDriverReconnect(t *testing.T) {
+
+	proxy, url := newFakeProxy(t)
+	db, err := sql.Open("sqlproxy", url+"?profile=erp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	queryRow := func(query string, args ...any) {
+		t.Helper()
+		var name string
+		var id int64
+		var created time.Time
+		if err := db.QueryRow(query, args...).Scan(&name, &id, &created); err != nil {
+			t.Fatal(err)
+		}
+		if name != query || id != 1 || !created.Equal(time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)) {
+			t.Errorf("row %q %d %v", name, id, created)
+		}
+	}
+
+	queryRow("SELECT 1")
+	stmt, err := db.Prepare("SELECT ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	// Statements and connections closed by the proxy are reported as driver.ErrBadConn,
+	// so database/sql opens a new connection and prepares the statement again
+	proxy.drop()
+	var name string
+	var id int64
+	var created time.Time
+	if err = stmt.QueryRow(1).Scan(&name, &id, &created); err != nil {
+		t.Fatal(err)
+	}
+	if name != "SELECT ?" {
+		t.Errorf("statement query %q", name)
+	}
+	proxy.drop()
+	queryRow("SELECT 2")
+
+	if len(proxy.connect) != 3 {
+		t.Errorf("%d connections, want 3", len(proxy.connect))
+	}
+	for _, info := range proxy.connect {
+		if info.Profile != "erp" {
+			t.Errorf("connection info %+v", info)
+		}
+	}
+
+}
+
+func TestConnCheck(t *testing.T) {
+
+	c := &conn{}
+	if err := c.check(io.EOF); err != io.EOF || !c.IsValid() {
+		t.Errorf("check: %v, valid %v", err, c.IsValid())
+	}
+	if err := c.check(&Error{StatusCode: http.StatusForbidden, Message: "Invalid connection id"}); err != driver.ErrBadConn || c.IsValid() {
+		t.Errorf("check: %v, valid %v", err, c.IsValid())
+	}
+
+}
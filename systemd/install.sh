@@ -38,6 +38,7 @@ Environment="BIND_ADDR=127.0.0.1"
 Environment="BIND_PORT=8080"
 Environment="MAX_ROWS=10000"
 #Environment="DEBUG_LOG=true"
+#Environment="CONFIG_FILE=/etc/sql-proxy/config.yml"
 #Environment="TLS_CERT=/etc/ssl/certs/cert.pem"
 #Environment="TLS_KEY=/etc/ssl/private/key.pem"
 